@@ -240,7 +240,10 @@ var pkgDeps = map[string][]string{
 	// Basic networking.
 	// Because net must be used by any package that wants to
 	// do networking portably, it must have a small dependency set: just L1+basic os.
-	"net": {"L1", "CGO", "os", "syscall", "time"},
+	"net": {"L1", "CGO", "os", "syscall", "time", "net/internal/netlink"},
+
+	// Generic netlink shared by net and net/tipcconfig.
+	"net/internal/netlink": {"L0", "syscall"},
 
 	// NET enables use of basic network-related packages.
 	"NET": {
@@ -313,6 +316,7 @@ var pkgDeps = map[string][]string{
 	// Simple net+crypto-aware packages.
 	"mime/multipart": {"L4", "OS", "mime", "crypto/rand", "net/textproto"},
 	"net/smtp":       {"L4", "CRYPTO", "NET", "crypto/tls"},
+	"net/tipcconfig": {"L4", "NET", "OS", "syscall", "net/internal/netlink"},
 
 	// HTTP, kingpin of dependencies.
 	"net/http": {
@@ -328,7 +332,7 @@ var pkgDeps = map[string][]string{
 	"net/http/httptest": {"L4", "NET", "OS", "crypto/tls", "flag", "net/http"},
 	"net/http/httputil": {"L4", "NET", "OS", "net/http", "net/http/internal"},
 	"net/http/pprof":    {"L4", "OS", "html/template", "net/http", "runtime/pprof"},
-	"net/rpc":           {"L4", "NET", "encoding/gob", "html/template", "net/http"},
+	"net/rpc":           {"L4", "NET", "encoding/gob", "html/template", "net/http", "syscall"},
 	"net/rpc/jsonrpc":   {"L4", "NET", "encoding/json", "net/rpc"},
 }
 
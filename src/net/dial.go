@@ -24,6 +24,10 @@ type Dialer struct {
 	// With or without a timeout, the operating system may impose
 	// its own earlier timeout. For instance, TCP timeouts are
 	// often around 3 minutes.
+	//
+	// When dialing "tipc" or "tipc-seqpacket", the time left until
+	// the timeout or deadline is also set as the TIPC_CONN_TIMEOUT
+	// of the socket, which otherwise defaults to 8 seconds.
 	Timeout time.Duration
 
 	// Deadline is the absolute point in time after which dials
@@ -49,6 +53,9 @@ type Dialer struct {
 	// network connection.
 	// If zero, keep-alives are not enabled. Network protocols
 	// that do not support keep-alives ignore this field.
+	// TIPC connections ignore it too: the links between TIPC
+	// nodes are supervised by the kernel, which aborts the
+	// connections over a link that fails.
 	KeepAlive time.Duration
 }
 
@@ -72,7 +79,7 @@ func parseNetwork(net string) (afnet string, proto int, err error) {
 		switch net {
 		case "tcp", "tcp4", "tcp6":
 		case "udp", "udp4", "udp6":
-		case "tipc":
+		case "tipc", "tipc-seqpacket", "tipc-rdm", "tipc-dgram":
 		case "ip", "ip4", "ip6":
 		case "unix", "unixgram", "unixpacket":
 		default:
@@ -107,8 +114,8 @@ func resolveAddr(op, net, addr string, deadline time.Time) (netaddr, error) {
 	switch afnet {
 	case "unix", "unixgram", "unixpacket":
 		return ResolveUnixAddr(afnet, addr)
-	case "tipc":
-		return ResolveTIPCAddr(afnet, addr) // SANDEEP 
+	case "tipc", "tipc-seqpacket", "tipc-rdm", "tipc-dgram":
+		return ResolveTIPCAddr(afnet, addr)
 	}
 	return resolveInternetAddr(afnet, addr, deadline)
 }
@@ -117,8 +124,9 @@ func resolveAddr(op, net, addr string, deadline time.Time) (netaddr, error) {
 //
 // Known networks are "tcp", "tcp4" (IPv4-only), "tcp6" (IPv6-only),
 // "udp", "udp4" (IPv4-only), "udp6" (IPv6-only), "ip", "ip4"
-// (IPv4-only), "ip6" (IPv6-only), "unix", "unixgram" and
-// "unixpacket".
+// (IPv4-only), "ip6" (IPv6-only), "unix", "unixgram",
+// "unixpacket", "tipc", "tipc-seqpacket", "tipc-rdm" and
+// "tipc-dgram".
 //
 // For TCP and UDP networks, addresses have the form host:port.
 // If host is a literal IPv6 address it must be enclosed
@@ -141,6 +149,11 @@ func resolveAddr(op, net, addr string, deadline time.Time) (netaddr, error) {
 //	Dial("ip6:ospf", "::1")
 //
 // For Unix networks, the address must be a file system path.
+//
+// For TIPC networks, the address must be a TIPC name of the form
+// "{service,instance}", a name sequence of the form
+// "{service,lower,upper}" or a port identity of the form
+// "<zone.cluster.node:ref>"; see ResolveTIPCAddr.
 func Dial(network, address string) (Conn, error) {
 	var d Dialer
 	return d.Dial(network, address)
@@ -243,8 +256,19 @@ func dialSingle(net, addr string, la, ra Addr, deadline time.Time) (c Conn, err
 		la, _ := la.(*IPAddr)
 		c, err = dialIP(net, la, ra, deadline)
 	case *TIPCAddr:
+		// Every TIPC address reports the "tipc" network, so
+		// the check above cannot tell a foreign address that
+		// claims the same network.
+		if _, ok := la.(*TIPCAddr); la != nil && !ok {
+			return nil, &OpError{Op: "dial", Net: net, Addr: ra, Err: &AddrError{Err: "unexpected local address type", Addr: la.String()}}
+		}
 		la, _ := la.(*TIPCAddr)
-		c, err = dialTIPC(net, la, ra, deadline)
+		switch net {
+		case "tipc-rdm", "tipc-dgram":
+			c, err = dialTIPCPacket(net, la, ra, deadline)
+		default:
+			c, err = dialTIPC(net, la, ra, deadline)
+		}
 	case *UnixAddr:
 		la, _ := la.(*UnixAddr)
 		c, err = dialUnix(net, la, ra, deadline)
@@ -259,7 +283,7 @@ func dialSingle(net, addr string, la, ra Addr, deadline time.Time) (c Conn, err
 
 // Listen announces on the local network address laddr.
 // The network net must be a stream-oriented network: "tcp", "tcp4",
-// "tcp6", "unix" or "unixpacket".
+// "tcp6", "unix", "unixpacket", "tipc" or "tipc-seqpacket".
 // See Dial for the syntax of laddr.
 func Listen(net, laddr string) (Listener, error) {
 	la, err := resolveAddr("listen", net, laddr, noDeadline)
@@ -285,7 +309,7 @@ func Listen(net, laddr string) (Listener, error) {
 
 // ListenPacket announces on the local network address laddr.
 // The network net must be a packet-oriented network: "udp", "udp4",
-// "udp6", "ip", "ip4", "ip6" or "unixgram".
+// "udp6", "ip", "ip4", "ip6", "unixgram", "tipc-rdm" or "tipc-dgram".
 // See Dial for the syntax of laddr.
 func ListenPacket(net, laddr string) (PacketConn, error) {
 	la, err := resolveAddr("listen", net, laddr, noDeadline)
@@ -298,6 +322,8 @@ func ListenPacket(net, laddr string) (PacketConn, error) {
 		l, err = ListenUDP(net, la)
 	case *IPAddr:
 		l, err = ListenIP(net, la)
+	case *TIPCAddr:
+		l, err = ListenTIPCPacket(net, la)
 	case *UnixAddr:
 		l, err = ListenUnixgram(net, la)
 	default:
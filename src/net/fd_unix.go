@@ -307,8 +307,11 @@ func (fd *netFD) readMsg(p []byte, oob []byte) (n, oobn, flags int, sa syscall.S
 	return
 }
 
+// chkReadErr reports a zero-byte read on a connection-oriented socket
+// as io.EOF.  Message-oriented sockets such as SOCK_DGRAM, SOCK_RAW
+// and TIPC's SOCK_RDM may legitimately deliver empty messages.
 func chkReadErr(n int, err error, fd *netFD) error {
-	if n == 0 && err == nil && fd.sotype != syscall.SOCK_DGRAM && fd.sotype != syscall.SOCK_RAW {
+	if n == 0 && err == nil && (fd.sotype == syscall.SOCK_STREAM || fd.sotype == syscall.SOCK_SEQPACKET) {
 		return io.EOF
 	}
 	return err
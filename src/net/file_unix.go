@@ -33,8 +33,11 @@ func newFileFD(f *os.File) (*netFD, error) {
 	lsa, _ := syscall.Getsockname(fd)
 	switch lsa.(type) {
 	default:
-		closesocket(fd)
-		return nil, syscall.EINVAL
+		family = tipcSockaddrFamily(lsa)
+		if toAddr = tipcAddrFunc(family, sotype); toAddr == nil {
+			closesocket(fd)
+			return nil, syscall.EINVAL
+		}
 	case *syscall.SockaddrInet4:
 		family = syscall.AF_INET
 		if sotype == syscall.SOCK_DGRAM {
@@ -62,7 +65,12 @@ func newFileFD(f *os.File) (*netFD, error) {
 	rsa, _ := syscall.Getpeername(fd)
 	raddr := toAddr(rsa)
 
-	netfd, err := newFD(fd, family, sotype, laddr.Network())
+	net := laddr.Network()
+	if _, ok := laddr.(*TIPCAddr); ok {
+		// Every TIPC address reports the "tipc" network.
+		net = tipcSotypeToNet(sotype)
+	}
+	netfd, err := newFD(fd, family, sotype, net)
 	if err != nil {
 		closesocket(fd)
 		return nil, err
@@ -93,6 +101,13 @@ func FileConn(f *os.File) (c Conn, err error) {
 		return newIPConn(fd), nil
 	case *UnixAddr:
 		return newUnixConn(fd), nil
+	case *TIPCAddr:
+		switch fd.net {
+		case "tipc", "tipc-seqpacket":
+			return newTIPCConn(fd), nil
+		case "tipc-rdm", "tipc-dgram":
+			return newTIPCPacketConn(fd), nil
+		}
 	}
 	fd.Close()
 	return nil, syscall.EINVAL
@@ -112,6 +127,11 @@ func FileListener(f *os.File) (l Listener, err error) {
 		return &TCPListener{fd}, nil
 	case *UnixAddr:
 		return &UnixListener{fd, laddr.Name}, nil
+	case *TIPCAddr:
+		switch fd.net {
+		case "tipc", "tipc-seqpacket":
+			return &TIPCListener{fd: fd}, nil
+		}
 	}
 	fd.Close()
 	return nil, syscall.EINVAL
@@ -133,6 +153,11 @@ func FilePacketConn(f *os.File) (c PacketConn, err error) {
 		return newIPConn(fd), nil
 	case *UnixAddr:
 		return newUnixConn(fd), nil
+	case *TIPCAddr:
+		switch fd.net {
+		case "tipc-rdm", "tipc-dgram":
+			return newTIPCPacketConn(fd), nil
+		}
 	}
 	fd.Close()
 	return nil, syscall.EINVAL
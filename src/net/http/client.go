@@ -337,6 +337,8 @@ func (c *Client) doFollowingRedirects(ireq *Request, shouldRedirect func(int) bo
 		if redirect != 0 {
 			nreq := new(Request)
 			nreq.Method = ireq.Method
+			nreq.Cancel = ireq.Cancel
+			nreq.Deadline = ireq.Deadline
 			if ireq.Method == "POST" || ireq.Method == "PUT" {
 				nreq.Method = "GET"
 			}
@@ -76,5 +76,15 @@ custom Server:
 		MaxHeaderBytes: 1 << 20,
 	}
 	log.Fatal(s.ListenAndServe())
+
+The Transport and the Server can speak HTTP/2 over TLS with peers
+that support it, negotiated with the "h2" protocol. HTTP/2 is off by
+default; listing "h2" in the NextProtos of the Transport's
+TLSClientConfig or of the TLS configuration the Server listens with
+turns it on:
+
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{NextProtos: []string{"h2", "http/1.1"}},
+	}
 */
 package http
@@ -106,3 +106,11 @@ func SetPendingDialHooks(before, after func()) {
 var ExportServerNewConn = (*Server).newConn
 
 var ExportCloseWriteAndWait = (*conn).closeWriteAndWait
+
+// SetShutdownNewConnGrace sets how long Server.Shutdown waits for a
+// new connection's first request, and returns a func restoring it.
+func SetShutdownNewConnGrace(d time.Duration) (restore func()) {
+	old := shutdownNewConnGrace
+	shutdownNewConnGrace = d
+	return func() { shutdownNewConnGrace = old }
+}
@@ -0,0 +1,454 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/2 connection state shared by the server and the client.
+// See RFC 7540.
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// http2NextProtoTLS is the NPN/ALPN protocol negotiated during
+// HTTP/2's TLS setup.
+const http2NextProtoTLS = "h2"
+
+var (
+	http2errClosedConn  = errors.New("http2: connection closed")
+	http2errStreamReset = errors.New("http2: stream reset")
+	http2errClosedBody  = errors.New("http2: read on closed body")
+)
+
+// http2flow is the flow control state of a stream.
+type http2flow struct {
+	send        int32 // bytes we may send before a WINDOW_UPDATE
+	recv        int32 // bytes the peer may send before a WINDOW_UPDATE
+	recvPending int32 // bytes consumed but not yet returned to the peer
+	err         error // set when the stream may no longer send
+}
+
+// http2conn holds the state common to both ends of an HTTP/2
+// connection. The read loop of the owning connection is the only
+// reader of fr; writers hold wmu.
+type http2conn struct {
+	nc net.Conn
+	bw *bufio.Writer
+	fr *http2Framer
+
+	wmu  sync.Mutex // guards writes to fr and hbuf
+	hbuf []byte     // header block being encoded
+
+	mu               sync.Mutex
+	cond             sync.Cond // signaled when windows grow or streams end
+	err              error     // sticky error once the connection is done
+	peerMaxFrameSize uint32
+	peerInitWindow   int32 // peer's SETTINGS_INITIAL_WINDOW_SIZE
+	sendWindow       int32 // connection-level send window
+	recvWindow       int32 // connection-level receive window
+	recvPending      int32 // bytes received but not yet returned
+	initRecvWindow   int32 // our SETTINGS_INITIAL_WINDOW_SIZE
+}
+
+func (c *http2conn) init(nc net.Conn, r io.Reader, initRecvWindow int32) {
+	c.nc = nc
+	c.bw = bufio.NewWriter(nc)
+	c.fr = newHTTP2Framer(c.bw, r)
+	c.cond.L = &c.mu
+	c.peerMaxFrameSize = http2initialMaxFrameSize
+	c.peerInitWindow = http2initialWindowSize
+	c.sendWindow = http2initialWindowSize
+	c.recvWindow = http2initialWindowSize
+	c.initRecvWindow = initRecvWindow
+}
+
+// fail records err as the reason the connection is done, wakes all
+// waiting writers and closes the network connection.
+func (c *http2conn) fail(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	c.nc.Close()
+}
+
+// writeFrames calls fn with wmu held and flushes what it wrote. A
+// write error ends the connection.
+func (c *http2conn) writeFrames(fn func(fr *http2Framer) error) error {
+	c.wmu.Lock()
+	err := fn(c.fr)
+	if err == nil {
+		err = c.fr.Flush()
+	}
+	c.wmu.Unlock()
+	if err != nil {
+		c.fail(err)
+	}
+	return err
+}
+
+// writeHeaders encodes fields as a header block and writes it to the
+// stream whose ID streamID returns. streamID is called with wmu held
+// just before the block is written, which lets clients number their
+// streams in the order they are opened.
+func (c *http2conn) writeHeaders(streamID func() uint32, fields []http2headerField, endStream bool) error {
+	c.mu.Lock()
+	maxFrame := c.peerMaxFrameSize
+	c.mu.Unlock()
+	return c.writeFrames(func(fr *http2Framer) error {
+		c.hbuf = c.hbuf[:0]
+		for _, f := range fields {
+			c.hbuf = http2appendHeaderField(c.hbuf, f)
+		}
+		return fr.WriteHeaderBlock(streamID(), endStream, c.hbuf, maxFrame)
+	})
+}
+
+// writeData sends p as DATA frames on the stream, waiting for the
+// stream and connection flow control windows as needed. An empty p
+// with endStream set sends an empty DATA frame ending the stream.
+func (c *http2conn) writeData(s *http2flow, streamID uint32, p []byte, endStream bool) error {
+	for {
+		c.mu.Lock()
+		for len(p) > 0 && c.err == nil && s.err == nil && (s.send <= 0 || c.sendWindow <= 0) {
+			c.cond.Wait()
+		}
+		if err := c.err; err != nil {
+			c.mu.Unlock()
+			return err
+		}
+		if err := s.err; err != nil {
+			c.mu.Unlock()
+			return err
+		}
+		n := int32(len(p))
+		if n > s.send {
+			n = s.send
+		}
+		if n > c.sendWindow {
+			n = c.sendWindow
+		}
+		if n > int32(c.peerMaxFrameSize) {
+			n = int32(c.peerMaxFrameSize)
+		}
+		if n < 0 {
+			n = 0
+		}
+		s.send -= n
+		c.sendWindow -= n
+		c.mu.Unlock()
+
+		chunk := p[:n]
+		p = p[n:]
+		var flags uint8
+		if endStream && len(p) == 0 {
+			flags = http2FlagEndStream
+		}
+		err := c.writeFrames(func(fr *http2Framer) error {
+			return fr.WriteFrame(http2FrameData, flags, streamID, chunk)
+		})
+		if err != nil || len(p) == 0 {
+			return err
+		}
+	}
+}
+
+// applySetting applies a setting received from the peer. streams
+// returns the flow control state of the open streams, whose send
+// windows change with SETTINGS_INITIAL_WINDOW_SIZE. It is called
+// with mu held.
+func (c *http2conn) applySetting(s http2Setting, streams func(func(*http2flow))) error {
+	switch s.ID {
+	case http2SettingMaxFrameSize:
+		c.peerMaxFrameSize = s.Val
+	case http2SettingInitialWindowSize:
+		delta := int32(s.Val) - c.peerInitWindow
+		c.peerInitWindow = int32(s.Val)
+		var err error
+		streams(func(f *http2flow) {
+			n := int64(f.send) + int64(delta)
+			if n > http2maxWindowSize {
+				err = http2ConnectionError(http2ErrCodeFlowControl)
+			}
+			f.send = int32(n)
+		})
+		c.cond.Broadcast()
+		return err
+	}
+	return nil
+}
+
+// handleSettings acknowledges a SETTINGS frame and applies it. The
+// peer's acknowledgements of our settings are ignored.
+func (c *http2conn) handleSettings(f *http2Frame, streams func(func(*http2flow)), apply func(http2Setting) error) error {
+	if f.has(http2FlagAck) {
+		return http2parseSettings(f, nil)
+	}
+	c.mu.Lock()
+	err := http2parseSettings(f, func(s http2Setting) error {
+		if err := c.applySetting(s, streams); err != nil {
+			return err
+		}
+		if apply != nil {
+			return apply(s)
+		}
+		return nil
+	})
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return c.writeFrames(func(fr *http2Framer) error {
+		return fr.WriteFrame(http2FrameSettings, http2FlagAck, 0)
+	})
+}
+
+// handleWindowUpdate adds the increment of a WINDOW_UPDATE frame to
+// the connection's send window, or to s's if the frame is for a
+// stream. s is nil for streams that are already closed.
+func (c *http2conn) handleWindowUpdate(f *http2Frame, s *http2flow) error {
+	incr, err := http2parseWindowUpdate(f)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &c.sendWindow
+	if f.StreamID != 0 {
+		if s == nil {
+			return nil
+		}
+		w = &s.send
+	}
+	if int64(*w)+int64(incr) > http2maxWindowSize {
+		if f.StreamID != 0 {
+			return http2StreamError{f.StreamID, http2ErrCodeFlowControl}
+		}
+		return http2ConnectionError(http2ErrCodeFlowControl)
+	}
+	*w += int32(incr)
+	c.cond.Broadcast()
+	return nil
+}
+
+// handlePing answers a PING frame.
+func (c *http2conn) handlePing(f *http2Frame) error {
+	if err := http2checkPing(f); err != nil {
+		return err
+	}
+	if f.has(http2FlagAck) {
+		return nil
+	}
+	data := append([]byte(nil), f.Payload...)
+	return c.writeFrames(func(fr *http2Framer) error {
+		return fr.WriteFrame(http2FramePing, http2FlagAck, 0, data)
+	})
+}
+
+// recvData accounts for the payload of a DATA frame, including its
+// padding, against the receive windows of the connection and of s,
+// and returns the data it carries. s is nil if the stream is not
+// open, in which case the data is discarded. The connection window
+// is returned to the peer as data arrives: the stream windows bound
+// how much is buffered.
+func (c *http2conn) recvData(f *http2Frame, s *http2flow) ([]byte, error) {
+	data, err := http2unpad(f)
+	if err != nil {
+		return nil, err
+	}
+	n := int32(len(f.Payload))
+	c.mu.Lock()
+	if n > c.recvWindow {
+		c.mu.Unlock()
+		return nil, http2ConnectionError(http2ErrCodeFlowControl)
+	}
+	c.recvWindow -= n
+	c.recvPending += n
+	connIncr := int32(0)
+	if c.recvPending >= http2initialWindowSize/4 {
+		connIncr = c.recvPending
+		c.recvWindow += connIncr
+		c.recvPending = 0
+	}
+	if s != nil {
+		if n > s.recv {
+			c.mu.Unlock()
+			c.sendWindowUpdate(0, connIncr)
+			return nil, http2StreamError{f.StreamID, http2ErrCodeFlowControl}
+		}
+		s.recv -= n
+	}
+	c.mu.Unlock()
+	c.sendWindowUpdate(0, connIncr)
+	if s != nil {
+		// Padding is never read, so return it right away.
+		c.consumed(s, f.StreamID, n-int32(len(data)))
+	}
+	return data, nil
+}
+
+// consumed returns n bytes of the stream's receive window to the
+// peer once enough has been read to make a WINDOW_UPDATE worthwhile.
+func (c *http2conn) consumed(s *http2flow, streamID uint32, n int32) {
+	if n <= 0 {
+		return
+	}
+	c.mu.Lock()
+	s.recvPending += n
+	incr := int32(0)
+	if s.err == nil && s.recvPending >= c.initRecvWindow/4 {
+		incr = s.recvPending
+		s.recv += incr
+		s.recvPending = 0
+	}
+	c.mu.Unlock()
+	c.sendWindowUpdate(streamID, incr)
+}
+
+func (c *http2conn) sendWindowUpdate(streamID uint32, n int32) {
+	if n <= 0 {
+		return
+	}
+	c.writeFrames(func(fr *http2Framer) error {
+		return fr.WriteWindowUpdate(streamID, uint32(n))
+	})
+}
+
+// resetStream sends a RST_STREAM frame.
+func (c *http2conn) resetStream(streamID uint32, code http2ErrCode) error {
+	return c.writeFrames(func(fr *http2Framer) error {
+		return fr.WriteRSTStream(streamID, code)
+	})
+}
+
+// http2pipe is a buffered pipe carrying the body of a request or
+// response from the read loop to the reader of the body.
+type http2pipe struct {
+	mu     sync.Mutex
+	c      sync.Cond
+	b      bytes.Buffer
+	err    error // returned once b is drained
+	broken bool  // whether the reader closed the pipe
+}
+
+func newHTTP2Pipe() *http2pipe {
+	p := new(http2pipe)
+	p.c.L = &p.mu
+	return p
+}
+
+// Read reads buffered data, waiting until some is available or the
+// writer closes the pipe.
+func (p *http2pipe) Read(d []byte) (n int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.b.Len() == 0 && p.err == nil {
+		p.c.Wait()
+	}
+	if p.b.Len() > 0 {
+		return p.b.Read(d)
+	}
+	return 0, p.err
+}
+
+// Write buffers d for the reader. Data written after the reader
+// closed the pipe is discarded.
+func (p *http2pipe) Write(d []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.broken && p.err == nil {
+		p.b.Write(d)
+		p.c.Signal()
+	}
+	return len(d), nil
+}
+
+// closeWithError makes Read return err after the buffered data has
+// been read.
+func (p *http2pipe) closeWithError(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+		p.c.Broadcast()
+	}
+	p.mu.Unlock()
+}
+
+// breakWithError discards the buffered data and makes Read return
+// err right away. It reports how many unread bytes were discarded.
+func (p *http2pipe) breakWithError(err error) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := p.b.Len()
+	p.b.Reset()
+	p.broken = true
+	if p.err == nil {
+		p.err = err
+	}
+	p.c.Broadcast()
+	return n
+}
+
+// http2connHeaders are the connection-specific header fields, which
+// HTTP/2 does not use.
+var http2connHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// http2validHeaderField reports whether f may appear in an HTTP/2
+// header block: its name must be a lowercase token, it must not be a
+// connection-specific field, and its value must not contain NUL, CR
+// or LF.
+func http2validHeaderField(f http2headerField) bool {
+	if f.Name == "" {
+		return false
+	}
+	for _, r := range f.Name {
+		if !isToken(r) || 'A' <= r && r <= 'Z' {
+			return false
+		}
+	}
+	if strings.ContainsAny(f.Value, "\x00\r\n") {
+		return false
+	}
+	for _, h := range http2connHeaders {
+		if strings.EqualFold(f.Name, h) {
+			return false
+		}
+	}
+	return f.Name != "te" || f.Value == "trailers"
+}
+
+// http2appendFields appends the fields of h, with lowercase names
+// and without the connection-specific ones, to fields.
+func http2appendFields(fields []http2headerField, h Header) []http2headerField {
+outer:
+	for k, vv := range h {
+		for _, c := range http2connHeaders {
+			if k == c {
+				continue outer
+			}
+		}
+		name := strings.ToLower(k)
+		if name == "te" || name == "host" {
+			continue
+		}
+		for _, v := range vv {
+			fields = append(fields, http2headerField{Name: name, Value: v})
+		}
+	}
+	return fields
+}
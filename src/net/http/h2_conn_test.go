@@ -0,0 +1,253 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// http2testPeer is the far end of an HTTP/2 connection under test. It
+// writes frames with fr and collects the frames it reads on frames.
+type http2testPeer struct {
+	t      *testing.T
+	nc     net.Conn
+	fr     *http2Framer
+	dec    *http2hpackDecoder
+	frames chan *http2Frame
+}
+
+func newHTTP2TestPeer(t *testing.T, nc net.Conn) *http2testPeer {
+	p := &http2testPeer{
+		t:      t,
+		nc:     nc,
+		fr:     newHTTP2Framer(bufio.NewWriter(nc), nc),
+		dec:    newHTTP2HpackDecoder(),
+		frames: make(chan *http2Frame, 100),
+	}
+	// The tests check the sizes of the frames they read.
+	p.fr.maxReadSize = http2maxFrameSize
+	go p.readFrames()
+	return p
+}
+
+func (p *http2testPeer) readFrames() {
+	defer close(p.frames)
+	for {
+		f, err := p.fr.ReadFrame()
+		if err != nil {
+			return
+		}
+		f.Payload = append([]byte(nil), f.Payload...)
+		p.frames <- f
+	}
+}
+
+// next returns the next frame read, skipping SETTINGS
+// acknowledgements and WINDOW_UPDATE frames. It returns nil if no
+// frame arrives within d.
+func (p *http2testPeer) next(d time.Duration) *http2Frame {
+	timeout := time.After(d)
+	for {
+		select {
+		case f, ok := <-p.frames:
+			if !ok {
+				return nil
+			}
+			if f.Type == http2FrameWindowUpdate || f.Type == http2FrameSettings && f.has(http2FlagAck) {
+				continue
+			}
+			return f
+		case <-timeout:
+			return nil
+		}
+	}
+}
+
+// expect returns the next frame, which must be of type typ.
+func (p *http2testPeer) expect(typ http2FrameType) *http2Frame {
+	f := p.next(5 * time.Second)
+	if f == nil {
+		p.t.Fatalf("no %v frame", typ)
+	}
+	if f.Type != typ {
+		p.t.Fatalf("got %v frame, flags %#x, stream %d; want %v", f.Type, f.Flags, f.StreamID, typ)
+	}
+	return f
+}
+
+// expectHeaders reads a header block, which may be split into a
+// HEADERS frame and CONTINUATION frames of at most maxFrameSize
+// bytes, and returns its first frame and its decoded fields.
+func (p *http2testPeer) expectHeaders(maxFrameSize int) (*http2Frame, []http2headerField) {
+	first := p.expect(http2FrameHeaders)
+	f := first
+	var block []byte
+	for {
+		if len(f.Payload) > maxFrameSize {
+			p.t.Fatalf("%v frame of %d bytes; max is %d", f.Type, len(f.Payload), maxFrameSize)
+		}
+		block = append(block, f.Payload...)
+		if f.has(http2FlagEndHeaders) {
+			break
+		}
+		f = p.expect(http2FrameContinuation)
+		if f.StreamID != first.StreamID || f.has(http2FlagEndStream) {
+			p.t.Fatalf("CONTINUATION on stream %d, flags %#x; want stream %d", f.StreamID, f.Flags, first.StreamID)
+		}
+	}
+	var fields []http2headerField
+	if err := p.dec.decode(block, func(f http2headerField) {
+		fields = append(fields, f)
+	}); err != nil {
+		p.t.Fatalf("decoding header block: %v", err)
+	}
+	return first, fields
+}
+
+// writeFrames calls fn and flushes what it wrote.
+func (p *http2testPeer) writeFrames(fn func(fr *http2Framer) error) {
+	if err := fn(p.fr); err != nil {
+		p.t.Fatal(err)
+	}
+	if err := p.fr.Flush(); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+// writeHeaders writes fields as a header block on the stream, split
+// into frames of at most maxFrameSize bytes.
+func (p *http2testPeer) writeHeaders(streamID uint32, endStream bool, maxFrameSize uint32, fields ...http2headerField) {
+	var block []byte
+	for _, f := range fields {
+		block = http2appendHeaderField(block, f)
+	}
+	p.writeFrames(func(fr *http2Framer) error {
+		return fr.WriteHeaderBlock(streamID, endStream, block, maxFrameSize)
+	})
+}
+
+// http2settingsFrame returns a SETTINGS frame as read from the wire.
+func http2settingsFrame(settings ...http2Setting) *http2Frame {
+	b := make([]byte, 6*len(settings))
+	for i, s := range settings {
+		binary.BigEndian.PutUint16(b[6*i:], uint16(s.ID))
+		binary.BigEndian.PutUint32(b[6*i+2:], s.Val)
+	}
+	return &http2Frame{Type: http2FrameSettings, Payload: b}
+}
+
+func newHTTP2TestConn(t *testing.T) (*http2conn, *http2testPeer) {
+	a, b := net.Pipe()
+	c := new(http2conn)
+	c.init(a, a, http2initialWindowSize)
+	return c, newHTTP2TestPeer(t, b)
+}
+
+// Tests that a header block larger than the peer's
+// SETTINGS_MAX_FRAME_SIZE is sent as a HEADERS frame followed by
+// CONTINUATION frames, none larger than that size.
+func TestHTTP2WriteHeadersContinuation(t *testing.T) {
+	c, p := newHTTP2TestConn(t)
+	defer c.fail(http2errClosedConn)
+	const maxFrame = 20000
+	noStreams := func(func(*http2flow)) {}
+	if err := c.handleSettings(http2settingsFrame(http2Setting{http2SettingMaxFrameSize, maxFrame}), noStreams, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := []http2headerField{
+		{Name: ":status", Value: "200"},
+		{Name: "x-big", Value: strings.Repeat("a", 3*maxFrame)},
+		{Name: "x-after", Value: "1"},
+	}
+	if err := c.writeHeaders(func() uint32 { return 5 }, fields, true); err != nil {
+		t.Fatal(err)
+	}
+	f, got := p.expectHeaders(maxFrame)
+	if f.StreamID != 5 || !f.has(http2FlagEndStream) || f.has(http2FlagEndHeaders) {
+		t.Errorf("HEADERS frame on stream %d, flags %#x; want stream 5 with END_STREAM and without END_HEADERS", f.StreamID, f.Flags)
+	}
+	if !reflect.DeepEqual(got, fields) {
+		t.Errorf("decoded %d fields; want the %d written", len(got), len(fields))
+	}
+}
+
+// Tests that writers blocked in writeData for lack of stream window
+// follow the changes of the peer's SETTINGS_INITIAL_WINDOW_SIZE,
+// including one that makes their windows negative.
+func TestHTTP2WriteDataInitialWindowChange(t *testing.T) {
+	c, p := newHTTP2TestConn(t)
+	defer c.fail(http2errClosedConn)
+	flows := []*http2flow{{send: c.peerInitWindow}, {send: c.peerInitWindow}}
+	streams := func(fn func(*http2flow)) {
+		for _, f := range flows {
+			fn(f)
+		}
+	}
+	setWindow := func(n uint32) {
+		if err := c.handleSettings(http2settingsFrame(http2Setting{http2SettingInitialWindowSize, n}), streams, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// expectData reads DATA frames until each stream has sent n
+	// more bytes, and reports whether END_STREAM was seen on each.
+	expectData := func(n int) (ended map[uint32]bool) {
+		ended = make(map[uint32]bool)
+		got := make(map[uint32]int)
+		for got[1] < n || got[3] < n {
+			f := p.expect(http2FrameData)
+			got[f.StreamID] += len(f.Payload)
+			if f.has(http2FlagEndStream) {
+				ended[f.StreamID] = true
+			}
+		}
+		if got[1] != n || got[3] != n {
+			t.Fatalf("streams sent %v bytes; want %d each", got, n)
+		}
+		return ended
+	}
+	expectBlocked := func() {
+		if f := p.next(50 * time.Millisecond); f != nil {
+			t.Fatalf("blocked writers sent a %v frame of %d bytes on stream %d", f.Type, len(f.Payload), f.StreamID)
+		}
+	}
+
+	setWindow(0)
+	errc := make(chan error, len(flows))
+	for i, f := range flows {
+		go func(id uint32, f *http2flow) {
+			errc <- c.writeData(f, id, make([]byte, 100), true)
+		}(uint32(2*i+1), f)
+	}
+	expectBlocked()
+
+	setWindow(60)
+	if ended := expectData(60); len(ended) != 0 {
+		t.Fatalf("streams %v ended early", ended)
+	}
+	expectBlocked()
+
+	// The windows go to -30; the writers must wait for 70 more.
+	setWindow(30)
+	expectBlocked()
+	setWindow(100)
+	if ended := expectData(40); !ended[1] || !ended[3] {
+		t.Errorf("streams ended: %v; want both", ended)
+	}
+	for range flows {
+		if err := <-errc; err != nil {
+			t.Errorf("writeData: %v", err)
+		}
+	}
+	if c.sendWindow != http2initialWindowSize-200 {
+		t.Errorf("connection send window = %d; want %d", c.sendWindow, http2initialWindowSize-200)
+	}
+}
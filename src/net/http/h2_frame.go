@@ -0,0 +1,504 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/2 framing. See RFC 7540.
+
+package http
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// http2ClientPreface is the string that must be sent by new
+// connections from clients.
+const http2ClientPreface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+const (
+	http2frameHeaderLen = 9
+
+	// http2initialMaxFrameSize is the initial value of
+	// SETTINGS_MAX_FRAME_SIZE, and the largest frame payload
+	// either side sends.
+	http2initialMaxFrameSize = 16384
+
+	// http2maxFrameSize is the largest value allowed for
+	// SETTINGS_MAX_FRAME_SIZE.
+	http2maxFrameSize = 1<<24 - 1
+
+	// http2initialWindowSize is the initial flow control window of
+	// connections and streams.
+	http2initialWindowSize = 65535
+
+	// http2maxWindowSize is the largest flow control window.
+	http2maxWindowSize = 1<<31 - 1
+
+	// http2initialHeaderTableSize is the initial size of the HPACK
+	// dynamic table.
+	http2initialHeaderTableSize = 4096
+)
+
+// An http2FrameType is the type of an HTTP/2 frame.
+type http2FrameType uint8
+
+const (
+	http2FrameData         http2FrameType = 0x0
+	http2FrameHeaders      http2FrameType = 0x1
+	http2FramePriority     http2FrameType = 0x2
+	http2FrameRSTStream    http2FrameType = 0x3
+	http2FrameSettings     http2FrameType = 0x4
+	http2FramePushPromise  http2FrameType = 0x5
+	http2FramePing         http2FrameType = 0x6
+	http2FrameGoAway       http2FrameType = 0x7
+	http2FrameWindowUpdate http2FrameType = 0x8
+	http2FrameContinuation http2FrameType = 0x9
+)
+
+var http2frameName = map[http2FrameType]string{
+	http2FrameData:         "DATA",
+	http2FrameHeaders:      "HEADERS",
+	http2FramePriority:     "PRIORITY",
+	http2FrameRSTStream:    "RST_STREAM",
+	http2FrameSettings:     "SETTINGS",
+	http2FramePushPromise:  "PUSH_PROMISE",
+	http2FramePing:         "PING",
+	http2FrameGoAway:       "GOAWAY",
+	http2FrameWindowUpdate: "WINDOW_UPDATE",
+	http2FrameContinuation: "CONTINUATION",
+}
+
+func (t http2FrameType) String() string {
+	if s, ok := http2frameName[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("UNKNOWN_FRAME_TYPE_%d", uint8(t))
+}
+
+// Frame flags. Their meaning depends on the frame type.
+const (
+	http2FlagEndStream  = 0x1  // DATA, HEADERS
+	http2FlagAck        = 0x1  // SETTINGS, PING
+	http2FlagEndHeaders = 0x4  // HEADERS, CONTINUATION
+	http2FlagPadded     = 0x8  // DATA, HEADERS
+	http2FlagPriority   = 0x20 // HEADERS
+)
+
+// An http2SettingID identifies a parameter in a SETTINGS frame.
+type http2SettingID uint16
+
+const (
+	http2SettingHeaderTableSize      http2SettingID = 0x1
+	http2SettingEnablePush           http2SettingID = 0x2
+	http2SettingMaxConcurrentStreams http2SettingID = 0x3
+	http2SettingInitialWindowSize    http2SettingID = 0x4
+	http2SettingMaxFrameSize         http2SettingID = 0x5
+	http2SettingMaxHeaderListSize    http2SettingID = 0x6
+)
+
+// An http2Setting is a setting parameter: which setting it is, and
+// its value.
+type http2Setting struct {
+	ID  http2SettingID
+	Val uint32
+}
+
+// valid reports whether the setting value is in range.
+func (s http2Setting) valid() error {
+	switch s.ID {
+	case http2SettingEnablePush:
+		if s.Val != 0 && s.Val != 1 {
+			return http2ConnectionError(http2ErrCodeProtocol)
+		}
+	case http2SettingInitialWindowSize:
+		if s.Val > http2maxWindowSize {
+			return http2ConnectionError(http2ErrCodeFlowControl)
+		}
+	case http2SettingMaxFrameSize:
+		if s.Val < http2initialMaxFrameSize || s.Val > http2maxFrameSize {
+			return http2ConnectionError(http2ErrCodeProtocol)
+		}
+	}
+	return nil
+}
+
+// An http2ErrCode is an unsigned 32-bit error code as defined in
+// section 7 of the HTTP/2 spec.
+type http2ErrCode uint32
+
+const (
+	http2ErrCodeNo                 http2ErrCode = 0x0
+	http2ErrCodeProtocol           http2ErrCode = 0x1
+	http2ErrCodeInternal           http2ErrCode = 0x2
+	http2ErrCodeFlowControl        http2ErrCode = 0x3
+	http2ErrCodeSettingsTimeout    http2ErrCode = 0x4
+	http2ErrCodeStreamClosed       http2ErrCode = 0x5
+	http2ErrCodeFrameSize          http2ErrCode = 0x6
+	http2ErrCodeRefusedStream      http2ErrCode = 0x7
+	http2ErrCodeCancel             http2ErrCode = 0x8
+	http2ErrCodeCompression        http2ErrCode = 0x9
+	http2ErrCodeConnect            http2ErrCode = 0xa
+	http2ErrCodeEnhanceYourCalm    http2ErrCode = 0xb
+	http2ErrCodeInadequateSecurity http2ErrCode = 0xc
+	http2ErrCodeHTTP11Required     http2ErrCode = 0xd
+)
+
+var http2errCodeName = map[http2ErrCode]string{
+	http2ErrCodeNo:                 "NO_ERROR",
+	http2ErrCodeProtocol:           "PROTOCOL_ERROR",
+	http2ErrCodeInternal:           "INTERNAL_ERROR",
+	http2ErrCodeFlowControl:        "FLOW_CONTROL_ERROR",
+	http2ErrCodeSettingsTimeout:    "SETTINGS_TIMEOUT",
+	http2ErrCodeStreamClosed:       "STREAM_CLOSED",
+	http2ErrCodeFrameSize:          "FRAME_SIZE_ERROR",
+	http2ErrCodeRefusedStream:      "REFUSED_STREAM",
+	http2ErrCodeCancel:             "CANCEL",
+	http2ErrCodeCompression:        "COMPRESSION_ERROR",
+	http2ErrCodeConnect:            "CONNECT_ERROR",
+	http2ErrCodeEnhanceYourCalm:    "ENHANCE_YOUR_CALM",
+	http2ErrCodeInadequateSecurity: "INADEQUATE_SECURITY",
+	http2ErrCodeHTTP11Required:     "HTTP_1_1_REQUIRED",
+}
+
+func (e http2ErrCode) String() string {
+	if s, ok := http2errCodeName[e]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown error code 0x%x", uint32(e))
+}
+
+// http2ConnectionError is an error that results in the termination
+// of the entire connection.
+type http2ConnectionError http2ErrCode
+
+func (e http2ConnectionError) Error() string {
+	return fmt.Sprintf("http2: connection error: %v", http2ErrCode(e))
+}
+
+// http2StreamError is an error that only affects one stream within
+// an HTTP/2 connection.
+type http2StreamError struct {
+	StreamID uint32
+	Code     http2ErrCode
+}
+
+func (e http2StreamError) Error() string {
+	return fmt.Sprintf("http2: stream error: stream ID %d; %v", e.StreamID, e.Code)
+}
+
+// An http2Frame is a frame read from the wire. Its Payload is only
+// valid until the next call to ReadFrame.
+type http2Frame struct {
+	Type     http2FrameType
+	Flags    uint8
+	StreamID uint32
+	Payload  []byte
+}
+
+func (f *http2Frame) has(flag uint8) bool {
+	return f.Flags&flag != 0
+}
+
+// An http2Framer reads and writes frames.
+type http2Framer struct {
+	r           io.Reader
+	maxReadSize uint32
+	rhdr        [http2frameHeaderLen]byte
+	rbuf        []byte
+
+	w    *bufio.Writer
+	whdr [http2frameHeaderLen]byte
+}
+
+func newHTTP2Framer(w *bufio.Writer, r io.Reader) *http2Framer {
+	return &http2Framer{
+		r:           r,
+		w:           w,
+		maxReadSize: http2initialMaxFrameSize,
+	}
+}
+
+// ReadFrame reads a single frame. Frames larger than the maximum
+// frame size we advertised are a connection error.
+func (fr *http2Framer) ReadFrame() (*http2Frame, error) {
+	if _, err := io.ReadFull(fr.r, fr.rhdr[:]); err != nil {
+		return nil, err
+	}
+	h := fr.rhdr[:]
+	n := uint32(h[0])<<16 | uint32(h[1])<<8 | uint32(h[2])
+	if n > fr.maxReadSize {
+		return nil, http2ConnectionError(http2ErrCodeFrameSize)
+	}
+	if uint32(cap(fr.rbuf)) < n {
+		fr.rbuf = make([]byte, n)
+	}
+	payload := fr.rbuf[:n]
+	if _, err := io.ReadFull(fr.r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return &http2Frame{
+		Type:     http2FrameType(h[3]),
+		Flags:    h[4],
+		StreamID: binary.BigEndian.Uint32(h[5:]) & (1<<31 - 1),
+		Payload:  payload,
+	}, nil
+}
+
+// WriteFrame writes a frame whose payload is the concatenation of
+// payload. The frame is buffered; callers must Flush.
+func (fr *http2Framer) WriteFrame(typ http2FrameType, flags uint8, streamID uint32, payload ...[]byte) error {
+	n := 0
+	for _, p := range payload {
+		n += len(p)
+	}
+	h := fr.whdr[:]
+	h[0], h[1], h[2] = byte(n>>16), byte(n>>8), byte(n)
+	h[3] = byte(typ)
+	h[4] = flags
+	binary.BigEndian.PutUint32(h[5:], streamID&(1<<31-1))
+	if _, err := fr.w.Write(h); err != nil {
+		return err
+	}
+	for _, p := range payload {
+		if _, err := fr.w.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered frames to the underlying connection.
+func (fr *http2Framer) Flush() error {
+	return fr.w.Flush()
+}
+
+// WriteSettings writes a SETTINGS frame with the given settings.
+func (fr *http2Framer) WriteSettings(settings ...http2Setting) error {
+	b := make([]byte, 6*len(settings))
+	for i, s := range settings {
+		binary.BigEndian.PutUint16(b[6*i:], uint16(s.ID))
+		binary.BigEndian.PutUint32(b[6*i+2:], s.Val)
+	}
+	return fr.WriteFrame(http2FrameSettings, 0, 0, b)
+}
+
+// WriteWindowUpdate writes a WINDOW_UPDATE frame granting the peer
+// incr more bytes on the stream, or on the connection if streamID is
+// zero.
+func (fr *http2Framer) WriteWindowUpdate(streamID, incr uint32) error {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], incr)
+	return fr.WriteFrame(http2FrameWindowUpdate, 0, streamID, b[:])
+}
+
+// WriteRSTStream writes a RST_STREAM frame.
+func (fr *http2Framer) WriteRSTStream(streamID uint32, code http2ErrCode) error {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(code))
+	return fr.WriteFrame(http2FrameRSTStream, 0, streamID, b[:])
+}
+
+// WriteGoAway writes a GOAWAY frame.
+func (fr *http2Framer) WriteGoAway(lastStreamID uint32, code http2ErrCode) error {
+	var b [8]byte
+	binary.BigEndian.PutUint32(b[:], lastStreamID&(1<<31-1))
+	binary.BigEndian.PutUint32(b[4:], uint32(code))
+	return fr.WriteFrame(http2FrameGoAway, 0, 0, b[:])
+}
+
+// WriteHeaderBlock writes the encoded header block as a HEADERS frame
+// followed by as many CONTINUATION frames as needed.
+func (fr *http2Framer) WriteHeaderBlock(streamID uint32, endStream bool, block []byte, maxFrameSize uint32) error {
+	var flags uint8
+	if endStream {
+		flags |= http2FlagEndStream
+	}
+	typ := http2FrameHeaders
+	for {
+		frag := block
+		if uint32(len(frag)) > maxFrameSize {
+			frag = frag[:maxFrameSize]
+		}
+		block = block[len(frag):]
+		if len(block) == 0 {
+			flags |= http2FlagEndHeaders
+		}
+		if err := fr.WriteFrame(typ, flags, streamID, frag); err != nil {
+			return err
+		}
+		if len(block) == 0 {
+			return nil
+		}
+		typ, flags = http2FrameContinuation, 0
+	}
+}
+
+var http2errFramePadding = http2ConnectionError(http2ErrCodeProtocol)
+
+// http2unpad removes the padding from the payload of a DATA or
+// HEADERS frame with the PADDED flag.
+func http2unpad(f *http2Frame) ([]byte, error) {
+	p := f.Payload
+	if !f.has(http2FlagPadded) {
+		return p, nil
+	}
+	if len(p) == 0 || int(p[0]) >= len(p) {
+		return nil, http2errFramePadding
+	}
+	return p[1 : len(p)-int(p[0])], nil
+}
+
+// http2headerFragment returns the header block fragment carried by a
+// HEADERS frame, skipping its padding and priority fields.
+func http2headerFragment(f *http2Frame) ([]byte, error) {
+	p, err := http2unpad(f)
+	if err != nil {
+		return nil, err
+	}
+	if f.has(http2FlagPriority) {
+		if len(p) < 5 {
+			return nil, http2ConnectionError(http2ErrCodeFrameSize)
+		}
+		if binary.BigEndian.Uint32(p)&(1<<31-1) == f.StreamID {
+			return nil, http2StreamError{f.StreamID, http2ErrCodeProtocol}
+		}
+		p = p[5:]
+	}
+	return p, nil
+}
+
+// http2parseSettings calls fn for each setting in a SETTINGS frame.
+func http2parseSettings(f *http2Frame, fn func(http2Setting) error) error {
+	if f.StreamID != 0 {
+		return http2ConnectionError(http2ErrCodeProtocol)
+	}
+	if f.has(http2FlagAck) {
+		if len(f.Payload) != 0 {
+			return http2ConnectionError(http2ErrCodeFrameSize)
+		}
+		return nil
+	}
+	if len(f.Payload)%6 != 0 {
+		return http2ConnectionError(http2ErrCodeFrameSize)
+	}
+	for p := f.Payload; len(p) > 0; p = p[6:] {
+		s := http2Setting{
+			ID:  http2SettingID(binary.BigEndian.Uint16(p)),
+			Val: binary.BigEndian.Uint32(p[2:]),
+		}
+		if err := s.valid(); err != nil {
+			return err
+		}
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// http2parseWindowUpdate returns the increment of a WINDOW_UPDATE
+// frame.
+func http2parseWindowUpdate(f *http2Frame) (uint32, error) {
+	if len(f.Payload) != 4 {
+		return 0, http2ConnectionError(http2ErrCodeFrameSize)
+	}
+	incr := binary.BigEndian.Uint32(f.Payload) & (1<<31 - 1)
+	if incr == 0 {
+		if f.StreamID == 0 {
+			return 0, http2ConnectionError(http2ErrCodeProtocol)
+		}
+		return 0, http2StreamError{f.StreamID, http2ErrCodeProtocol}
+	}
+	return incr, nil
+}
+
+// http2parseRSTStream returns the error code of a RST_STREAM frame.
+func http2parseRSTStream(f *http2Frame) (http2ErrCode, error) {
+	if len(f.Payload) != 4 {
+		return 0, http2ConnectionError(http2ErrCodeFrameSize)
+	}
+	if f.StreamID == 0 {
+		return 0, http2ConnectionError(http2ErrCodeProtocol)
+	}
+	return http2ErrCode(binary.BigEndian.Uint32(f.Payload)), nil
+}
+
+// http2parseGoAway returns the last stream ID and error code of a
+// GOAWAY frame.
+func http2parseGoAway(f *http2Frame) (lastStreamID uint32, code http2ErrCode, err error) {
+	if f.StreamID != 0 {
+		return 0, 0, http2ConnectionError(http2ErrCodeProtocol)
+	}
+	if len(f.Payload) < 8 {
+		return 0, 0, http2ConnectionError(http2ErrCodeFrameSize)
+	}
+	return binary.BigEndian.Uint32(f.Payload) & (1<<31 - 1), http2ErrCode(binary.BigEndian.Uint32(f.Payload[4:])), nil
+}
+
+// http2checkPing validates a PING frame.
+func http2checkPing(f *http2Frame) error {
+	if f.StreamID != 0 {
+		return http2ConnectionError(http2ErrCodeProtocol)
+	}
+	if len(f.Payload) != 8 {
+		return http2ConnectionError(http2ErrCodeFrameSize)
+	}
+	return nil
+}
+
+// http2checkPriority validates a PRIORITY frame. Priorities are
+// otherwise ignored.
+func http2checkPriority(f *http2Frame) error {
+	if f.StreamID == 0 {
+		return http2ConnectionError(http2ErrCodeProtocol)
+	}
+	if len(f.Payload) != 5 {
+		return http2StreamError{f.StreamID, http2ErrCodeFrameSize}
+	}
+	return nil
+}
+
+// http2headerBlock accumulates the fragments of a header block
+// spread over a HEADERS frame and its CONTINUATION frames.
+type http2headerBlock struct {
+	streamID  uint32
+	endStream bool
+	buf       []byte
+}
+
+// http2readHeaderBlock reads the CONTINUATION frames that complete
+// the header block started by the HEADERS frame f and returns the
+// whole block. No other frames may be interleaved, and blocks larger
+// than maxSize end the connection.
+func http2readHeaderBlock(fr *http2Framer, f *http2Frame, maxSize int) (*http2headerBlock, error) {
+	frag, err := http2headerFragment(f)
+	if err != nil {
+		return nil, err
+	}
+	hb := &http2headerBlock{
+		streamID:  f.StreamID,
+		endStream: f.has(http2FlagEndStream),
+		buf:       append([]byte(nil), frag...),
+	}
+	if len(hb.buf) > maxSize {
+		return nil, http2ConnectionError(http2ErrCodeEnhanceYourCalm)
+	}
+	for !f.has(http2FlagEndHeaders) {
+		if f, err = fr.ReadFrame(); err != nil {
+			return nil, err
+		}
+		if f.Type != http2FrameContinuation || f.StreamID != hb.streamID {
+			return nil, http2ConnectionError(http2ErrCodeProtocol)
+		}
+		if len(hb.buf)+len(f.Payload) > maxSize {
+			return nil, http2ConnectionError(http2ErrCodeEnhanceYourCalm)
+		}
+		hb.buf = append(hb.buf, f.Payload...)
+	}
+	return hb, nil
+}
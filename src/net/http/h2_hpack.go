@@ -0,0 +1,382 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HPACK header compression for HTTP/2. See RFC 7541.
+
+package http
+
+import (
+	"errors"
+	"sync"
+)
+
+// An http2headerField is a name-value pair. Sensitive fields are
+// never added to a dynamic table by encoders.
+type http2headerField struct {
+	Name, Value string
+	Sensitive   bool
+}
+
+// size returns the size of an entry in the dynamic table, as defined
+// in section 4.1 of RFC 7541.
+func (f http2headerField) size() uint32 {
+	return uint32(len(f.Name) + len(f.Value) + 32)
+}
+
+// http2errHpack is returned for header blocks that cannot be
+// decoded. The decoder state is then lost, so this ends the
+// connection.
+var http2errHpack = http2ConnectionError(http2ErrCodeCompression)
+
+// http2dynamicTable is the HPACK dynamic table, oldest entry first.
+type http2dynamicTable struct {
+	ents    []http2headerField
+	size    uint32
+	maxSize uint32
+}
+
+func (t *http2dynamicTable) add(f http2headerField) {
+	t.ents = append(t.ents, f)
+	t.size += f.size()
+	t.evict()
+}
+
+func (t *http2dynamicTable) setMaxSize(n uint32) {
+	t.maxSize = n
+	t.evict()
+}
+
+// evict removes the oldest entries until the table fits in maxSize.
+func (t *http2dynamicTable) evict() {
+	n := 0
+	for t.size > t.maxSize && n < len(t.ents) {
+		t.size -= t.ents[n].size()
+		n++
+	}
+	if n > 0 {
+		t.ents = append(t.ents[:0], t.ents[n:]...)
+	}
+}
+
+// An http2hpackDecoder decodes header blocks.
+type http2hpackDecoder struct {
+	dyn http2dynamicTable
+
+	// maxTableSize is the largest dynamic table size the peer's
+	// encoder may select.
+	maxTableSize uint32
+}
+
+func newHTTP2HpackDecoder() *http2hpackDecoder {
+	d := &http2hpackDecoder{maxTableSize: http2initialHeaderTableSize}
+	d.dyn.maxSize = http2initialHeaderTableSize
+	return d
+}
+
+// at returns the header field at the index i of the combined static
+// and dynamic tables.
+func (d *http2hpackDecoder) at(i uint64) (http2headerField, bool) {
+	if i == 0 {
+		return http2headerField{}, false
+	}
+	if i <= uint64(len(http2staticTable)) {
+		return http2staticTable[i-1], true
+	}
+	j := i - uint64(len(http2staticTable)) - 1
+	if j >= uint64(len(d.dyn.ents)) {
+		return http2headerField{}, false
+	}
+	return d.dyn.ents[len(d.dyn.ents)-1-int(j)], true
+}
+
+// decode decodes the header block p and calls emit for each header
+// field in order.
+func (d *http2hpackDecoder) decode(p []byte, emit func(http2headerField)) error {
+	sawField := false
+	for len(p) > 0 {
+		var err error
+		b := p[0]
+		switch {
+		case b&0x80 != 0:
+			// Indexed header field.
+			var i uint64
+			if i, p, err = http2readVarInt(7, p); err != nil {
+				return err
+			}
+			f, ok := d.at(i)
+			if !ok {
+				return http2errHpack
+			}
+			emit(f)
+		case b&0xc0 == 0x40:
+			// Literal header field with incremental indexing.
+			p, err = d.literal(p, 6, true, false, emit)
+		case b&0xf0 == 0x00:
+			// Literal header field without indexing.
+			p, err = d.literal(p, 4, false, false, emit)
+		case b&0xf0 == 0x10:
+			// Literal header field never indexed.
+			p, err = d.literal(p, 4, false, true, emit)
+		default:
+			// Dynamic table size update, only allowed
+			// before the first header field.
+			if sawField {
+				return http2errHpack
+			}
+			var size uint64
+			if size, p, err = http2readVarInt(5, p); err != nil {
+				return err
+			}
+			if size > uint64(d.maxTableSize) {
+				return http2errHpack
+			}
+			d.dyn.setMaxSize(uint32(size))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		sawField = true
+	}
+	return nil
+}
+
+func (d *http2hpackDecoder) literal(p []byte, n uint, index, sensitive bool, emit func(http2headerField)) ([]byte, error) {
+	i, p, err := http2readVarInt(n, p)
+	if err != nil {
+		return nil, err
+	}
+	var f http2headerField
+	if i > 0 {
+		if f, err = d.nameAt(i); err != nil {
+			return nil, err
+		}
+	} else if f.Name, p, err = http2readString(p); err != nil {
+		return nil, err
+	}
+	if f.Value, p, err = http2readString(p); err != nil {
+		return nil, err
+	}
+	f.Sensitive = sensitive
+	if index {
+		d.dyn.add(f)
+	}
+	emit(f)
+	return p, nil
+}
+
+func (d *http2hpackDecoder) nameAt(i uint64) (http2headerField, error) {
+	f, ok := d.at(i)
+	if !ok {
+		return http2headerField{}, http2errHpack
+	}
+	return http2headerField{Name: f.Name}, nil
+}
+
+// http2readVarInt reads an integer with an n-bit prefix from the
+// start of p, as described in section 5.1 of RFC 7541.
+func http2readVarInt(n uint, p []byte) (uint64, []byte, error) {
+	if len(p) == 0 {
+		return 0, nil, http2errHpack
+	}
+	max := uint64(1)<<n - 1
+	i := uint64(p[0]) & max
+	p = p[1:]
+	if i < max {
+		return i, p, nil
+	}
+	for shift := uint(0); len(p) > 0; shift += 7 {
+		if shift > 28 {
+			return 0, nil, http2errHpack
+		}
+		b := p[0]
+		p = p[1:]
+		i += uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return i, p, nil
+		}
+	}
+	return 0, nil, http2errHpack
+}
+
+// http2readString reads a string literal from the start of p.
+func http2readString(p []byte) (string, []byte, error) {
+	if len(p) == 0 {
+		return "", nil, http2errHpack
+	}
+	huff := p[0]&0x80 != 0
+	n, p, err := http2readVarInt(7, p)
+	if err != nil {
+		return "", nil, err
+	}
+	if uint64(len(p)) < n {
+		return "", nil, http2errHpack
+	}
+	s, p := p[:n], p[n:]
+	if !huff {
+		return string(s), p, nil
+	}
+	v, err := http2huffmanDecode(s)
+	if err != nil {
+		return "", nil, http2errHpack
+	}
+	return v, p, nil
+}
+
+// http2appendVarInt appends i encoded with an n-bit prefix to dst.
+// The bits of first above the prefix are kept in the first octet.
+func http2appendVarInt(dst []byte, n uint, first byte, i uint64) []byte {
+	max := uint64(1)<<n - 1
+	if i < max {
+		return append(dst, first|byte(i))
+	}
+	dst = append(dst, first|byte(max))
+	for i -= max; i >= 0x80; i >>= 7 {
+		dst = append(dst, byte(0x80|i&0x7f))
+	}
+	return append(dst, byte(i))
+}
+
+// http2appendString appends s as a string literal, Huffman coded if
+// that is shorter.
+func http2appendString(dst []byte, s string) []byte {
+	if n := http2huffmanEncodeLength(s); n < len(s) {
+		dst = http2appendVarInt(dst, 7, 0x80, uint64(n))
+		return http2appendHuffmanString(dst, s)
+	}
+	dst = http2appendVarInt(dst, 7, 0, uint64(len(s)))
+	return append(dst, s...)
+}
+
+var (
+	http2staticIndexOnce sync.Once
+	http2staticByName    map[string]uint64
+	http2staticByField   map[http2headerField]uint64
+)
+
+func http2buildStaticIndex() {
+	http2staticByName = make(map[string]uint64)
+	http2staticByField = make(map[http2headerField]uint64)
+	for i, f := range http2staticTable {
+		if _, ok := http2staticByName[f.Name]; !ok {
+			http2staticByName[f.Name] = uint64(i + 1)
+		}
+		http2staticByField[f] = uint64(i + 1)
+	}
+}
+
+// http2appendHeaderField appends the encoding of f to dst. Fields
+// are never added to the dynamic table, so the encoder needs no
+// state and works with any table size the peer allows.
+func http2appendHeaderField(dst []byte, f http2headerField) []byte {
+	http2staticIndexOnce.Do(http2buildStaticIndex)
+	if !f.Sensitive {
+		if i, ok := http2staticByField[http2headerField{Name: f.Name, Value: f.Value}]; ok {
+			return http2appendVarInt(dst, 7, 0x80, i)
+		}
+	}
+	var first byte // without indexing
+	if f.Sensitive {
+		first = 0x10 // never indexed
+	}
+	if i, ok := http2staticByName[f.Name]; ok {
+		dst = http2appendVarInt(dst, 4, first, i)
+	} else {
+		dst = append(dst, first)
+		dst = http2appendString(dst, f.Name)
+	}
+	return http2appendString(dst, f.Value)
+}
+
+var http2errInvalidHuffman = errors.New("http2: invalid Huffman-encoded data")
+
+// An http2huffmanNode is a node of the tree used to decode Huffman
+// coded strings.
+type http2huffmanNode struct {
+	next [2]*http2huffmanNode
+	sym  byte
+	leaf bool
+}
+
+var (
+	http2huffmanOnce sync.Once
+	http2huffmanRoot *http2huffmanNode
+)
+
+func http2buildHuffmanTree() {
+	root := new(http2huffmanNode)
+	for sym, code := range http2huffmanCodes {
+		n := root
+		for i := int(http2huffmanCodeLen[sym]) - 1; i >= 0; i-- {
+			bit := code >> uint(i) & 1
+			if n.next[bit] == nil {
+				n.next[bit] = new(http2huffmanNode)
+			}
+			n = n.next[bit]
+		}
+		n.sym = byte(sym)
+		n.leaf = true
+	}
+	http2huffmanRoot = root
+}
+
+// http2huffmanDecode decodes the Huffman coded string p. The string
+// must end with at most 7 bits of padding taken from the EOS symbol,
+// and must not contain the EOS symbol itself.
+func http2huffmanDecode(p []byte) (string, error) {
+	http2huffmanOnce.Do(http2buildHuffmanTree)
+	buf := make([]byte, 0, len(p)*8/5)
+	n := http2huffmanRoot
+	pad := 0 // bits read since the last symbol
+	ones := true
+	for _, b := range p {
+		for i := 7; i >= 0; i-- {
+			bit := b >> uint(i) & 1
+			if n = n.next[bit]; n == nil {
+				return "", http2errInvalidHuffman
+			}
+			pad++
+			ones = ones && bit == 1
+			if n.leaf {
+				buf = append(buf, n.sym)
+				n = http2huffmanRoot
+				pad, ones = 0, true
+			}
+		}
+	}
+	if pad > 7 || !ones {
+		return "", http2errInvalidHuffman
+	}
+	return string(buf), nil
+}
+
+// http2huffmanEncodeLength returns the number of octets needed to
+// Huffman code s.
+func http2huffmanEncodeLength(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		n += int(http2huffmanCodeLen[s[i]])
+	}
+	return (n + 7) / 8
+}
+
+// http2appendHuffmanString appends s Huffman coded to dst.
+func http2appendHuffmanString(dst []byte, s string) []byte {
+	var x uint64
+	n := uint(0) // bits in x not yet appended
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		x = x<<http2huffmanCodeLen[c] | uint64(http2huffmanCodes[c])
+		n += uint(http2huffmanCodeLen[c])
+		for n >= 8 {
+			n -= 8
+			dst = append(dst, byte(x>>n))
+		}
+	}
+	if n > 0 {
+		// Pad with the most significant bits of EOS.
+		dst = append(dst, byte(x<<(8-n))|byte(0xff>>n))
+	}
+	return dst
+}
@@ -0,0 +1,282 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func dehex(s string) []byte {
+	b, err := hex.DecodeString(strings.Replace(s, " ", "", -1))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+var http2VarIntTests = []struct {
+	n   uint
+	i   uint64
+	enc string
+}{
+	// RFC 7541, section C.1.
+	{5, 10, "0a"},
+	{5, 1337, "1f9a0a"},
+	{8, 42, "2a"},
+	{7, 127, "7f00"},
+	{4, 15, "0f00"},
+}
+
+func TestHTTP2VarInt(t *testing.T) {
+	for _, tt := range http2VarIntTests {
+		enc := http2appendVarInt(nil, tt.n, 0, tt.i)
+		if got := hex.EncodeToString(enc); got != tt.enc {
+			t.Errorf("appendVarInt(%d, %d) = %s; want %s", tt.n, tt.i, got, tt.enc)
+		}
+		i, rest, err := http2readVarInt(tt.n, append(enc, 0xff))
+		if err != nil || i != tt.i || len(rest) != 1 {
+			t.Errorf("readVarInt(%d, %s) = %d, %x, %v; want %d", tt.n, tt.enc, i, rest, err, tt.i)
+		}
+	}
+	if _, _, err := http2readVarInt(5, dehex("1f 9a")); err == nil {
+		t.Error("readVarInt accepted a truncated integer")
+	}
+}
+
+var http2HuffmanTests = []struct {
+	s, enc string
+}{
+	// RFC 7541, section C.4.
+	{"www.example.com", "f1e3c2e5f23a6ba0ab90f4ff"},
+	{"no-cache", "a8eb10649cbf"},
+	{"custom-key", "25a849e95ba97d7f"},
+	{"custom-value", "25a849e95bb8e8b4bf"},
+	{"", ""},
+}
+
+func TestHTTP2Huffman(t *testing.T) {
+	for _, tt := range http2HuffmanTests {
+		enc := http2appendHuffmanString(nil, tt.s)
+		if got := hex.EncodeToString(enc); got != tt.enc {
+			t.Errorf("huffman(%q) = %s; want %s", tt.s, got, tt.enc)
+		}
+		if n := http2huffmanEncodeLength(tt.s); n != len(enc) {
+			t.Errorf("huffmanEncodeLength(%q) = %d; want %d", tt.s, n, len(enc))
+		}
+		s, err := http2huffmanDecode(enc)
+		if err != nil || s != tt.s {
+			t.Errorf("huffmanDecode(%s) = %q, %v; want %q", tt.enc, s, err, tt.s)
+		}
+	}
+	var all []byte
+	for i := 0; i < 256; i++ {
+		all = append(all, byte(i))
+	}
+	s, err := http2huffmanDecode(http2appendHuffmanString(nil, string(all)))
+	if err != nil || s != string(all) {
+		t.Errorf("all octets did not round trip: %q, %v", s, err)
+	}
+	// Padding must be a prefix of EOS, at most 7 bits long.
+	for _, bad := range []string{"f1e3c2e5f23a6ba0ab90f4fe", "f1e3c2e5f23a6ba0ab90f4ffff"} {
+		if _, err := http2huffmanDecode(dehex(bad)); err == nil {
+			t.Errorf("huffmanDecode(%s) succeeded; want error", bad)
+		}
+	}
+}
+
+func TestHTTP2HpackDecode(t *testing.T) {
+	// RFC 7541, section C.4: requests with Huffman coding, all
+	// decoded with the same decoder.
+	blocks := []struct {
+		enc       string
+		fields    []http2headerField
+		tableSize uint32
+	}{
+		{
+			"8286 8441 8cf1 e3c2 e5f2 3a6b a0ab 90f4 ff",
+			[]http2headerField{
+				{Name: ":method", Value: "GET"},
+				{Name: ":scheme", Value: "http"},
+				{Name: ":path", Value: "/"},
+				{Name: ":authority", Value: "www.example.com"},
+			},
+			57,
+		},
+		{
+			"8286 84be 5886 a8eb 1064 9cbf",
+			[]http2headerField{
+				{Name: ":method", Value: "GET"},
+				{Name: ":scheme", Value: "http"},
+				{Name: ":path", Value: "/"},
+				{Name: ":authority", Value: "www.example.com"},
+				{Name: "cache-control", Value: "no-cache"},
+			},
+			110,
+		},
+		{
+			"8287 85bf 4088 25a8 49e9 5ba9 7d7f 8925 a849 e95b b8e8 b4bf",
+			[]http2headerField{
+				{Name: ":method", Value: "GET"},
+				{Name: ":scheme", Value: "https"},
+				{Name: ":path", Value: "/index.html"},
+				{Name: ":authority", Value: "www.example.com"},
+				{Name: "custom-key", Value: "custom-value"},
+			},
+			164,
+		},
+	}
+	d := newHTTP2HpackDecoder()
+	for i, b := range blocks {
+		var got []http2headerField
+		err := d.decode(dehex(b.enc), func(f http2headerField) {
+			got = append(got, f)
+		})
+		if err != nil {
+			t.Fatalf("block %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, b.fields) {
+			t.Errorf("block %d = %v; want %v", i, got, b.fields)
+		}
+		if d.dyn.size != b.tableSize {
+			t.Errorf("block %d: table size = %d; want %d", i, d.dyn.size, b.tableSize)
+		}
+	}
+}
+
+func TestHTTP2HpackDecodeErrors(t *testing.T) {
+	for _, enc := range []string{
+		"be",         // index beyond both tables
+		"80",         // index 0
+		"41 8c f1e3", // truncated string
+		"82 3f e11f", // table size update after a field
+	} {
+		err := newHTTP2HpackDecoder().decode(dehex(enc), func(http2headerField) {})
+		if err != http2errHpack {
+			t.Errorf("decode(%s) = %v; want %v", enc, err, http2errHpack)
+		}
+	}
+}
+
+func TestHTTP2HpackRoundTrip(t *testing.T) {
+	fields := []http2headerField{
+		{Name: ":status", Value: "200"},
+		{Name: ":status", Value: "418"},
+		{Name: "content-type", Value: "text/plain; charset=utf-8"},
+		{Name: "x-custom", Value: strings.Repeat("v", 300)},
+		{Name: "authorization", Value: "secret", Sensitive: true},
+		{Name: "accept-encoding", Value: "gzip, deflate"},
+		{Name: "empty", Value: ""},
+	}
+	var enc []byte
+	for _, f := range fields {
+		enc = http2appendHeaderField(enc, f)
+	}
+	d := newHTTP2HpackDecoder()
+	var got []http2headerField
+	if err := d.decode(enc, func(f http2headerField) { got = append(got, f) }); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, fields) {
+		t.Errorf("got %v; want %v", got, fields)
+	}
+	if len(d.dyn.ents) != 0 {
+		t.Errorf("encoder added %d entries to the dynamic table", len(d.dyn.ents))
+	}
+}
+
+func TestHTTP2Framer(t *testing.T) {
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	fr := newHTTP2Framer(bw, &buf)
+	block := bytes.Repeat([]byte("h"), 40)
+	if err := fr.WriteHeaderBlock(3, true, block, 16); err != nil {
+		t.Fatal(err)
+	}
+	if err := fr.WriteFrame(http2FrameData, http2FlagEndStream, 3, []byte("hel"), []byte("lo")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fr.WriteSettings(http2Setting{http2SettingInitialWindowSize, 1 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Type != http2FrameHeaders || f.StreamID != 3 || f.has(http2FlagEndHeaders) {
+		t.Fatalf("first frame = %v, flags %#x, stream %d", f.Type, f.Flags, f.StreamID)
+	}
+	hb, err := http2readHeaderBlock(fr, f, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(hb.buf, block) || !hb.endStream || hb.streamID != 3 {
+		t.Errorf("header block = %q, endStream %v, stream %d", hb.buf, hb.endStream, hb.streamID)
+	}
+
+	f, err = fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Type != http2FrameData || !f.has(http2FlagEndStream) || string(f.Payload) != "hello" {
+		t.Errorf("data frame = %v, flags %#x, payload %q", f.Type, f.Flags, f.Payload)
+	}
+
+	f, err = fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var settings []http2Setting
+	if err := http2parseSettings(f, func(s http2Setting) error {
+		settings = append(settings, s)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []http2Setting{{http2SettingInitialWindowSize, 1 << 20}}; !reflect.DeepEqual(settings, want) {
+		t.Errorf("settings = %v; want %v", settings, want)
+	}
+}
+
+func TestHTTP2FramerErrors(t *testing.T) {
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	fr := newHTTP2Framer(bw, &buf)
+	fr.WriteFrame(http2FrameData, 0, 1, make([]byte, http2initialMaxFrameSize+1))
+	fr.Flush()
+	if _, err := fr.ReadFrame(); err != http2ConnectionError(http2ErrCodeFrameSize) {
+		t.Errorf("oversized frame: err = %v", err)
+	}
+
+	buf.Reset()
+	fr.WriteHeaderBlock(1, false, make([]byte, 20), 10)
+	fr.Flush()
+	f, _ := fr.ReadFrame()
+	if _, err := http2readHeaderBlock(fr, f, 15); err != http2ConnectionError(http2ErrCodeEnhanceYourCalm) {
+		t.Errorf("oversized header block: err = %v", err)
+	}
+
+	buf.Reset()
+	fr.WriteFrame(http2FrameHeaders, 0, 1, make([]byte, 10))
+	fr.WriteFrame(http2FramePing, 0, 0, make([]byte, 8))
+	fr.Flush()
+	f, _ = fr.ReadFrame()
+	if _, err := http2readHeaderBlock(fr, f, 100); err != http2ConnectionError(http2ErrCodeProtocol) {
+		t.Errorf("interleaved header block: err = %v", err)
+	}
+
+	bad := &http2Frame{Type: http2FrameSettings, Payload: []byte{0, 4, 0x80, 0, 0, 0}}
+	if err := http2parseSettings(bad, func(http2Setting) error { return nil }); err != http2ConnectionError(http2ErrCodeFlowControl) {
+		t.Errorf("window size setting too large: err = %v", err)
+	}
+}
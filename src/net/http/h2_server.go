@@ -0,0 +1,797 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/2 server.
+
+package http
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// http2maxConcurrentStreams is the number of streams a client
+	// may have open on one connection.
+	http2maxConcurrentStreams = 250
+
+	// http2responseBufferSize is how much response body is
+	// buffered before it is sent as DATA frames.
+	http2responseBufferSize = 4 << 10
+)
+
+// http2ServeConn serves HTTP/2 on c, a TLS connection on which "h2"
+// was negotiated, calling h for each request. It is the default
+// TLSNextProto function for "h2" and returns when the connection is
+// done.
+func http2ServeConn(srv *Server, c *tls.Conn, h Handler) {
+	// The deadlines set for the TLS handshake would otherwise end
+	// the connection while it is idle between requests.
+	c.SetDeadline(time.Time{})
+
+	state := c.ConnectionState()
+	sc := &http2serverConn{
+		srv:        srv,
+		handler:    h,
+		tlsState:   &state,
+		remoteAddr: c.RemoteAddr().String(),
+		br:         bufio.NewReader(c),
+		dec:        newHTTP2HpackDecoder(),
+		streams:    make(map[uint32]*http2serverStream),
+	}
+	sc.init(c, sc.br, http2initialWindowSize)
+	sc.serve()
+}
+
+// An http2serverConn is the server end of an HTTP/2 connection. Its
+// read loop runs in the goroutine that called http2ServeConn, and
+// each request is handled in a goroutine of its own.
+type http2serverConn struct {
+	http2conn
+	srv        *Server
+	handler    Handler
+	tlsState   *tls.ConnectionState
+	remoteAddr string
+	br         *bufio.Reader
+
+	// Owned by the read loop.
+	dec  *http2hpackDecoder
+	nreq int // number of requests received
+
+	// idleTimer, if non-nil, shuts the connection down once it has
+	// had no open streams for the server's idle timeout. It is
+	// set before frames are read and reset with mu held.
+	idleTimer *time.Timer
+
+	// Guarded by mu.
+	streams     map[uint32]*http2serverStream
+	maxStreamID uint32 // highest stream ID opened by the client; written only by the read loop
+	goingAway   bool   // GOAWAY sent by shutdown; new streams are refused
+}
+
+// An http2serverStream is a request being served.
+type http2serverStream struct {
+	sc   *http2serverConn
+	id   uint32
+	flow http2flow // guarded by sc.mu
+
+	// body carries the request body. bodyOpen, guarded by sc.mu,
+	// reports whether the client may still send DATA frames.
+	body     *http2pipe
+	bodyOpen bool
+	declLen  int64 // Content-Length of the request, or -1
+	gotLen   int64 // request body bytes received
+
+	closeNotify chan bool
+	cancel      chan struct{} // the request's Cancel; nil once closed, guarded by sc.mu
+}
+
+// serve reads and processes frames until the connection fails.
+func (sc *http2serverConn) serve() {
+	err := sc.readLoop()
+	if ce, ok := err.(http2ConnectionError); ok {
+		sc.writeFrames(func(fr *http2Framer) error {
+			return fr.WriteGoAway(sc.maxStreamID, http2ErrCode(ce))
+		})
+	} else {
+		err = http2errClosedConn
+	}
+	sc.fail(err)
+	sc.srv.trackHTTP2Conn(sc, false)
+	if sc.idleTimer != nil {
+		sc.idleTimer.Stop()
+	}
+
+	sc.mu.Lock()
+	for _, st := range sc.streams {
+		sc.closeStreamLocked(st, err)
+	}
+	sc.mu.Unlock()
+}
+
+func (sc *http2serverConn) readLoop() error {
+	preface := make([]byte, len(http2ClientPreface))
+	if _, err := io.ReadFull(sc.br, preface); err != nil {
+		return err
+	}
+	if string(preface) != http2ClientPreface {
+		return http2ConnectionError(http2ErrCodeProtocol)
+	}
+	if sc.tlsState.Version < tls.VersionTLS12 {
+		return http2ConnectionError(http2ErrCodeInadequateSecurity)
+	}
+	err := sc.writeFrames(func(fr *http2Framer) error {
+		return fr.WriteSettings(
+			http2Setting{http2SettingMaxConcurrentStreams, http2maxConcurrentStreams},
+			http2Setting{http2SettingMaxHeaderListSize, uint32(sc.srv.maxHeaderBytes())},
+		)
+	})
+	if err != nil {
+		return err
+	}
+	if !sc.srv.trackHTTP2Conn(sc, true) {
+		go sc.shutdown()
+	}
+	if d := sc.srv.idleTimeout(); d != 0 {
+		sc.mu.Lock()
+		sc.idleTimer = time.AfterFunc(d, sc.shutdown)
+		sc.mu.Unlock()
+	}
+	for first := true; ; first = false {
+		f, err := sc.fr.ReadFrame()
+		if err != nil {
+			return err
+		}
+		if first && (f.Type != http2FrameSettings || f.has(http2FlagAck)) {
+			return http2ConnectionError(http2ErrCodeProtocol)
+		}
+		err = sc.processFrame(f)
+		if se, ok := err.(http2StreamError); ok {
+			sc.resetStream(se.StreamID, se.Code)
+			sc.mu.Lock()
+			if st := sc.streams[se.StreamID]; st != nil {
+				sc.closeStreamLocked(st, http2errStreamReset)
+			}
+			sc.mu.Unlock()
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// stream returns the open stream with the given ID. It reports an
+// error for frames on streams the client never opened.
+func (sc *http2serverConn) stream(id uint32) (*http2serverStream, error) {
+	if id == 0 || id > sc.maxStreamID {
+		return nil, http2ConnectionError(http2ErrCodeProtocol)
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.streams[id], nil
+}
+
+func (sc *http2serverConn) processFrame(f *http2Frame) error {
+	switch f.Type {
+	case http2FrameData:
+		return sc.processData(f)
+	case http2FrameHeaders:
+		return sc.processHeaders(f)
+	case http2FramePriority:
+		return http2checkPriority(f)
+	case http2FrameRSTStream:
+		if _, err := http2parseRSTStream(f); err != nil {
+			return err
+		}
+		st, err := sc.stream(f.StreamID)
+		if err != nil {
+			return err
+		}
+		if st != nil {
+			sc.mu.Lock()
+			sc.closeStreamLocked(st, http2errStreamReset)
+			sc.mu.Unlock()
+		}
+		return nil
+	case http2FrameSettings:
+		return sc.handleSettings(f, sc.eachFlow, nil)
+	case http2FramePushPromise:
+		// Clients cannot push.
+		return http2ConnectionError(http2ErrCodeProtocol)
+	case http2FramePing:
+		return sc.handlePing(f)
+	case http2FrameGoAway:
+		// The client will open no more streams; those in
+		// flight are still served.
+		_, _, err := http2parseGoAway(f)
+		return err
+	case http2FrameWindowUpdate:
+		var s *http2flow
+		if f.StreamID != 0 {
+			st, err := sc.stream(f.StreamID)
+			if err != nil {
+				return err
+			}
+			if st != nil {
+				s = &st.flow
+			}
+		}
+		return sc.handleWindowUpdate(f, s)
+	case http2FrameContinuation:
+		// CONTINUATION frames are read with their HEADERS.
+		return http2ConnectionError(http2ErrCodeProtocol)
+	}
+	// Unknown frame types are ignored.
+	return nil
+}
+
+// eachFlow calls fn for the flow control state of each open stream.
+// It is called with mu held.
+func (sc *http2serverConn) eachFlow(fn func(*http2flow)) {
+	for _, st := range sc.streams {
+		fn(&st.flow)
+	}
+}
+
+func (sc *http2serverConn) processData(f *http2Frame) error {
+	st, err := sc.stream(f.StreamID)
+	if err != nil {
+		return err
+	}
+	var s *http2flow
+	sc.mu.Lock()
+	if st != nil && st.bodyOpen {
+		s = &st.flow
+	}
+	sc.mu.Unlock()
+	data, err := sc.recvData(f, s)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		// Data for a stream that is closed, or whose request
+		// body is already complete.
+		if st != nil {
+			return http2StreamError{f.StreamID, http2ErrCodeStreamClosed}
+		}
+		return nil
+	}
+	st.gotLen += int64(len(data))
+	if st.declLen >= 0 && st.gotLen > st.declLen {
+		return http2StreamError{f.StreamID, http2ErrCodeProtocol}
+	}
+	st.body.Write(data)
+	if f.has(http2FlagEndStream) {
+		return sc.endRequestBody(st)
+	}
+	return nil
+}
+
+// endRequestBody marks the end of the request body of st.
+func (sc *http2serverConn) endRequestBody(st *http2serverStream) error {
+	if st.declLen >= 0 && st.gotLen != st.declLen {
+		return http2StreamError{st.id, http2ErrCodeProtocol}
+	}
+	sc.mu.Lock()
+	st.bodyOpen = false
+	sc.mu.Unlock()
+	st.body.closeWithError(io.EOF)
+	return nil
+}
+
+func (sc *http2serverConn) processHeaders(f *http2Frame) error {
+	id := f.StreamID
+	if id == 0 {
+		return http2ConnectionError(http2ErrCodeProtocol)
+	}
+	hb, err := http2readHeaderBlock(sc.fr, f, sc.srv.maxHeaderBytes())
+	if err != nil {
+		return err
+	}
+	// Every header block must be decoded to keep the decoder's
+	// table in step with the client's encoder.
+	var fields []http2headerField
+	size := 0
+	if err := sc.dec.decode(hb.buf, func(f http2headerField) {
+		size += int(f.size())
+		fields = append(fields, f)
+	}); err != nil {
+		return err
+	}
+
+	if id <= sc.maxStreamID {
+		// Trailers of a request body.
+		sc.mu.Lock()
+		st := sc.streams[id]
+		open := st != nil && st.bodyOpen
+		sc.mu.Unlock()
+		if !open {
+			if st == nil {
+				return http2ConnectionError(http2ErrCodeStreamClosed)
+			}
+			return http2StreamError{id, http2ErrCodeStreamClosed}
+		}
+		if !hb.endStream {
+			return http2StreamError{id, http2ErrCodeProtocol}
+		}
+		return sc.endRequestBody(st)
+	}
+	if id%2 != 1 {
+		return http2ConnectionError(http2ErrCodeProtocol)
+	}
+	sc.mu.Lock()
+	sc.maxStreamID = id
+	n := len(sc.streams)
+	goingAway := sc.goingAway
+	sc.mu.Unlock()
+	if goingAway || n >= http2maxConcurrentStreams {
+		return http2StreamError{id, http2ErrCodeRefusedStream}
+	}
+
+	st := &http2serverStream{
+		sc:          sc,
+		id:          id,
+		bodyOpen:    !hb.endStream,
+		declLen:     -1,
+		closeNotify: make(chan bool, 1),
+		cancel:      make(chan struct{}),
+	}
+	if size > sc.srv.maxHeaderBytes() {
+		return sc.writeHeaders(func() uint32 { return id }, []http2headerField{
+			{Name: ":status", Value: strconv.Itoa(statusRequestHeaderFieldsTooLarge)},
+		}, true)
+	}
+	req, ok := sc.newRequest(st, fields)
+	if !ok {
+		return http2StreamError{id, http2ErrCodeProtocol}
+	}
+	req.Cancel = st.cancel
+
+	sc.mu.Lock()
+	st.flow.send = sc.peerInitWindow
+	st.flow.recv = sc.initRecvWindow
+	sc.streams[id] = st
+	if sc.idleTimer != nil {
+		sc.idleTimer.Stop()
+	}
+	sc.mu.Unlock()
+	sc.nreq++
+	if sc.srv.requestLimitReached(sc.nreq) {
+		sc.shutdown()
+	}
+
+	rw := &http2responseWriter{
+		st:            st,
+		req:           req,
+		handlerHeader: make(Header),
+		contentLength: -1,
+	}
+	go sc.runHandler(rw, req)
+	return nil
+}
+
+// newRequest builds the Request carried by a HEADERS frame. It
+// reports false if the request is malformed.
+func (sc *http2serverConn) newRequest(st *http2serverStream, fields []http2headerField) (*Request, bool) {
+	var method, scheme, authority, path string
+	header := make(Header)
+	sawRegular := false
+	for _, f := range fields {
+		if strings.HasPrefix(f.Name, ":") {
+			var p *string
+			switch f.Name {
+			case ":method":
+				p = &method
+			case ":scheme":
+				p = &scheme
+			case ":authority":
+				p = &authority
+			case ":path":
+				p = &path
+			}
+			// Pseudo-header fields must be known, appear
+			// once and precede the regular fields.
+			if p == nil || *p != "" || f.Value == "" || sawRegular {
+				return nil, false
+			}
+			*p = f.Value
+			continue
+		}
+		sawRegular = true
+		if !http2validHeaderField(f) {
+			return nil, false
+		}
+		header.Add(CanonicalHeaderKey(f.Name), f.Value)
+	}
+	if method == "" {
+		return nil, false
+	}
+
+	// Cookies may be split into several fields for better
+	// compression.
+	if cookies := header["Cookie"]; len(cookies) > 1 {
+		header.Set("Cookie", strings.Join(cookies, "; "))
+	}
+
+	var u *url.URL
+	var requestURI string
+	if method == "CONNECT" {
+		if scheme != "" || path != "" || authority == "" {
+			return nil, false
+		}
+		u = &url.URL{Host: authority}
+		requestURI = authority
+	} else {
+		if scheme == "" || path == "" {
+			return nil, false
+		}
+		var err error
+		if u, err = url.ParseRequestURI(path); err != nil {
+			return nil, false
+		}
+		requestURI = path
+	}
+	host := authority
+	if host == "" {
+		host = header.get("Host")
+	}
+	delete(header, "Host")
+
+	req := &Request{
+		Method:     method,
+		URL:        u,
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		ProtoMinor: 0,
+		Header:     header,
+		Host:       host,
+		RemoteAddr: sc.remoteAddr,
+		RequestURI: requestURI,
+		TLS:        sc.tlsState,
+	}
+	if !st.bodyOpen {
+		req.Body = eofReader
+		return req, true
+	}
+	if cl := header.get("Content-Length"); cl != "" {
+		n, err := strconv.ParseInt(cl, 10, 64)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		st.declLen = n
+	}
+	req.ContentLength = st.declLen
+	st.body = newHTTP2Pipe()
+	req.Body = &http2requestBody{st: st}
+	return req, true
+}
+
+// closeStreamLocked ends st, making its pending writes and reads
+// fail with err. It is called with mu held.
+func (sc *http2serverConn) closeStreamLocked(st *http2serverStream, err error) {
+	if sc.streams[st.id] != st {
+		return
+	}
+	delete(sc.streams, st.id)
+	if st.flow.err == nil {
+		st.flow.err = err
+	}
+	st.bodyOpen = false
+	if st.body != nil {
+		st.body.breakWithError(err)
+	}
+	select {
+	case st.closeNotify <- true:
+	default:
+	}
+	st.cancelLocked()
+	sc.cond.Broadcast()
+	sc.closeIfDrainedLocked()
+}
+
+// finishStream is called once the response of st has been sent. If
+// the client is still sending the request body it is told to stop.
+func (sc *http2serverConn) finishStream(st *http2serverStream) {
+	sc.mu.Lock()
+	open := st.bodyOpen && sc.streams[st.id] == st
+	if sc.streams[st.id] == st {
+		delete(sc.streams, st.id)
+		st.bodyOpen = false
+		st.flow.err = http2errStreamReset
+		if st.body != nil {
+			st.body.breakWithError(http2errClosedBody)
+		}
+	}
+	st.cancelLocked()
+	sc.mu.Unlock()
+	if open {
+		sc.resetStream(st.id, http2ErrCodeNo)
+	}
+	sc.mu.Lock()
+	sc.closeIfDrainedLocked()
+	sc.mu.Unlock()
+}
+
+// cancelLocked closes the Cancel channel of st's request. It is called
+// with sc.mu held.
+func (st *http2serverStream) cancelLocked() {
+	if st.cancel != nil {
+		close(st.cancel)
+		st.cancel = nil
+	}
+}
+
+// shutdown tells the client that no new streams will be accepted and
+// closes the connection once the open streams are done.
+func (sc *http2serverConn) shutdown() {
+	sc.mu.Lock()
+	if sc.goingAway {
+		sc.mu.Unlock()
+		return
+	}
+	sc.goingAway = true
+	last := sc.maxStreamID
+	sc.mu.Unlock()
+	sc.writeFrames(func(fr *http2Framer) error {
+		return fr.WriteGoAway(last, http2ErrCodeNo)
+	})
+	sc.mu.Lock()
+	sc.closeIfDrainedLocked()
+	sc.mu.Unlock()
+}
+
+// closeIfDrainedLocked closes the connection if shutdown has begun
+// and no streams remain, and otherwise starts the idle timer once
+// the last stream ends. It is called with mu held.
+func (sc *http2serverConn) closeIfDrainedLocked() {
+	if len(sc.streams) != 0 || sc.err != nil {
+		return
+	}
+	if sc.goingAway {
+		go sc.fail(http2errClosedConn)
+	} else if sc.idleTimer != nil {
+		sc.idleTimer.Reset(sc.srv.idleTimeout())
+	}
+}
+
+// trackHTTP2Conn adds or removes sc from the connections told to go
+// away on Shutdown. It reports false if sc cannot be added because
+// the server is shutting down.
+func (srv *Server) trackHTTP2Conn(sc *http2serverConn, add bool) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if !add {
+		delete(srv.http2Conns, sc)
+		return true
+	}
+	if srv.shuttingDown() {
+		return false
+	}
+	if srv.http2Conns == nil {
+		srv.http2Conns = make(map[*http2serverConn]struct{})
+	}
+	srv.http2Conns[sc] = struct{}{}
+	return true
+}
+
+// shutdownHTTP2Conns is registered with RegisterOnShutdown by Serve.
+func (srv *Server) shutdownHTTP2Conns() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for sc := range srv.http2Conns {
+		go sc.shutdown()
+	}
+}
+
+// runHandler calls the handler for req and sends what remains of the
+// response.
+func (sc *http2serverConn) runHandler(rw *http2responseWriter, req *Request) {
+	defer func() {
+		if err := recover(); err != nil {
+			const size = 64 << 10
+			buf := make([]byte, size)
+			buf = buf[:runtime.Stack(buf, false)]
+			sc.srv.logf("http: panic serving %v: %v\n%s", sc.remoteAddr, err, buf)
+			sc.resetStream(rw.st.id, http2ErrCodeInternal)
+			sc.mu.Lock()
+			sc.closeStreamLocked(rw.st, http2errStreamReset)
+			sc.mu.Unlock()
+			return
+		}
+		rw.finish()
+		sc.finishStream(rw.st)
+	}()
+	sc.handler.ServeHTTP(rw, req)
+}
+
+// http2requestBody is the Body of a request received over HTTP/2.
+// Reading it returns flow control credit to the client.
+type http2requestBody struct {
+	st     *http2serverStream
+	closed bool
+}
+
+func (b *http2requestBody) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, http2errClosedBody
+	}
+	n, err := b.st.body.Read(p)
+	if n > 0 {
+		b.st.sc.consumed(&b.st.flow, b.st.id, int32(n))
+	}
+	return n, err
+}
+
+func (b *http2requestBody) Close() error {
+	if !b.closed {
+		b.closed = true
+		b.st.body.breakWithError(http2errClosedBody)
+	}
+	return nil
+}
+
+// http2responseWriter is the ResponseWriter of a request received
+// over HTTP/2.
+type http2responseWriter struct {
+	st            *http2serverStream
+	req           *Request
+	handlerHeader Header
+	header        Header // handlerHeader as of the WriteHeader call
+	status        int
+	wroteHeader   bool  // whether WriteHeader was called
+	sentHeader    bool  // whether the HEADERS frame was sent
+	handlerDone   bool  // whether the handler returned
+	contentLength int64 // declared by the handler, or -1
+	written       int64 // body bytes written by the handler
+	buf           []byte
+	err           error // sticky error from sending the response
+}
+
+func (w *http2responseWriter) Header() Header {
+	return w.handlerHeader
+}
+
+func (w *http2responseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		w.st.sc.srv.logf("http: multiple response.WriteHeader calls")
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	w.header = w.handlerHeader.clone()
+	if cl := w.header.get("Content-Length"); cl != "" {
+		v, err := strconv.ParseInt(cl, 10, 64)
+		if err == nil && v >= 0 {
+			w.contentLength = v
+		} else {
+			w.st.sc.srv.logf("http: invalid Content-Length of %q", cl)
+			w.header.Del("Content-Length")
+		}
+	}
+}
+
+func (w *http2responseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(StatusOK)
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	if !bodyAllowedForStatus(w.status) {
+		return 0, ErrBodyNotAllowed
+	}
+	w.written += int64(len(data))
+	if w.contentLength != -1 && w.written > w.contentLength {
+		return 0, ErrContentLength
+	}
+	if len(w.buf)+len(data) <= http2responseBufferSize {
+		w.buf = append(w.buf, data...)
+		return len(data), nil
+	}
+	if err := w.flushBuf(false); err != nil {
+		return 0, err
+	}
+	if len(data) < http2responseBufferSize {
+		w.buf = append(w.buf, data...)
+		return len(data), nil
+	}
+	if err := w.send(data, false); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Flush sends the response header and any buffered body.
+func (w *http2responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(StatusOK)
+	}
+	w.flushBuf(false)
+	if !w.sentHeader {
+		w.send(nil, false)
+	}
+}
+
+func (w *http2responseWriter) CloseNotify() <-chan bool {
+	return w.st.closeNotify
+}
+
+// finish sends what remains of the response once the handler has
+// returned.
+func (w *http2responseWriter) finish() {
+	if !w.wroteHeader {
+		w.WriteHeader(StatusOK)
+	}
+	w.handlerDone = true
+	w.flushBuf(true)
+}
+
+func (w *http2responseWriter) flushBuf(end bool) error {
+	if len(w.buf) == 0 && !end {
+		return nil
+	}
+	err := w.send(w.buf, end)
+	w.buf = w.buf[:0]
+	return err
+}
+
+// send writes p as response body, sending the header first if it
+// has not been sent. If end is set, the response is complete.
+func (w *http2responseWriter) send(p []byte, end bool) error {
+	if w.err != nil {
+		return w.err
+	}
+	sc := w.st.sc
+	isHEAD := w.req.Method == "HEAD"
+	if isHEAD {
+		p = nil
+	}
+	if !w.sentHeader {
+		w.sentHeader = true
+		fields := []http2headerField{{Name: ":status", Value: strconv.Itoa(w.status)}}
+		fields = http2appendFields(fields, w.responseHeader(p, isHEAD))
+		endStream := end && len(p) == 0
+		w.err = sc.writeHeaders(func() uint32 { return w.st.id }, fields, endStream)
+		if w.err != nil || endStream {
+			return w.err
+		}
+	}
+	if len(p) == 0 && !end {
+		return nil
+	}
+	w.err = sc.writeData(&w.st.flow, w.st.id, p, end)
+	return w.err
+}
+
+// responseHeader returns the header to send with a response whose
+// body starts with p.
+func (w *http2responseWriter) responseHeader(p []byte, isHEAD bool) Header {
+	header := w.header
+	if !bodyAllowedForStatus(w.status) {
+		for _, k := range suppressedHeaders(w.status) {
+			header.Del(k)
+		}
+	} else {
+		_, haveType := header["Content-Type"]
+		if !haveType && len(p) > 0 {
+			header.Set("Content-Type", DetectContentType(p))
+		}
+		// If the handler finished and wrote a small body in
+		// one go, its length is known.
+		if w.handlerDone && header.get("Content-Length") == "" && (!isHEAD || w.written > 0) {
+			header.Set("Content-Length", strconv.FormatInt(w.written, 10))
+		}
+	}
+	if _, ok := header["Date"]; !ok {
+		header.Set("Date", string(appendTime(nil, time.Now())))
+	}
+	return header
+}
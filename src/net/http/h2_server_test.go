@@ -0,0 +1,186 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// http2testCert returns a self-signed certificate for the tests that
+// speak TLS without net/http/httptest.
+func http2testCert(t *testing.T) tls.Certificate {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"Acme Co"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}
+}
+
+// An http2testServer serves a handler with HTTP/2 over TLS on a
+// loopback listener.
+type http2testServer struct {
+	ln net.Listener
+	wg sync.WaitGroup // counts the open connections
+}
+
+func newHTTP2TestServer(t *testing.T, h Handler) *http2testServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &http2testServer{ln: ln}
+	srv := &Server{
+		Handler: h,
+		ConnState: func(c net.Conn, state ConnState) {
+			switch state {
+			case StateNew:
+				ts.wg.Add(1)
+			case StateClosed, StateHijacked:
+				ts.wg.Done()
+			}
+		},
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{http2testCert(t)},
+		NextProtos:   []string{http2NextProtoTLS},
+	}
+	go srv.Serve(tls.NewListener(ln, config))
+	return ts
+}
+
+// Close stops the server and waits for the connections to it, which
+// the clients must have closed, to end.
+func (ts *http2testServer) Close() {
+	ts.ln.Close()
+	ts.wg.Wait()
+}
+
+// dial opens an HTTP/2 connection to ts and exchanges settings with
+// it.
+func (ts *http2testServer) dial(t *testing.T) *http2testPeer {
+	c, err := tls.Dial("tcp", ts.ln.Addr().String(), &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{http2NextProtoTLS},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proto := c.ConnectionState().NegotiatedProtocol; proto != http2NextProtoTLS {
+		c.Close()
+		t.Fatalf("negotiated protocol %q; want %q", proto, http2NextProtoTLS)
+	}
+	p := newHTTP2TestPeer(t, c)
+	p.writeFrames(func(fr *http2Framer) error {
+		if _, err := fr.w.WriteString(http2ClientPreface); err != nil {
+			return err
+		}
+		return fr.WriteSettings()
+	})
+	p.expect(http2FrameSettings)
+	return p
+}
+
+// writeGet opens the stream id with a GET request for path.
+func (p *http2testPeer) writeGet(id uint32, path string, extra ...http2headerField) {
+	fields := []http2headerField{
+		{Name: ":method", Value: "GET"},
+		{Name: ":scheme", Value: "https"},
+		{Name: ":authority", Value: "example.com"},
+		{Name: ":path", Value: path},
+	}
+	p.writeHeaders(id, true, http2initialMaxFrameSize, append(fields, extra...)...)
+}
+
+// Tests that the server reads request headers spread over a HEADERS
+// frame and CONTINUATION frames.
+func TestHTTP2ServerContinuation(t *testing.T) {
+	ts := newHTTP2TestServer(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, strconv.Itoa(len(r.Header.Get("X-Big"))))
+	}))
+	defer ts.Close()
+	p := ts.dial(t)
+	defer p.nc.Close()
+
+	const size = 2*http2initialMaxFrameSize + 100
+	p.writeGet(1, "/", http2headerField{Name: "x-big", Value: strings.Repeat("a", size)})
+	f, fields := p.expectHeaders(http2initialMaxFrameSize)
+	if f.StreamID != 1 || len(fields) == 0 || fields[0] != (http2headerField{Name: ":status", Value: "200"}) {
+		t.Fatalf("response on stream %d: %v", f.StreamID, fields)
+	}
+	if f = p.expect(http2FrameData); string(f.Payload) != strconv.Itoa(size) {
+		t.Errorf("handler saw an X-Big header of %s bytes; want %d", f.Payload, size)
+	}
+}
+
+// Tests that the server refuses streams beyond
+// http2maxConcurrentStreams and accepts new ones once the open
+// streams end.
+func TestHTTP2ServerRefusesStreams(t *testing.T) {
+	release := make(chan bool)
+	var releaseOnce sync.Once
+	releaseAll := func() { releaseOnce.Do(func() { close(release) }) }
+	ts := newHTTP2TestServer(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer releaseAll()
+	p := ts.dial(t)
+	defer p.nc.Close()
+
+	id := uint32(1)
+	for i := 0; i < http2maxConcurrentStreams; i++ {
+		p.writeGet(id, "/")
+		id += 2
+	}
+	p.writeGet(id, "/")
+	f := p.expect(http2FrameRSTStream)
+	if code, _ := http2parseRSTStream(f); f.StreamID != id || code != http2ErrCodeRefusedStream {
+		t.Fatalf("RST_STREAM on stream %d with %v; want stream %d with %v", f.StreamID, code, id, http2ErrCodeRefusedStream)
+	}
+
+	releaseAll()
+	for ended := 0; ended < http2maxConcurrentStreams; {
+		f := p.next(5 * time.Second)
+		if f == nil {
+			t.Fatalf("%d of %d streams ended", ended, http2maxConcurrentStreams)
+		}
+		if f.Type == http2FrameHeaders {
+			p.dec.decode(f.Payload, func(http2headerField) {})
+		}
+		if f.has(http2FlagEndStream) {
+			ended++
+		}
+	}
+	id += 2
+	p.writeGet(id, "/")
+	if f, fields := p.expectHeaders(http2initialMaxFrameSize); f.StreamID != id || fields[0].Value != "200" {
+		t.Errorf("response on stream %d: %v; want status 200 on stream %d", f.StreamID, fields, id)
+	}
+}
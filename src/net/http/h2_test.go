@@ -0,0 +1,487 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	. "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newHTTP2Server starts a TLS test server that offers HTTP/2.
+func newHTTP2Server(h Handler) *httptest.Server {
+	ts := httptest.NewUnstartedServer(h)
+	startHTTP2(ts)
+	return ts
+}
+
+// startHTTP2 starts ts, from NewUnstartedServer, with TLS and offering
+// HTTP/2.
+func startHTTP2(ts *httptest.Server) {
+	ts.TLS = &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+	ts.StartTLS()
+}
+
+// newHTTP2Transport returns a Transport that trusts ts and offers
+// HTTP/2.
+func newHTTP2Transport(t *testing.T, ts *httptest.Server) *Transport {
+	tr := newTLSTransport(t, ts)
+	tr.TLSClientConfig.NextProtos = []string{"h2", "http/1.1"}
+	return tr
+}
+
+func TestHTTP2Basic(t *testing.T) {
+	defer afterTest(t)
+	ts := newHTTP2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("X-Proto", r.Proto)
+		w.Header().Set("X-Host", r.Host)
+		fmt.Fprintf(w, "<html>%s %s</html>", r.Method, r.URL.RequestURI())
+	}))
+	defer ts.Close()
+	tr := newHTTP2Transport(t, ts)
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	res, err := c.Get(ts.URL + "/foo?bar=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Proto != "HTTP/2.0" || res.ProtoMajor != 2 {
+		t.Errorf("response proto = %q", res.Proto)
+	}
+	if res.TLS == nil || res.TLS.NegotiatedProtocol != "h2" {
+		t.Errorf("response TLS = %+v", res.TLS)
+	}
+	if got := res.Header.Get("X-Proto"); got != "HTTP/2.0" {
+		t.Errorf("request proto = %q", got)
+	}
+	if got, want := res.Header.Get("X-Host"), strings.TrimPrefix(ts.URL, "https://"); got != want {
+		t.Errorf("request host = %q; want %q", got, want)
+	}
+	if want := "<html>GET /foo?bar=1</html>"; string(body) != want {
+		t.Errorf("body = %q; want %q", body, want)
+	}
+	if got, want := res.Header.Get("Content-Type"), "text/html; charset=utf-8"; got != want {
+		t.Errorf("Content-Type = %q; want %q", got, want)
+	}
+	if res.ContentLength != int64(len(body)) {
+		t.Errorf("ContentLength = %d; want %d", res.ContentLength, len(body))
+	}
+	if res.Header.Get("Date") == "" {
+		t.Error("no Date header")
+	}
+}
+
+func TestHTTP2LargeBodies(t *testing.T) {
+	defer afterTest(t)
+	ts := newHTTP2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.ContentLength != -1 {
+			t.Errorf("request ContentLength = %d; want -1", r.ContentLength)
+		}
+		io.Copy(w, r.Body)
+	}))
+	defer ts.Close()
+	tr := newHTTP2Transport(t, ts)
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	// Larger than the initial flow control windows, in both
+	// directions.
+	want := bytes.Repeat([]byte("0123456789abcdef"), 3<<16)
+	res, err := c.Post(ts.URL, "application/octet-stream", struct{ io.Reader }{bytes.NewReader(want)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	got, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("echoed %d bytes; want %d", len(got), len(want))
+	}
+}
+
+func TestHTTP2SharedConn(t *testing.T) {
+	defer afterTest(t)
+	var (
+		mu    sync.Mutex
+		addrs = make(map[string]int)
+	)
+	ts := newHTTP2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		mu.Lock()
+		addrs[r.RemoteAddr]++
+		mu.Unlock()
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer ts.Close()
+	tr := newHTTP2Transport(t, ts)
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	// Prime the connection, then send requests at once.
+	res, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path := fmt.Sprintf("/%d", i)
+			res, err := c.Get(ts.URL + path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer res.Body.Close()
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil || string(body) != path {
+				t.Errorf("GET %s = %q, %v", path, body, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(addrs) != 1 {
+		t.Errorf("requests came over %d connections; want 1: %v", len(addrs), addrs)
+	}
+}
+
+// Tests that HTTP/2 is only spoken when both ends ask for it.
+func TestHTTP2OptIn(t *testing.T) {
+	defer afterTest(t)
+	handler := HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, r.Proto)
+	})
+	get := func(ts *httptest.Server, tr *Transport) string {
+		defer tr.CloseIdleConnections()
+		res, err := (&Client{Transport: tr}).Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Proto != string(body) {
+			t.Errorf("client proto %q, server proto %q", res.Proto, body)
+		}
+		return string(body)
+	}
+
+	ts := httptest.NewTLSServer(handler)
+	if got := get(ts, newHTTP2Transport(t, ts)); got != "HTTP/1.1" {
+		t.Errorf("default server: got %s", got)
+	}
+	ts.Close()
+
+	ts = newHTTP2Server(handler)
+	defer ts.Close()
+	if got := get(ts, newTLSTransport(t, ts)); got != "HTTP/1.1" {
+		t.Errorf("default client: got %s", got)
+	}
+	if got := get(ts, newHTTP2Transport(t, ts)); got != "HTTP/2.0" {
+		t.Errorf("client and server with HTTP/2: got %s", got)
+	}
+}
+
+func TestHTTP2Gzip(t *testing.T) {
+	defer afterTest(t)
+	const msg = "hello, hello, hello, hello"
+	ts := newHTTP2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if ae := r.Header.Get("Accept-Encoding"); ae != "gzip" {
+			t.Errorf("Accept-Encoding = %q", ae)
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		io.WriteString(zw, msg)
+		zw.Close()
+	}))
+	defer ts.Close()
+	tr := newHTTP2Transport(t, ts)
+	defer tr.CloseIdleConnections()
+
+	res, err := (&Client{Transport: tr}).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != msg {
+		t.Errorf("body = %q; want %q", body, msg)
+	}
+	if res.Header.Get("Content-Encoding") != "" || res.ContentLength != -1 {
+		t.Errorf("Content-Encoding = %q, ContentLength = %d", res.Header.Get("Content-Encoding"), res.ContentLength)
+	}
+}
+
+func TestHTTP2HeadAndStatus(t *testing.T) {
+	defer afterTest(t)
+	ts := newHTTP2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		switch r.URL.Path {
+		case "/head":
+			io.WriteString(w, "12345")
+		case "/nocontent":
+			w.WriteHeader(StatusNoContent)
+		case "/notfound":
+			NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	tr := newHTTP2Transport(t, ts)
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	res, err := c.Head(ts.URL + "/head")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 200 || res.ContentLength != 5 {
+		t.Errorf("HEAD: status %d, ContentLength %d", res.StatusCode, res.ContentLength)
+	}
+	if body, _ := ioutil.ReadAll(res.Body); len(body) != 0 {
+		t.Errorf("HEAD: body %q", body)
+	}
+	res.Body.Close()
+
+	for path, code := range map[string]int{"/nocontent": 204, "/notfound": 404} {
+		res, err := c.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != code || res.Status != fmt.Sprintf("%d %s", code, StatusText(code)) {
+			t.Errorf("GET %s: status %q", path, res.Status)
+		}
+		if code == 204 && len(body) != 0 {
+			t.Errorf("GET %s: body %q", path, body)
+		}
+	}
+}
+
+// Tests that a client closing a response body early, or canceling
+// its request, resets only that stream.
+func TestHTTP2StreamCancel(t *testing.T) {
+	defer afterTest(t)
+	gone := make(chan bool, 2)
+	ts := newHTTP2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/" {
+			io.WriteString(w, "ok")
+			return
+		}
+		w.(Flusher).Flush()
+		select {
+		case <-w.(CloseNotifier).CloseNotify():
+			gone <- true
+		case <-time.After(5 * time.Second):
+			gone <- false
+		}
+	}))
+	defer ts.Close()
+	tr := newHTTP2Transport(t, ts)
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	res, err := c.Get(ts.URL + "/close")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if !<-gone {
+		t.Error("handler not notified of closed body")
+	}
+
+	req, _ := NewRequest("GET", ts.URL+"/cancel", nil)
+	res, err = c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.CancelRequest(req)
+	if _, err := ioutil.ReadAll(res.Body); err == nil {
+		t.Error("read of canceled response succeeded")
+	}
+	res.Body.Close()
+	if !<-gone {
+		t.Error("handler not notified of canceled request")
+	}
+
+	res, err = c.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil || string(body) != "ok" {
+		t.Errorf("request after resets = %q, %v", body, err)
+	}
+}
+
+// Tests that Shutdown lets a request in flight on an HTTP/2
+// connection finish and then closes the connection.
+func TestHTTP2Shutdown(t *testing.T) {
+	defer afterTest(t)
+	inHandler := make(chan bool)
+	release := make(chan bool)
+	ts := newHTTP2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		inHandler <- true
+		<-release
+		io.WriteString(w, "done")
+	}))
+	defer ts.Close()
+	tr := newHTTP2Transport(t, ts)
+	defer tr.CloseIdleConnections()
+
+	type result struct {
+		body string
+		err  error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		res, err := (&Client{Transport: tr}).Get(ts.URL)
+		if err != nil {
+			resc <- result{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		resc <- result{string(body), err}
+	}()
+	<-inHandler
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- ts.Config.Shutdown(time.Now().Add(5 * time.Second)) }()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	if r := <-resc; r.err != nil || r.body != "done" {
+		t.Errorf("in-flight request = %q, %v", r.body, r.err)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown = %v", err)
+	}
+}
+
+// Tests that Request.Cancel cancels an HTTP/2 request, and that the
+// server's handler sees its own Request.Cancel closed.
+func TestHTTP2RequestCancelChan(t *testing.T) {
+	defer afterTest(t)
+	canceled := make(chan bool, 1)
+	ts := newHTTP2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.(Flusher).Flush()
+		select {
+		case <-r.Cancel:
+			canceled <- true
+		case <-time.After(5 * time.Second):
+			canceled <- false
+		}
+	}))
+	defer ts.Close()
+	tr := newHTTP2Transport(t, ts)
+	defer tr.CloseIdleConnections()
+
+	cancel := make(chan struct{})
+	req, _ := NewRequest("GET", ts.URL, nil)
+	req.Cancel = cancel
+	res, err := (&Client{Transport: tr}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ProtoMajor != 2 {
+		t.Fatalf("response proto = %q", res.Proto)
+	}
+	close(cancel)
+	if _, err := ioutil.ReadAll(res.Body); err == nil || err.Error() != "net/http: request canceled" {
+		t.Errorf("reading body: %v; want request canceled", err)
+	}
+	res.Body.Close()
+	if !<-canceled {
+		t.Error("handler's Request.Cancel not closed")
+	}
+}
+
+// Tests that an HTTP/2 connection is told to go away after
+// MaxRequestsPerConn requests, and closed once idle for IdleTimeout.
+func TestHTTP2ConnLimits(t *testing.T) {
+	defer afterTest(t)
+	ts := httptest.NewUnstartedServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, r.RemoteAddr)
+	}))
+	ts.Config.MaxRequestsPerConn = 2
+	ts.Config.IdleTimeout = 200 * time.Millisecond
+	startHTTP2(ts)
+	defer ts.Close()
+	tr := newHTTP2Transport(t, ts)
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	get := func() string {
+		res, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.ProtoMajor != 2 {
+			t.Fatalf("response proto = %q", res.Proto)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		return string(body)
+	}
+	a0, a1, a2 := get(), get(), get()
+	if a0 != a1 || a1 == a2 {
+		t.Errorf("remote addrs = %q, %q, %q; want the first two the same and the third new", a0, a1, a2)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if a3 := get(); a3 == a2 {
+		t.Errorf("request after idle timeout reused the connection from %s", a3)
+	}
+}
+
+// Tests request and response headers too large for one frame, which
+// are sent with CONTINUATION frames.
+func TestHTTP2LargeHeaders(t *testing.T) {
+	defer afterTest(t)
+	big := strings.Repeat("a", 40<<10)
+	ts := newHTTP2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("X-Big", r.Header.Get("X-Big"))
+	}))
+	defer ts.Close()
+	tr := newHTTP2Transport(t, ts)
+	defer tr.CloseIdleConnections()
+
+	req, _ := NewRequest("GET", ts.URL, nil)
+	req.Header.Set("X-Big", big)
+	res, err := (&Client{Transport: tr}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.ProtoMajor != 2 {
+		t.Fatalf("response proto = %q", res.Proto)
+	}
+	if got := res.Header.Get("X-Big"); got != big {
+		t.Errorf("X-Big response header of %d bytes; want %d", len(got), len(big))
+	}
+}
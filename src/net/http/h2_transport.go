@@ -0,0 +1,760 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/2 client.
+
+package http
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// http2clientStreamWindow is the receive window of each
+	// stream opened by the client.
+	http2clientStreamWindow = 1 << 20
+
+	// http2maxResponseHeaderBytes bounds the size of response
+	// header blocks.
+	http2maxResponseHeaderBytes = 10 << 20
+)
+
+// http2errClientConnUnusable is returned by RoundTrip when the
+// connection takes no new requests; the server did not process the
+// request, which may be retried on another connection.
+var http2errClientConnUnusable = errors.New("http2: client connection not usable")
+
+// http2errGoAwayBody is returned by RoundTrip in place of
+// http2errClientConnUnusable for requests with a body, which cannot
+// be sent again once it has been read from.
+var http2errGoAwayBody = errors.New("http2: server sent GOAWAY before processing the request; its body cannot be sent again")
+
+// An http2clientConn is the client end of an HTTP/2 connection. All
+// requests to an origin share it; each is sent on a stream of its
+// own.
+type http2clientConn struct {
+	http2conn
+	t        *Transport
+	tlsState *tls.ConnectionState
+	br       *bufio.Reader
+
+	dec *http2hpackDecoder // owned by the read loop
+
+	// Guarded by mu.
+	streams       map[uint32]*http2clientStream
+	nextStreamID  uint32
+	active        int    // streams open or about to be opened
+	maxConcurrent uint32 // the server's SETTINGS_MAX_CONCURRENT_STREAMS
+	goAway        bool   // whether the server sent GOAWAY
+	closing       bool   // whether to close once no streams are active
+	closed        bool   // whether the read loop has ended
+	onClosed      func() // called once the read loop has ended, or nil
+}
+
+// An http2clientStream is a request sent over an http2clientConn.
+type http2clientStream struct {
+	cc            *http2clientConn
+	req           *Request
+	requestedGzip bool
+	resc          chan responseAndError // receives the response header or an error
+	done          chan struct{}         // closed when the stream ends, if req can be canceled
+
+	// Guarded by cc.mu.
+	id       uint32
+	flow     http2flow
+	open     bool // whether the stream is in cc.streams
+	sentRes  bool // whether a value was sent on resc
+	reqDone  bool // whether the request was fully sent
+	bodyOpen bool // whether the server may still send response body
+
+	// Owned by the read loop; body is only changed with cc.mu held.
+	gotHeader bool
+	body      *http2pipe // response body
+	declLen   int64      // Content-Length of the response, or -1
+	gotLen    int64      // response body bytes received
+}
+
+// newHTTP2ClientConn starts HTTP/2 on c, a TLS connection on which
+// "h2" was negotiated.
+func (t *Transport) newHTTP2ClientConn(c *tls.Conn) (*http2clientConn, error) {
+	state := c.ConnectionState()
+	cc := &http2clientConn{
+		t:             t,
+		tlsState:      &state,
+		br:            bufio.NewReader(c),
+		dec:           newHTTP2HpackDecoder(),
+		streams:       make(map[uint32]*http2clientStream),
+		nextStreamID:  1,
+		maxConcurrent: 1000, // until the server says otherwise
+	}
+	cc.init(c, cc.br, http2clientStreamWindow)
+	err := cc.writeFrames(func(fr *http2Framer) error {
+		if _, err := cc.bw.WriteString(http2ClientPreface); err != nil {
+			return err
+		}
+		return fr.WriteSettings(
+			http2Setting{http2SettingEnablePush, 0},
+			http2Setting{http2SettingInitialWindowSize, http2clientStreamWindow},
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+	go cc.readLoop()
+	return cc, nil
+}
+
+// setOnClosed arranges for f to be called once cc is closed, or calls
+// it at once if cc is already closed.
+func (cc *http2clientConn) setOnClosed(f func()) {
+	cc.mu.Lock()
+	closed := cc.closed
+	cc.onClosed = f
+	cc.mu.Unlock()
+	if closed {
+		f()
+	}
+}
+
+// canTakeNewRequest reports whether new requests may be sent on cc.
+func (cc *http2clientConn) canTakeNewRequest() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.usableLocked()
+}
+
+func (cc *http2clientConn) usableLocked() bool {
+	return cc.err == nil && !cc.goAway && !cc.closing && cc.nextStreamID < 1<<31
+}
+
+// closeIfIdle closes cc once it has no active streams, and stops it
+// from taking new requests.
+func (cc *http2clientConn) closeIfIdle() {
+	cc.mu.Lock()
+	cc.closing = true
+	idle := cc.active == 0
+	cc.cond.Broadcast()
+	cc.mu.Unlock()
+	if idle {
+		cc.fail(http2errClosedConn)
+	}
+}
+
+// reserveStream waits until the server allows another stream.
+func (cc *http2clientConn) reserveStream() error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for {
+		if cc.err != nil {
+			return cc.err
+		}
+		if !cc.usableLocked() {
+			return http2errClientConnUnusable
+		}
+		if uint32(cc.active) < cc.maxConcurrent {
+			cc.active++
+			return nil
+		}
+		cc.cond.Wait()
+	}
+}
+
+// openStream assigns the next stream ID to cs. It is called with wmu
+// held, just before the request's HEADERS frame is written.
+func (cc *http2clientConn) openStream(cs *http2clientStream) uint32 {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cs.id = cc.nextStreamID
+	cc.nextStreamID += 2
+	cs.flow.send = cc.peerInitWindow
+	cs.flow.recv = http2clientStreamWindow
+	cs.open = true
+	cs.bodyOpen = true
+	cc.streams[cs.id] = cs
+	return cs.id
+}
+
+// endStreamLocked removes cs from the active streams, delivering err
+// to the caller of RoundTrip if it has not received the response
+// yet. It is called with mu held.
+func (cc *http2clientConn) endStreamLocked(cs *http2clientStream, err error) {
+	if !cs.open {
+		return
+	}
+	cs.open = false
+	delete(cc.streams, cs.id)
+	cc.active--
+	if cs.done != nil {
+		close(cs.done)
+	}
+	if cs.flow.err == nil {
+		cs.flow.err = err
+	}
+	cs.bodyOpen = false
+	if cs.body != nil {
+		cs.body.breakWithError(err)
+	}
+	if !cs.sentRes {
+		cs.sentRes = true
+		cs.resc <- responseAndError{err: err}
+	}
+	cc.cond.Broadcast()
+	if cc.closing && cc.active == 0 {
+		go cc.fail(http2errClosedConn)
+	}
+}
+
+// abort ends cs before the server finished it, telling the server
+// with RST_STREAM.
+func (cs *http2clientStream) abort(err error) {
+	cc := cs.cc
+	cc.mu.Lock()
+	wasOpen := cs.open
+	cc.endStreamLocked(cs, err)
+	cc.mu.Unlock()
+	if wasOpen {
+		cc.resetStream(cs.id, http2ErrCodeCancel)
+	}
+}
+
+// watchCancel aborts cs when its request's Cancel channel is closed
+// or its Deadline passes before the stream ends.
+func (cs *http2clientStream) watchCancel() {
+	deadlinec, stopTimer := cs.req.deadlineTimer()
+	defer stopTimer()
+	select {
+	case <-cs.req.Cancel:
+		cs.abort(errRequestCanceled)
+	case <-deadlinec:
+		cs.abort(errDeadline)
+	case <-cs.done:
+	}
+}
+
+// RoundTrip sends req on a new stream and waits for the response
+// header.
+func (cc *http2clientConn) RoundTrip(req *Request) (*Response, error) {
+	if err := cc.reserveStream(); err != nil {
+		return nil, err
+	}
+	cs := &http2clientStream{
+		cc:      cc,
+		req:     req,
+		resc:    make(chan responseAndError, 1),
+		declLen: -1,
+	}
+	if req.Cancel != nil || !req.Deadline.IsZero() {
+		cs.done = make(chan struct{})
+	}
+	hasBody := req.Body != nil
+	fields := cs.requestFields()
+	err := cc.writeHeaders(func() uint32 { return cc.openStream(cs) }, fields, !hasBody)
+	if err != nil {
+		cc.mu.Lock()
+		if cs.open {
+			cc.endStreamLocked(cs, err)
+		} else {
+			cc.active--
+			cc.cond.Broadcast()
+		}
+		cc.mu.Unlock()
+		req.closeBody()
+		return nil, err
+	}
+	cc.t.setReqCanceler(req, func() { cs.abort(errRequestCanceled) })
+	if cs.done != nil {
+		go cs.watchCancel()
+	}
+
+	var bodyc chan error
+	var respHeaderTimer <-chan time.Time
+	if hasBody {
+		bodyc = make(chan error, 1)
+		go func() { bodyc <- cs.writeRequestBody() }()
+	} else {
+		cs.requestSent()
+		if d := cc.t.ResponseHeaderTimeout; d > 0 {
+			respHeaderTimer = time.After(d)
+		}
+	}
+	for {
+		select {
+		case re := <-cs.resc:
+			if re.err == http2errClientConnUnusable && hasBody {
+				re.err = http2errGoAwayBody
+			}
+			if re.err != nil {
+				cc.t.setReqCanceler(req, nil)
+			}
+			return re.res, re.err
+		case err := <-bodyc:
+			bodyc = nil
+			if err != nil {
+				cs.abort(err)
+				break
+			}
+			if d := cc.t.ResponseHeaderTimeout; d > 0 {
+				respHeaderTimer = time.After(d)
+			}
+		case <-respHeaderTimer:
+			cs.abort(errTimeout)
+		}
+	}
+}
+
+// requestFields returns the header fields of the request.
+func (cs *http2clientStream) requestFields() []http2headerField {
+	req := cs.req
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	method := req.Method
+	if method == "" {
+		method = "GET"
+	}
+	fields := []http2headerField{
+		{Name: ":authority", Value: host},
+		{Name: ":method", Value: method},
+	}
+	if method != "CONNECT" {
+		fields = append(fields,
+			http2headerField{Name: ":path", Value: req.URL.RequestURI()},
+			http2headerField{Name: ":scheme", Value: "https"},
+		)
+	}
+	for _, f := range http2appendFields(nil, req.Header) {
+		if f.Name == "user-agent" && f.Value == "" {
+			// A blank User-Agent means none is sent.
+			continue
+		}
+		if f.Name == "content-length" {
+			continue
+		}
+		fields = append(fields, f)
+	}
+	if req.Body != nil && req.ContentLength > 0 {
+		fields = append(fields, http2headerField{Name: "content-length", Value: strconv.FormatInt(req.ContentLength, 10)})
+	}
+	if _, ok := req.Header["User-Agent"]; !ok {
+		fields = append(fields, http2headerField{Name: "user-agent", Value: defaultUserAgent})
+	}
+	// Ask for a compressed response, and decompress it, as
+	// persistConn.roundTrip does.
+	if !cs.cc.t.DisableCompression &&
+		req.Header.Get("Accept-Encoding") == "" &&
+		req.Header.Get("Range") == "" &&
+		method != "HEAD" {
+		cs.requestedGzip = true
+		fields = append(fields, http2headerField{Name: "accept-encoding", Value: "gzip"})
+	}
+	return fields
+}
+
+// writeRequestBody sends the request body. It returns an error only
+// if reading the body fails; write errors end the stream, which is
+// reported to RoundTrip by the read loop.
+func (cs *http2clientStream) writeRequestBody() error {
+	cc := cs.cc
+	body := cs.req.Body
+	defer body.Close()
+	buf := make([]byte, http2initialMaxFrameSize)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if cc.writeData(&cs.flow, cs.id, buf[:n], false) != nil {
+				return nil
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if cc.writeData(&cs.flow, cs.id, nil, true) == nil {
+		cs.requestSent()
+	}
+	return nil
+}
+
+// requestSent records that the request was sent up to END_STREAM.
+func (cs *http2clientStream) requestSent() {
+	cs.cc.mu.Lock()
+	cs.reqDone = true
+	cs.cc.mu.Unlock()
+}
+
+// stream returns the open stream with the given ID.
+func (cc *http2clientConn) stream(id uint32) (*http2clientStream, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if id == 0 || id%2 != 1 || id >= cc.nextStreamID {
+		return nil, http2ConnectionError(http2ErrCodeProtocol)
+	}
+	return cc.streams[id], nil
+}
+
+// eachFlow calls fn for the flow control state of each open stream.
+// It is called with mu held.
+func (cc *http2clientConn) eachFlow(fn func(*http2flow)) {
+	for _, cs := range cc.streams {
+		fn(&cs.flow)
+	}
+}
+
+func (cc *http2clientConn) readLoop() {
+	err := cc.readFrames()
+	if ce, ok := err.(http2ConnectionError); ok {
+		cc.writeFrames(func(fr *http2Framer) error {
+			return fr.WriteGoAway(0, http2ErrCode(ce))
+		})
+	} else if err == io.EOF || err == nil {
+		err = http2errClosedConn
+	}
+	cc.fail(err)
+
+	cc.mu.Lock()
+	for _, cs := range cc.streams {
+		cc.endStreamLocked(cs, err)
+	}
+	cc.closed = true
+	onClosed := cc.onClosed
+	cc.mu.Unlock()
+	if onClosed != nil {
+		onClosed()
+	}
+}
+
+func (cc *http2clientConn) readFrames() error {
+	for first := true; ; first = false {
+		f, err := cc.fr.ReadFrame()
+		if err != nil {
+			return err
+		}
+		if first && (f.Type != http2FrameSettings || f.has(http2FlagAck)) {
+			return http2ConnectionError(http2ErrCodeProtocol)
+		}
+		err = cc.processFrame(f)
+		if se, ok := err.(http2StreamError); ok {
+			cc.resetStream(se.StreamID, se.Code)
+			cc.mu.Lock()
+			if cs := cc.streams[se.StreamID]; cs != nil {
+				cc.endStreamLocked(cs, se)
+			}
+			cc.mu.Unlock()
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (cc *http2clientConn) processFrame(f *http2Frame) error {
+	switch f.Type {
+	case http2FrameData:
+		return cc.processData(f)
+	case http2FrameHeaders:
+		return cc.processHeaders(f)
+	case http2FramePriority:
+		return http2checkPriority(f)
+	case http2FrameRSTStream:
+		code, err := http2parseRSTStream(f)
+		if err != nil {
+			return err
+		}
+		cs, err := cc.stream(f.StreamID)
+		if err != nil {
+			return err
+		}
+		if cs != nil {
+			cc.mu.Lock()
+			if code == http2ErrCodeNo && !cs.bodyOpen {
+				// The response is complete; the server
+				// does not want the rest of the request.
+				err = http2errStreamReset
+			} else {
+				err = fmt.Errorf("http2: server reset stream: %v", code)
+			}
+			cc.endStreamLocked(cs, err)
+			cc.mu.Unlock()
+		}
+		return nil
+	case http2FrameSettings:
+		return cc.handleSettings(f, cc.eachFlow, func(s http2Setting) error {
+			if s.ID == http2SettingMaxConcurrentStreams {
+				cc.maxConcurrent = s.Val
+				cc.cond.Broadcast()
+			}
+			return nil
+		})
+	case http2FramePushPromise:
+		// Push was disabled in our SETTINGS.
+		return http2ConnectionError(http2ErrCodeProtocol)
+	case http2FramePing:
+		return cc.handlePing(f)
+	case http2FrameGoAway:
+		lastID, _, err := http2parseGoAway(f)
+		if err != nil {
+			return err
+		}
+		cc.mu.Lock()
+		cc.goAway = true
+		for id, cs := range cc.streams {
+			if id > lastID {
+				// Never processed by the server.
+				cc.endStreamLocked(cs, http2errClientConnUnusable)
+			}
+		}
+		idle := cc.active == 0
+		cc.cond.Broadcast()
+		cc.mu.Unlock()
+		if idle {
+			return http2errClosedConn
+		}
+		return nil
+	case http2FrameWindowUpdate:
+		var s *http2flow
+		if f.StreamID != 0 {
+			cs, err := cc.stream(f.StreamID)
+			if err != nil {
+				return err
+			}
+			if cs != nil {
+				s = &cs.flow
+			}
+		}
+		return cc.handleWindowUpdate(f, s)
+	case http2FrameContinuation:
+		return http2ConnectionError(http2ErrCodeProtocol)
+	}
+	return nil
+}
+
+func (cc *http2clientConn) processData(f *http2Frame) error {
+	cs, err := cc.stream(f.StreamID)
+	if err != nil {
+		return err
+	}
+	var s *http2flow
+	cc.mu.Lock()
+	if cs != nil && cs.bodyOpen && cs.gotHeader {
+		s = &cs.flow
+	}
+	cc.mu.Unlock()
+	data, err := cc.recvData(f, s)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		if cs != nil {
+			return http2StreamError{f.StreamID, http2ErrCodeStreamClosed}
+		}
+		return nil
+	}
+	cs.gotLen += int64(len(data))
+	if cs.declLen >= 0 && cs.gotLen > cs.declLen {
+		return http2StreamError{f.StreamID, http2ErrCodeProtocol}
+	}
+	cs.body.Write(data)
+	if f.has(http2FlagEndStream) {
+		return cc.endResponseBody(cs)
+	}
+	return nil
+}
+
+// endResponseBody marks the end of the response of cs. The stream
+// is done once the request was also sent; otherwise the server does
+// not want the rest of it.
+func (cc *http2clientConn) endResponseBody(cs *http2clientStream) error {
+	if cs.declLen >= 0 && cs.gotLen != cs.declLen && cs.req.Method != "HEAD" {
+		return http2StreamError{cs.id, http2ErrCodeProtocol}
+	}
+	if cs.body != nil {
+		cs.body.closeWithError(io.EOF)
+	}
+	cc.mu.Lock()
+	reqDone := cs.reqDone
+	cs.bodyOpen = false
+	cs.body = nil // keep the unread response body
+	cc.endStreamLocked(cs, http2errStreamReset)
+	cc.mu.Unlock()
+	if !reqDone {
+		cc.resetStream(cs.id, http2ErrCodeCancel)
+	}
+	return nil
+}
+
+func (cc *http2clientConn) processHeaders(f *http2Frame) error {
+	cs, err := cc.stream(f.StreamID)
+	if err != nil {
+		return err
+	}
+	hb, err := http2readHeaderBlock(cc.fr, f, http2maxResponseHeaderBytes)
+	if err != nil {
+		return err
+	}
+	var fields []http2headerField
+	if err := cc.dec.decode(hb.buf, func(f http2headerField) {
+		fields = append(fields, f)
+	}); err != nil {
+		return err
+	}
+	if cs == nil {
+		return nil
+	}
+	if cs.gotHeader {
+		// Trailers are read but not reported.
+		if !hb.endStream {
+			return http2StreamError{cs.id, http2ErrCodeProtocol}
+		}
+		return cc.endResponseBody(cs)
+	}
+
+	res, err := cs.newResponse(fields, hb.endStream)
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		// An informational response.
+		if hb.endStream {
+			return http2StreamError{cs.id, http2ErrCodeProtocol}
+		}
+		return nil
+	}
+	cs.gotHeader = true
+	cc.mu.Lock()
+	if !cs.sentRes {
+		cs.sentRes = true
+		cs.resc <- responseAndError{res: res}
+	}
+	cc.mu.Unlock()
+	if hb.endStream {
+		return cc.endResponseBody(cs)
+	}
+	return nil
+}
+
+// newResponse builds the Response carried by a HEADERS frame. It
+// returns nil for informational (1xx) responses.
+func (cs *http2clientStream) newResponse(fields []http2headerField, endStream bool) (*Response, error) {
+	malformed := http2StreamError{cs.id, http2ErrCodeProtocol}
+	status := ""
+	header := make(Header)
+	for i, f := range fields {
+		if f.Name == ":status" {
+			if i != 0 {
+				return nil, malformed
+			}
+			status = f.Value
+			continue
+		}
+		if !http2validHeaderField(f) {
+			return nil, malformed
+		}
+		header.Add(CanonicalHeaderKey(f.Name), f.Value)
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil || len(status) != 3 {
+		return nil, malformed
+	}
+	if code < 200 {
+		return nil, nil
+	}
+
+	res := &Response{
+		Status:        status + " " + StatusText(code),
+		StatusCode:    code,
+		Proto:         "HTTP/2.0",
+		ProtoMajor:    2,
+		ProtoMinor:    0,
+		Header:        header,
+		ContentLength: -1,
+		Request:       cs.req,
+		TLS:           cs.cc.tlsState,
+	}
+	if cl := header.get("Content-Length"); cl != "" {
+		n, err := strconv.ParseInt(cl, 10, 64)
+		if err != nil || n < 0 {
+			return nil, malformed
+		}
+		res.ContentLength = n
+		cs.declLen = n
+	}
+	if endStream {
+		if cs.req.Method != "HEAD" {
+			res.ContentLength = 0
+		}
+		res.Body = eofReader
+		return res, nil
+	}
+	pipe := newHTTP2Pipe()
+	cs.cc.mu.Lock()
+	cs.body = pipe
+	cs.cc.mu.Unlock()
+	res.Body = &http2responseBody{cs: cs, pipe: pipe}
+	if cs.requestedGzip && header.get("Content-Encoding") == "gzip" {
+		header.Del("Content-Encoding")
+		header.Del("Content-Length")
+		res.ContentLength = -1
+		res.Body = &gzipReader{body: res.Body}
+	}
+	return res, nil
+}
+
+// http2responseBody is the Body of a response received over HTTP/2.
+// Reading it returns flow control credit to the server.
+type http2responseBody struct {
+	cs   *http2clientStream
+	pipe *http2pipe
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (b *http2responseBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		return 0, errors.New("http: read on closed response body")
+	}
+	n, err := b.pipe.Read(p)
+	cs := b.cs
+	if n > 0 {
+		cs.cc.mu.Lock()
+		open := cs.open
+		cs.cc.mu.Unlock()
+		if open {
+			cs.cc.consumed(&cs.flow, cs.id, int32(n))
+		}
+	}
+	if err != nil {
+		cs.cc.t.setReqCanceler(cs.req, nil)
+	}
+	return n, err
+}
+
+func (b *http2responseBody) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+	b.pipe.breakWithError(http2errClosedBody)
+	b.cs.abort(http2errClosedBody)
+	b.cs.cc.t.setReqCanceler(b.cs.req, nil)
+	return nil
+}
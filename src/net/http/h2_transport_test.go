@@ -0,0 +1,164 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// An http2testListener accepts the connections of a Transport under
+// test, to be served by hand.
+type http2testListener struct {
+	t      *testing.T
+	ln     *net.TCPListener
+	config *tls.Config
+}
+
+func newHTTP2TestListener(t *testing.T) *http2testListener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http2testListener{
+		t:  t,
+		ln: ln.(*net.TCPListener),
+		config: &tls.Config{
+			Certificates: []tls.Certificate{http2testCert(t)},
+			NextProtos:   []string{http2NextProtoTLS},
+		},
+	}
+}
+
+// transport returns a Transport that speaks HTTP/2 to l.
+func (l *http2testListener) transport() *Transport {
+	return &Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			NextProtos:         []string{http2NextProtoTLS},
+		},
+	}
+}
+
+// accept accepts a connection, reads the client preface and
+// exchanges settings. It returns nil if no connection arrives within
+// d.
+func (l *http2testListener) accept(d time.Duration) *http2testPeer {
+	l.ln.SetDeadline(time.Now().Add(d))
+	c, err := l.ln.Accept()
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return nil
+		}
+		l.t.Fatal(err)
+	}
+	tc := tls.Server(c, l.config)
+	preface := make([]byte, len(http2ClientPreface))
+	if _, err := io.ReadFull(tc, preface); err != nil || string(preface) != http2ClientPreface {
+		tc.Close()
+		l.t.Fatalf("client preface %q, %v", preface, err)
+	}
+	p := newHTTP2TestPeer(l.t, tc)
+	p.writeFrames(func(fr *http2Framer) error { return fr.WriteSettings() })
+	p.expect(http2FrameSettings)
+	return p
+}
+
+// Tests that a request that a GOAWAY frame shows the server never
+// processed is sent again on a new connection by Transport.RoundTrip.
+func TestHTTP2TransportGoAwayRetry(t *testing.T) {
+	l := newHTTP2TestListener(t)
+	defer l.ln.Close()
+	tr := l.transport()
+	defer tr.CloseIdleConnections()
+
+	type result struct {
+		body string
+		err  error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		res, err := (&Client{Transport: tr}).Get("https://" + l.ln.Addr().String() + "/")
+		if err != nil {
+			resc <- result{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		resc <- result{string(body), err}
+	}()
+
+	p := l.accept(5 * time.Second)
+	defer p.nc.Close()
+	if f, _ := p.expectHeaders(http2initialMaxFrameSize); f.StreamID != 1 {
+		t.Fatalf("request on stream %d; want 1", f.StreamID)
+	}
+	p.writeFrames(func(fr *http2Framer) error { return fr.WriteGoAway(0, http2ErrCodeNo) })
+
+	p2 := l.accept(5 * time.Second)
+	if p2 == nil {
+		t.Fatal("request not sent again on a new connection")
+	}
+	defer p2.nc.Close()
+	f, _ := p2.expectHeaders(http2initialMaxFrameSize)
+	p2.writeHeaders(f.StreamID, false, http2initialMaxFrameSize, http2headerField{Name: ":status", Value: "200"})
+	p2.writeFrames(func(fr *http2Framer) error {
+		return fr.WriteFrame(http2FrameData, http2FlagEndStream, f.StreamID, []byte("ok"))
+	})
+	if r := <-resc; r.err != nil || r.body != "ok" {
+		t.Errorf("response %q, %v; want ok", r.body, r.err)
+	}
+}
+
+// Tests that a request whose body was being sent when a GOAWAY frame
+// showed the server never processed it fails rather than being sent
+// again without the body already read.
+func TestHTTP2TransportGoAwayDuringBody(t *testing.T) {
+	l := newHTTP2TestListener(t)
+	defer l.ln.Close()
+	tr := l.transport()
+	defer tr.CloseIdleConnections()
+
+	pr, pw := io.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		res, err := (&Client{Transport: tr}).Post("https://"+l.ln.Addr().String()+"/", "text/plain", pr)
+		if err == nil {
+			res.Body.Close()
+		}
+		errc <- err
+	}()
+
+	p := l.accept(5 * time.Second)
+	defer p.nc.Close()
+	f, _ := p.expectHeaders(http2initialMaxFrameSize)
+	if f.has(http2FlagEndStream) {
+		t.Fatal("request without a body")
+	}
+	io.WriteString(pw, "part of the body")
+	if f := p.expect(http2FrameData); string(f.Payload) != "part of the body" {
+		t.Fatalf("DATA %q", f.Payload)
+	}
+	p.writeFrames(func(fr *http2Framer) error { return fr.WriteGoAway(0, http2ErrCodeNo) })
+	pw.Close()
+
+	select {
+	case err := <-errc:
+		if err == nil || !strings.Contains(err.Error(), "GOAWAY") {
+			t.Errorf("Post error = %v; want one mentioning GOAWAY", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Post did not return")
+	}
+	if p2 := l.accept(100 * time.Millisecond); p2 != nil {
+		p2.nc.Close()
+		t.Error("request sent again on a new connection")
+	}
+}
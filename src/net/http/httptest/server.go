@@ -129,7 +129,13 @@ func (s *Server) StartTLS() {
 		*s.TLS = *existingConfig
 	}
 	if s.TLS.NextProtos == nil {
-		s.TLS.NextProtos = []string{"http/1.1"}
+		// Offer HTTP/2 only if the server takes it over;
+		// see http.Server.TLSNextProto.
+		if s.Config.TLSNextProto["h2"] != nil {
+			s.TLS.NextProtos = []string{"h2", "http/1.1"}
+		} else {
+			s.TLS.NextProtos = []string{"http/1.1"}
+		}
 	}
 	if len(s.TLS.Certificates) == 0 {
 		s.TLS.Certificates = []tls.Certificate{cert}
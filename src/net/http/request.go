@@ -22,6 +22,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -226,6 +227,40 @@ type Request struct {
 	// otherwise it leaves the field nil.
 	// This field is ignored by the HTTP client.
 	TLS *tls.ConnectionState
+
+	// Cancel is an optional channel whose closure indicates that
+	// the request should be regarded as canceled. The Transport
+	// in this package stops dialing, writing the request or
+	// reading the response once Cancel is closed. Not all
+	// implementations of RoundTripper may support Cancel.
+	//
+	// For server requests, the HTTP server in this package sets
+	// Cancel to a channel that is closed when the handler returns
+	// or the client's connection is found to have gone away. On
+	// HTTP/2 connections that is when the client resets the stream
+	// or the connection is lost; on HTTP/1.x connections, when a
+	// write to the client fails or, once the request body has been
+	// read to its end, when the client hangs up. A handler may pass
+	// Cancel on in the requests it makes, so that they are canceled
+	// along with its own.
+	Cancel <-chan struct{}
+
+	// Deadline, if non-zero, is the time after which the request
+	// should be regarded as canceled, as if Cancel were closed.
+	// Requests given up for a passed Deadline fail with an error
+	// whose Timeout method reports true.
+	Deadline time.Time
+}
+
+// deadlineTimer returns a channel that receives when r's Deadline
+// passes, and a func that releases the timer. The channel is nil if r
+// has no Deadline.
+func (r *Request) deadlineTimer() (<-chan time.Time, func()) {
+	if r.Deadline.IsZero() {
+		return nil, func() {}
+	}
+	t := time.NewTimer(r.Deadline.Sub(time.Now()))
+	return t.C, func() { t.Stop() }
 }
 
 // ProtoAtLeast reports whether the HTTP protocol used
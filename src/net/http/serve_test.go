@@ -908,6 +908,35 @@ func TestTLSHandshakeTimeout(t *testing.T) {
 	}
 }
 
+func TestTIPCServer(t *testing.T) {
+	defer afterTest(t)
+	ln, err := net.Listen("tipc", "{1000,42}")
+	if err != nil {
+		t.Skipf("TIPC not available: %v", err)
+	}
+	srv := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		fmt.Fprint(w, r.RemoteAddr)
+	})}
+	go srv.Serve(ln)
+	defer ln.Close()
+
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+	res, err := (&Client{Transport: tr}).Get("http+tipc://1000.42/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The remote address is the port identity of the client.
+	if _, err := net.ResolveTIPCAddr("tipc", string(body)); err != nil || !strings.HasPrefix(string(body), "<") {
+		t.Errorf("RemoteAddr = %q; want a TIPC port identity", body)
+	}
+}
+
 func TestTLSServer(t *testing.T) {
 	defer afterTest(t)
 	ts := httptest.NewTLSServer(HandlerFunc(func(w ResponseWriter, r *Request) {
@@ -2756,6 +2785,434 @@ func TestServerKeepAliveAfterWriteError(t *testing.T) {
 	}
 }
 
+// Tests that the server closes Request.Cancel when the client goes
+// away, and when the handler returns.
+func TestServerRequestCancel(t *testing.T) {
+	defer afterTest(t)
+	inHandler := make(chan bool)
+	canceled := make(chan bool, 1)
+	cancelc := make(chan (<-chan struct{}), 1)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.Cancel == nil {
+			t.Error("nil Request.Cancel")
+			return
+		}
+		if r.URL.Path == "/quick" {
+			cancelc <- r.Cancel
+			return
+		}
+		w.(CloseNotifier).CloseNotify()
+		inHandler <- true
+		select {
+		case <-r.Cancel:
+			canceled <- true
+		case <-time.After(5 * time.Second):
+			canceled <- false
+		}
+	}))
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: foo\r\n\r\n")
+	<-inHandler
+	conn.Close()
+	if !<-canceled {
+		t.Error("Request.Cancel not closed after the client hung up")
+	}
+
+	res, err := Get(ts.URL + "/quick")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	select {
+	case <-<-cancelc:
+	default:
+		t.Error("Request.Cancel not closed after the handler returned")
+	}
+}
+
+// Tests that the server notices a client hanging up while the handler
+// runs once the request body has been read, without the handler
+// calling CloseNotify.
+func TestServerRequestCancelWithoutCloseNotify(t *testing.T) {
+	defer afterTest(t)
+	inHandler := make(chan bool)
+	canceled := make(chan bool, 1)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			t.Errorf("reading body: %v", err)
+		}
+		inHandler <- true
+		select {
+		case <-r.Cancel:
+			canceled <- true
+		case <-time.After(5 * time.Second):
+			canceled <- false
+		}
+	}))
+	defer ts.Close()
+
+	for _, req := range []string{
+		"GET / HTTP/1.1\r\nHost: foo\r\n\r\n",
+		"POST / HTTP/1.1\r\nHost: foo\r\nContent-Length: 5\r\n\r\nhello",
+		"POST / HTTP/1.1\r\nHost: foo\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n",
+	} {
+		conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(conn, req)
+		<-inHandler
+		conn.Close()
+		if !<-canceled {
+			t.Errorf("Request.Cancel not closed after the client hung up; request %q", req)
+		}
+	}
+}
+
+// Tests that the bytes read while watching for the client to hang up
+// are handed over to a handler that hijacks the connection.
+func TestServerHijackAfterBackgroundRead(t *testing.T) {
+	defer afterTest(t)
+	sent := make(chan bool)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		<-sent
+		time.Sleep(50 * time.Millisecond) // let the background read see the bytes
+		conn, bufrw, err := w.(Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		line, err := bufrw.ReadString('\n')
+		if err != nil {
+			t.Errorf("reading after hijack: %v", err)
+			return
+		}
+		io.WriteString(conn, "got "+line)
+	}))
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: foo\r\n\r\n")
+	time.Sleep(50 * time.Millisecond)
+	io.WriteString(conn, "hello\n")
+	sent <- true
+	b, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "got hello\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+// Tests that ReadHeaderTimeout cuts off a client slow to send its
+// headers, but not one slow to send its body.
+func TestServerReadHeaderTimeout(t *testing.T) {
+	defer afterTest(t)
+	ts := httptest.NewUnstartedServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading body: %v", err)
+		}
+		w.Write(body)
+	}))
+	ts.Config.ReadHeaderTimeout = 100 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: foo\r\n")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if got, err := ioutil.ReadAll(conn); err != nil || len(got) != 0 {
+		t.Errorf("slow headers: read %q, %v; want the connection closed", got, err)
+	}
+
+	conn, err = net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "POST / HTTP/1.1\r\nHost: foo\r\nContent-Length: 5\r\nConnection: close\r\n\r\n")
+	time.Sleep(300 * time.Millisecond)
+	io.WriteString(conn, "hello")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	res, err := ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("slow body: %v", err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != 200 || string(body) != "hello" {
+		t.Errorf("slow body: status %d, body %q", res.StatusCode, body)
+	}
+}
+
+// Tests that IdleTimeout closes a keep-alive connection that waits
+// too long for its next request, and that the wait is not bounded by
+// ReadHeaderTimeout.
+func TestServerIdleTimeout(t *testing.T) {
+	defer afterTest(t)
+	ts := httptest.NewUnstartedServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, r.URL.Path)
+	}))
+	ts.Config.ReadHeaderTimeout = 100 * time.Millisecond
+	ts.Config.IdleTimeout = 500 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	br := bufio.NewReader(conn)
+	get := func(path string) {
+		io.WriteString(conn, "GET "+path+" HTTP/1.1\r\nHost: foo\r\n\r\n")
+		res, err := ReadResponse(br, nil)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if string(body) != path {
+			t.Errorf("GET %s: body %q", path, body)
+		}
+	}
+	get("/first")
+	time.Sleep(250 * time.Millisecond) // longer than ReadHeaderTimeout
+	get("/second")
+
+	start := time.Now()
+	if got, err := ioutil.ReadAll(br); err != nil || len(got) != 0 {
+		t.Errorf("idle conn: read %q, %v; want the connection closed", got, err)
+	}
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("idle conn closed after %v; want about 500ms", d)
+	}
+}
+
+func TestServerMaxRequestsPerConn(t *testing.T) {
+	defer afterTest(t)
+	ts := httptest.NewUnstartedServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, r.RemoteAddr)
+	}))
+	ts.Config.MaxRequestsPerConn = 2
+	ts.Start()
+	defer ts.Close()
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	var addrs []string
+	for i := 0; i < 3; i++ {
+		res, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if wantClose := i == 1; res.Close != wantClose {
+			t.Errorf("request %d: Close = %v; want %v", i, res.Close, wantClose)
+		}
+		addrs = append(addrs, string(body))
+	}
+	if addrs[0] != addrs[1] || addrs[1] == addrs[2] {
+		t.Errorf("remote addrs = %q; want the first two the same and the third new", addrs)
+	}
+}
+
+// serveForShutdown starts srv on a new local listener and returns
+// the listener's address and a channel receiving Serve's result.
+func serveForShutdown(t *testing.T, srv *Server) (addr string, errc chan error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc = make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	return ln.Addr().String(), errc
+}
+
+func TestServerShutdown(t *testing.T) {
+	defer afterTest(t)
+	inHandler := make(chan bool)
+	release := make(chan bool)
+	srv := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/slow" {
+			inHandler <- true
+			<-release
+		}
+		io.WriteString(w, r.URL.Path)
+	})}
+	addr, serveErr := serveForShutdown(t, srv)
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	// An idle keep-alive connection, which Shutdown closes, and a
+	// request in flight, which it waits for.
+	res, err := c.Get("http://" + addr + "/idle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	type result struct {
+		body string
+		res  *Response
+		err  error
+	}
+	slowc := make(chan result, 1)
+	go func() {
+		res, err := (&Client{Transport: &Transport{DisableKeepAlives: true}}).Get("http://" + addr + "/slow")
+		if err != nil {
+			slowc <- result{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		slowc <- result{string(body), res, err}
+	}()
+	<-inHandler
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- srv.Shutdown(time.Time{}) }()
+	if err := <-serveErr; err != ErrServerClosed {
+		t.Errorf("Serve = %v; want ErrServerClosed", err)
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("dial after Shutdown succeeded")
+	}
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned %v with a request in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	r := <-slowc
+	if r.err != nil || r.body != "/slow" {
+		t.Errorf("in-flight request = %q, %v", r.body, r.err)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown = %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Serve(ln); err != ErrServerClosed {
+		t.Errorf("Serve after Shutdown = %v; want ErrServerClosed", err)
+	}
+}
+
+// Tests that a connection that never sends a request does not hold
+// up Shutdown.
+func TestServerShutdownNewConn(t *testing.T) {
+	defer afterTest(t)
+	defer SetShutdownNewConnGrace(100 * time.Millisecond)()
+	srv := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {})}
+	addr, serveErr := serveForShutdown(t, srv)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- srv.Shutdown(time.Time{}) }()
+	select {
+	case err := <-shutdownErr:
+		if err != nil {
+			t.Errorf("Shutdown = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown blocked on a connection that sent no request")
+	}
+	<-serveErr
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := ioutil.ReadAll(conn); err != nil {
+		t.Errorf("reading conn after Shutdown: %v", err)
+	}
+}
+
+func TestServerShutdownDeadline(t *testing.T) {
+	defer afterTest(t)
+	inHandler := make(chan bool)
+	srv := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		inHandler <- true
+		ioutil.ReadAll(r.Body) // until the server closes the conn
+	})}
+	addr, serveErr := serveForShutdown(t, srv)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "POST / HTTP/1.1\r\nHost: foo\r\nContent-Length: 100\r\n\r\n")
+	<-inHandler
+
+	err = srv.Shutdown(time.Now().Add(100 * time.Millisecond))
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Fatalf("Shutdown = %v; want a timeout error", err)
+	}
+	<-serveErr
+
+	if err := srv.Close(); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := ioutil.ReadAll(conn); err != nil {
+		t.Errorf("reading conn after Close: %v", err)
+	}
+}
+
+func TestServerRegisterOnShutdown(t *testing.T) {
+	defer afterTest(t)
+	hijacked := make(chan net.Conn, 1)
+	srv := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		c, _, err := w.(Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		hijacked <- c
+	})}
+	srv.RegisterOnShutdown(func() {
+		(<-hijacked).Close()
+	})
+	addr, serveErr := serveForShutdown(t, srv)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: foo\r\n\r\n")
+
+	// The hijacked conn is not waited for.
+	if err := srv.Shutdown(time.Now().Add(5 * time.Second)); err != nil {
+		t.Errorf("Shutdown = %v", err)
+	}
+	<-serveErr
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := ioutil.ReadAll(conn); err != nil {
+		t.Errorf("hijacked conn not closed by hook: %v", err)
+	}
+}
+
 func BenchmarkClientServer(b *testing.B) {
 	b.ReportAllocs()
 	b.StopTimer()
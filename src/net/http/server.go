@@ -8,6 +8,7 @@ package http
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -32,6 +33,11 @@ var (
 	ErrBodyNotAllowed  = errors.New("http: request method or response status code does not allow body")
 	ErrHijacked        = errors.New("Conn has been hijacked")
 	ErrContentLength   = errors.New("Conn.Write wrote more than the declared Content-Length")
+
+	// ErrServerClosed is returned by the Server's Serve,
+	// ListenAndServe and ListenAndServeTLS methods after a call
+	// to Shutdown or Close.
+	ErrServerClosed = errors.New("http: Server closed")
 )
 
 // Objects implementing the Handler interface can be
@@ -121,10 +127,15 @@ type conn struct {
 	buf        *bufio.ReadWriter    // buffered(lr,rwc), reading from bufio->limitReader->sr->rwc
 	tlsState   *tls.ConnectionState // or nil when not using TLS
 
-	mu           sync.Mutex // guards the following
-	clientGone   bool       // if client has disconnected mid-request
-	closeNotifyc chan bool  // made lazily
-	hijackedv    bool       // connection has been hijacked by handler
+	mu           sync.Mutex    // guards the following
+	clientGone   bool          // if client has disconnected mid-request
+	closeNotifyc chan bool     // made lazily
+	hijackedv    bool          // connection has been hijacked by handler
+	reqCancel    chan struct{} // Cancel of the current request; nil once closed
+	bgDone       chan struct{} // closed when the background read ends; nil if none
+	bgAborted    bool          // background read stopped by abortBackgroundRead
+
+	nreq int // number of requests read; owned by the serving goroutine
 }
 
 func (c *conn) hijacked() bool {
@@ -136,6 +147,7 @@ func (c *conn) hijacked() bool {
 func (c *conn) hijack() (rwc net.Conn, buf *bufio.ReadWriter, err error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.abortBackgroundReadLocked()
 	if c.hijackedv {
 		return nil, nil, ErrHijacked
 	}
@@ -155,6 +167,8 @@ func (c *conn) closeNotify() <-chan bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.closeNotifyc == nil {
+		// The pipe below takes over watching the connection.
+		c.abortBackgroundReadLocked()
 		c.closeNotifyc = make(chan bool, 1)
 		if c.hijackedv {
 			// to obey the function signature, even though
@@ -186,6 +200,88 @@ func (c *conn) noteClientGone() {
 		c.closeNotifyc <- true
 	}
 	c.clientGone = true
+	c.cancelRequestLocked()
+}
+
+// cancelRequest closes the Cancel channel of the request being
+// served, if it is not closed yet.
+func (c *conn) cancelRequest() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cancelRequestLocked()
+}
+
+func (c *conn) cancelRequestLocked() {
+	if c.reqCancel != nil {
+		close(c.reqCancel)
+		c.reqCancel = nil
+	}
+}
+
+// startBackgroundRead starts reading from the connection while the
+// handler runs, so that the Cancel channel of the request is closed
+// if the client hangs up.  It is called once the request body has
+// been read to its end: anything the client sends afterwards is the
+// start of its next request, which the background read keeps for the
+// next readRequest.
+func (c *conn) startBackgroundRead() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reqCancel == nil || c.bgDone != nil || c.hijackedv || c.closeNotifyc != nil || c.clientGone {
+		return
+	}
+	if c.buf.Reader.Buffered() > 0 {
+		// A pipelined request is waiting already.
+		return
+	}
+	// The request has been read, so ReadTimeout no longer applies.
+	c.rwc.SetReadDeadline(time.Time{})
+	c.bgDone = make(chan struct{})
+	go c.backgroundRead(c.bgDone)
+}
+
+func (c *conn) backgroundRead(done chan struct{}) {
+	c.sr.Lock()
+	r := c.sr.r
+	c.sr.Unlock()
+	var b [1]byte
+	n, err := r.Read(b[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n == 1 {
+		c.sr.Lock()
+		c.sr.r = io.MultiReader(bytes.NewReader(b[:]), c.rwc)
+		c.sr.Unlock()
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() && c.bgAborted {
+		// Stopped by abortBackgroundReadLocked.
+		c.rwc.SetReadDeadline(time.Time{})
+	} else if err != nil {
+		c.clientGone = true
+		c.cancelRequestLocked()
+	}
+	c.bgDone = nil
+	c.bgAborted = false
+	close(done)
+}
+
+// aLongTimeAgo is a read deadline that makes a pending read return
+// at once.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// abortBackgroundReadLocked stops the background read, if any, and
+// waits for it to end.  It must be called with c.mu held, which it
+// releases while waiting.
+func (c *conn) abortBackgroundReadLocked() {
+	for c.bgDone != nil {
+		done := c.bgDone
+		c.bgAborted = true
+		c.rwc.SetReadDeadline(aLongTimeAgo)
+		c.mu.Unlock()
+		<-done
+		c.mu.Lock()
+	}
 }
 
 // A switchReader can have its Reader changed at runtime.
@@ -507,6 +603,26 @@ func (srv *Server) maxHeaderBytes() int {
 	return DefaultMaxHeaderBytes
 }
 
+func (srv *Server) readHeaderTimeout() time.Duration {
+	if srv.ReadHeaderTimeout != 0 {
+		return srv.ReadHeaderTimeout
+	}
+	return srv.ReadTimeout
+}
+
+func (srv *Server) idleTimeout() time.Duration {
+	if srv.IdleTimeout != 0 {
+		return srv.IdleTimeout
+	}
+	return srv.ReadTimeout
+}
+
+// requestLimitReached reports whether a connection that has read n
+// requests may not read another.
+func (srv *Server) requestLimitReached(n int) bool {
+	return srv.MaxRequestsPerConn > 0 && n >= srv.MaxRequestsPerConn
+}
+
 func (srv *Server) initialLimitedReaderSize() int64 {
 	return int64(srv.maxHeaderBytes()) + 4096 // bufio slop
 }
@@ -572,9 +688,17 @@ func (c *conn) readRequest() (w *response, err error) {
 		return nil, ErrHijacked
 	}
 
+	// The headers must arrive within ReadHeaderTimeout, and the
+	// whole request, body included, within ReadTimeout.
+	t0 := time.Now()
+	var hdrDeadline, wholeReqDeadline time.Time
+	if d := c.server.readHeaderTimeout(); d != 0 {
+		hdrDeadline = t0.Add(d)
+	}
 	if d := c.server.ReadTimeout; d != 0 {
-		c.rwc.SetReadDeadline(time.Now().Add(d))
+		wholeReqDeadline = t0.Add(d)
 	}
+	c.rwc.SetReadDeadline(hdrDeadline)
 	if d := c.server.WriteTimeout; d != 0 {
 		defer func() {
 			c.rwc.SetWriteDeadline(time.Now().Add(d))
@@ -590,9 +714,16 @@ func (c *conn) readRequest() (w *response, err error) {
 		return nil, err
 	}
 	c.lr.N = noLimit
+	c.rwc.SetReadDeadline(wholeReqDeadline)
+	c.nreq++
 
 	req.RemoteAddr = c.remoteAddr
 	req.TLS = c.tlsState
+	cancelc := make(chan struct{})
+	req.Cancel = cancelc
+	c.mu.Lock()
+	c.reqCancel = cancelc
+	c.mu.Unlock()
 
 	w = &response{
 		conn:          c,
@@ -718,7 +849,7 @@ func (cw *chunkWriter) writeHeader(p []byte) {
 	cw.wroteHeader = true
 
 	w := cw.res
-	keepAlivesEnabled := w.conn.server.doKeepAlives()
+	keepAlivesEnabled := w.conn.server.doKeepAlives() && !w.conn.server.requestLimitReached(w.conn.nreq)
 	isHEAD := w.req.Method == "HEAD"
 
 	// header is written out to w.conn.buf below. Depending on the
@@ -1066,6 +1197,10 @@ func (c *conn) finalFlush() {
 
 // Close the connection.
 func (c *conn) close() {
+	c.mu.Lock()
+	c.cancelRequestLocked()
+	c.abortBackgroundReadLocked()
+	c.mu.Unlock()
 	c.finalFlush()
 	if c.rwc != nil {
 		c.rwc.Close()
@@ -1115,6 +1250,7 @@ func validNPN(proto string) bool {
 }
 
 func (c *conn) setState(nc net.Conn, state ConnState) {
+	c.server.trackConn(c, nc, state)
 	if hook := c.server.ConnState; hook != nil {
 		hook(nc, state)
 	}
@@ -1150,8 +1286,13 @@ func (c *conn) serve() {
 		c.tlsState = new(tls.ConnectionState)
 		*c.tlsState = tlsConn.ConnectionState()
 		if proto := c.tlsState.NegotiatedProtocol; validNPN(proto) {
-			if fn := c.server.TLSNextProto[proto]; fn != nil {
+			fn := c.server.TLSNextProto[proto]
+			if fn == nil && proto == http2NextProtoTLS {
+				fn = http2ServeConn
+			}
+			if fn != nil {
 				h := initNPNRequest{tlsConn, serverHandler{c.server}}
+				c.setState(c.rwc, StateActive)
 				fn(c.server, tlsConn, h)
 			}
 			return
@@ -1159,8 +1300,9 @@ func (c *conn) serve() {
 	}
 
 	for {
+		buffered := c.buf.Reader.Buffered() > 0
 		w, err := c.readRequest()
-		if c.lr.N != c.server.initialLimitedReaderSize() {
+		if buffered || c.lr.N != c.server.initialLimitedReaderSize() {
 			// If we read any bytes off the wire, we're active.
 			c.setState(c.rwc, StateActive)
 		}
@@ -1183,8 +1325,15 @@ func (c *conn) serve() {
 			break
 		}
 
-		// Expect 100 Continue support
+		// Watch for the client hanging up once the body is read.
 		req := w.req
+		if b, ok := req.Body.(*body); ok {
+			b.onHitEOF = c.startBackgroundRead
+		} else {
+			c.startBackgroundRead()
+		}
+
+		// Expect 100 Continue support
 		if req.expectsContinue() {
 			if req.ProtoAtLeast(1, 1) && req.ContentLength != 0 {
 				// Wrap the Body reader with one that replies on the connection
@@ -1202,6 +1351,10 @@ func (c *conn) serve() {
 		// [*] Not strictly true: HTTP pipelining.  We could let them all process
 		// in parallel even if their responses need to be serialized.
 		serverHandler{c.server}.ServeHTTP(w, w.req)
+		c.mu.Lock()
+		c.cancelRequestLocked()
+		c.abortBackgroundReadLocked()
+		c.mu.Unlock()
 		if c.hijacked() {
 			return
 		}
@@ -1213,6 +1366,17 @@ func (c *conn) serve() {
 			break
 		}
 		c.setState(c.rwc, StateIdle)
+		if c.server.shuttingDown() {
+			break
+		}
+		if d := c.server.idleTimeout(); d != 0 {
+			// Wait for the next request's first bytes, so that
+			// the header timeout starts once they arrive.
+			c.rwc.SetReadDeadline(time.Now().Add(d))
+			if _, err := c.buf.Peek(4); err != nil {
+				break
+			}
+		}
 	}
 }
 
@@ -1616,6 +1780,26 @@ type Server struct {
 	MaxHeaderBytes int           // maximum size of request headers, DefaultMaxHeaderBytes if 0
 	TLSConfig      *tls.Config   // optional TLS config, used by ListenAndServeTLS
 
+	// ReadHeaderTimeout is the amount of time allowed to read a
+	// request's headers, counted from the start of the request.
+	// The deadline is then extended to ReadTimeout for reading
+	// the body, so a handler may take its time over a large
+	// upload while slow clients are still cut off early. If
+	// ReadHeaderTimeout is zero, ReadTimeout is used.
+	ReadHeaderTimeout time.Duration
+
+	// IdleTimeout is the maximum amount of time to wait for the
+	// next request on a keep-alive connection. The header timeout
+	// starts once the next request begins to arrive. If
+	// IdleTimeout is zero, ReadTimeout is used.
+	IdleTimeout time.Duration
+
+	// MaxRequestsPerConn, if positive, is the number of requests
+	// served on a connection before the server closes it. The
+	// last response carries "Connection: close"; on HTTP/2
+	// connections the client is sent a GOAWAY frame instead.
+	MaxRequestsPerConn int
+
 	// TLSNextProto optionally specifies a function to take over
 	// ownership of the provided TLS connection when an NPN
 	// protocol upgrade has occurred.  The map key is the protocol
@@ -1623,6 +1807,13 @@ type Server struct {
 	// handle HTTP requests and will initialize the Request's TLS
 	// and RemoteAddr if not already set.  The connection is
 	// automatically closed when the function returns.
+	//
+	// HTTP/2 is off by default. To turn it on, list "h2" in the
+	// NextProtos of the TLS configuration: connections that
+	// negotiate "h2" are then served by the built-in HTTP/2
+	// support unless TLSNextProto has an "h2" entry of its own.
+	// ListenAndServeTLS offers "h2" if TLSNextProto has an "h2"
+	// entry and TLSConfig does not list protocols of its own.
 	TLSNextProto map[string]func(*Server, *tls.Conn, Handler)
 
 	// ConnState specifies an optional callback function that is
@@ -1636,7 +1827,22 @@ type Server struct {
 	// standard logger.
 	ErrorLog *log.Logger
 
-	disableKeepAlives int32 // accessed atomically.
+	disableKeepAlives int32     // accessed atomically.
+	inShutdown        int32     // accessed atomically; non-zero after Shutdown or Close
+	nextProtoOnce     sync.Once // guards setupHTTP2
+
+	mu         sync.Mutex // guards the following
+	listeners  map[*net.Listener]struct{}
+	activeConn map[*conn]trackedConn
+	onShutdown []func()
+	http2Conns map[*http2serverConn]struct{}
+}
+
+// A trackedConn is a connection being served and its state.
+type trackedConn struct {
+	nc    net.Conn
+	state ConnState
+	since time.Time // when state was entered
 }
 
 // A ConnState represents the state of a client connection to a server.
@@ -1721,12 +1927,23 @@ func (srv *Server) ListenAndServe() error {
 // Serve accepts incoming connections on the Listener l, creating a
 // new service goroutine for each.  The service goroutines read requests and
 // then call srv.Handler to reply to them.
+//
+// Serve always returns a non-nil error. After Shutdown or Close, the
+// returned error is ErrServerClosed.
 func (srv *Server) Serve(l net.Listener) error {
 	defer l.Close()
+	srv.nextProtoOnce.Do(srv.setupHTTP2)
+	if !srv.trackListener(&l, true) {
+		return ErrServerClosed
+	}
+	defer srv.trackListener(&l, false)
 	var tempDelay time.Duration // how long to sleep on accept failure
 	for {
 		rw, e := l.Accept()
 		if e != nil {
+			if srv.shuttingDown() {
+				return ErrServerClosed
+			}
 			if ne, ok := e.(net.Error); ok && ne.Temporary() {
 				if tempDelay == 0 {
 					tempDelay = 5 * time.Millisecond
@@ -1752,8 +1969,182 @@ func (srv *Server) Serve(l net.Listener) error {
 	}
 }
 
+// setupHTTP2 arranges for Shutdown to reach the connections served
+// by the built-in HTTP/2 support.
+func (srv *Server) setupHTTP2() {
+	srv.RegisterOnShutdown(srv.shutdownHTTP2Conns)
+}
+
+// shutdownPollInterval is how often Shutdown checks whether the
+// connections it waits for are done.
+var shutdownPollInterval = 500 * time.Millisecond
+
+// shutdownNewConnGrace is how long Shutdown waits for a new
+// connection to start its first request before treating it as idle.
+var shutdownNewConnGrace = 5 * time.Second
+
+var errShutdownTimeout error = &httpError{err: "http: Server.Shutdown deadline exceeded", timeout: true}
+
+// Shutdown gracefully shuts down the server without interrupting
+// any active connections. Shutdown works by first closing all open
+// listeners, then closing all idle connections, and then waiting
+// for the active connections to return to idle, closing them as they
+// do. A new connection on which no request has begun within a few
+// seconds is closed as if idle. HTTP/2 connections are told to expect
+// no more requests, and close once their requests in flight are done.
+//
+// If the deadline passes before all connections are closed,
+// Shutdown returns an error whose Timeout method reports true; the
+// connections still open are left alone, and Close ends them. A zero
+// deadline means Shutdown waits as long as it takes. Otherwise,
+// Shutdown returns any error returned from closing the Server's
+// listeners.
+//
+// Once Shutdown has been called, Serve, ListenAndServe and
+// ListenAndServeTLS return ErrServerClosed right away. Shutdown
+// neither closes nor waits for hijacked connections; functions
+// registered with RegisterOnShutdown may tell their owners to close
+// them.
+func (srv *Server) Shutdown(deadline time.Time) error {
+	atomic.StoreInt32(&srv.inShutdown, 1)
+	srv.mu.Lock()
+	lnerr := srv.closeListenersLocked()
+	for _, f := range srv.onShutdown {
+		go f()
+	}
+	srv.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		t := time.NewTimer(deadline.Sub(time.Now()))
+		defer t.Stop()
+		timeout = t.C
+	}
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if srv.closeIdleConns() {
+			return lnerr
+		}
+		select {
+		case <-timeout:
+			return errShutdownTimeout
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close immediately closes all listeners and all connections in
+// state StateNew, StateActive or StateIdle, abandoning the requests
+// in progress on them. For a graceful shutdown, use Shutdown.
+//
+// Close does not close hijacked connections, nor does it call the
+// functions registered with RegisterOnShutdown. It returns any
+// error returned from closing the Server's listeners.
+func (srv *Server) Close() error {
+	atomic.StoreInt32(&srv.inShutdown, 1)
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	err := srv.closeListenersLocked()
+	for c, t := range srv.activeConn {
+		t.nc.Close()
+		delete(srv.activeConn, c)
+	}
+	return err
+}
+
+// RegisterOnShutdown registers a function to call on Shutdown. It
+// can be used to gracefully shut down connections that have been
+// hijacked, or that speak a protocol of their own after an NPN/ALPN
+// upgrade. Each function is called in a goroutine of its own, and
+// should not wait for shutdown to complete.
+func (srv *Server) RegisterOnShutdown(f func()) {
+	srv.mu.Lock()
+	srv.onShutdown = append(srv.onShutdown, f)
+	srv.mu.Unlock()
+}
+
+func (srv *Server) shuttingDown() bool {
+	return atomic.LoadInt32(&srv.inShutdown) != 0
+}
+
+// closeIdleConns closes the idle connections and reports whether
+// the server has no connections left.
+func (srv *Server) closeIdleConns() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	quiescent := true
+	for c, t := range srv.activeConn {
+		idle := t.state == StateIdle ||
+			t.state == StateNew && time.Since(t.since) >= shutdownNewConnGrace
+		if !idle {
+			quiescent = false
+			continue
+		}
+		t.nc.Close()
+		delete(srv.activeConn, c)
+	}
+	return quiescent
+}
+
+func (srv *Server) closeListenersLocked() error {
+	var err error
+	for ln := range srv.listeners {
+		if cerr := (*ln).Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(srv.listeners, ln)
+	}
+	return err
+}
+
+// trackListener adds or removes a listener from the set of
+// listeners Shutdown and Close close. It reports false if the listener
+// cannot be added because the server is shutting down.
+func (srv *Server) trackListener(ln *net.Listener, add bool) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if add {
+		if srv.shuttingDown() {
+			return false
+		}
+		if srv.listeners == nil {
+			srv.listeners = make(map[*net.Listener]struct{})
+		}
+		srv.listeners[ln] = struct{}{}
+	} else {
+		delete(srv.listeners, ln)
+	}
+	return true
+}
+
+// trackConn records the state of a connection being served.
+func (srv *Server) trackConn(c *conn, nc net.Conn, state ConnState) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	switch state {
+	case StateHijacked, StateClosed:
+		delete(srv.activeConn, c)
+	default:
+		if srv.activeConn == nil {
+			srv.activeConn = make(map[*conn]trackedConn)
+		}
+		srv.activeConn[c] = trackedConn{nc, state, time.Now()}
+	}
+}
+
+// nextProtos returns the protocols to offer for TLS NPN/ALPN: "h2"
+// and "http/1.1" if TLSNextProto takes over "h2" connections, or
+// else only "http/1.1".
+func (srv *Server) nextProtos() []string {
+	if srv.TLSNextProto[http2NextProtoTLS] != nil {
+		return []string{http2NextProtoTLS, "http/1.1"}
+	}
+	return []string{"http/1.1"}
+}
+
 func (s *Server) doKeepAlives() bool {
-	return atomic.LoadInt32(&s.disableKeepAlives) == 0
+	return atomic.LoadInt32(&s.disableKeepAlives) == 0 && !s.shuttingDown()
 }
 
 // SetKeepAlivesEnabled controls whether HTTP keep-alives are enabled.
@@ -1808,6 +2199,17 @@ func ListenAndServe(addr string, handler Handler) error {
 	return server.ListenAndServe()
 }
 
+// ListenAndServeTIPC is like ListenAndServe but listens for TIPC
+// connections on the TIPC name or name sequence addr, written as
+// accepted by net.ResolveTIPCAddr, such as "{1000,42}". Clients reach
+// it with "http+tipc" URLs; see Transport. The RemoteAddr of each
+// request is the port identity of the client's socket, such as
+// "<1.1.1:2319234>".
+func ListenAndServeTIPC(addr string, handler Handler) error {
+	server := &Server{Addr: addr, Handler: handler}
+	return server.ListenAndServeTIPC()
+}
+
 // ListenAndServeTLS acts identically to ListenAndServe, except that it
 // expects HTTPS connections. Additionally, files containing a certificate and
 // matching private key for the server must be provided. If the certificate
@@ -1841,6 +2243,17 @@ func ListenAndServeTLS(addr string, certFile string, keyFile string, handler Han
 	return server.ListenAndServeTLS(certFile, keyFile)
 }
 
+// ListenAndServeTIPC listens on the TIPC name or name sequence
+// srv.Addr and then calls Serve to handle requests on incoming TIPC
+// connections. See the ListenAndServeTIPC function.
+func (srv *Server) ListenAndServeTIPC() error {
+	ln, err := net.Listen("tipc", srv.Addr)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(ln)
+}
+
 // ListenAndServeTLS listens on the TCP network address srv.Addr and
 // then calls Serve to handle requests on incoming TLS connections.
 //
@@ -1860,7 +2273,7 @@ func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
 		*config = *srv.TLSConfig
 	}
 	if config.NextProtos == nil {
-		config.NextProtos = []string{"http/1.1"}
+		config.NextProtos = srv.nextProtos()
 	}
 
 	var err error
@@ -2091,6 +2504,7 @@ func (w checkConnErrorWriter) Write(p []byte) (n int, err error) {
 	n, err = w.c.w.Write(p) // c.w == c.rwc, except after a hijack, when rwc is nil.
 	if err != nil && w.c.werr == nil {
 		w.c.werr = err
+		w.c.cancelRequest()
 	}
 	return
 }
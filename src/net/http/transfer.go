@@ -560,8 +560,10 @@ type body struct {
 	r       *bufio.Reader // underlying wire-format reader for the trailer
 	closing bool          // is the connection to be closed after reading body?
 
-	mu     sync.Mutex // guards closed, and calls to Read and Close
-	closed bool
+	mu       sync.Mutex // guards closed, and calls to Read and Close
+	closed   bool
+	sawEOF   bool
+	onHitEOF func() // if non-nil, called once the body has been read to its end
 }
 
 // ErrBodyReadAfterClose is returned when reading a Request or Response
@@ -610,6 +612,12 @@ func (b *body) readLocked(p []byte) (n int, err error) {
 		}
 	}
 
+	if err == io.EOF && !b.sawEOF {
+		b.sawEOF = true
+		if b.onHitEOF != nil {
+			b.onHitEOF()
+		}
+	}
 	return n, err
 }
 
@@ -20,6 +20,8 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -46,12 +48,27 @@ const DefaultMaxIdleConnsPerHost = 2
 // Transport is an implementation of RoundTripper that supports HTTP,
 // HTTPS, and HTTP proxies (for either HTTP or HTTPS with CONNECT).
 // Transport can also cache connections for future re-use.
+//
+// Transport also sends requests for "http+tipc" URLs over TIPC
+// connections. The host of such a URL is a TIPC name written
+// "service.instance", as in "http+tipc://1000.42/path", and the
+// request goes to whichever server in the cluster is bound to that
+// name. Proxies are not used for these requests.
+//
+// For HTTPS URLs, a Transport that offers "h2" in TLSClientConfig
+// speaks HTTP/2 with servers that support it, negotiated with TLS
+// ALPN. All requests to such a server share a single connection, each
+// sent on a stream of its own.
 type Transport struct {
 	idleMu     sync.Mutex
 	wantIdle   bool // user has requested to close all idle conns
 	idleConn   map[connectMethodKey][]*persistConn
 	idleConnCh map[connectMethodKey]chan *persistConn
 
+	connsPerHostMu   sync.Mutex
+	connsPerHost     map[connectMethodKey]int             // connections dialing or open
+	connsPerHostWait map[connectMethodKey][]chan struct{} // dialers waiting under MaxConnsPerHost
+
 	reqMu       sync.Mutex
 	reqCanceler map[*Request]func()
 
@@ -65,7 +82,8 @@ type Transport struct {
 	Proxy func(*Request) (*url.URL, error)
 
 	// Dial specifies the dial function for creating unencrypted
-	// TCP connections.
+	// TCP connections, and TIPC connections on the "tipc" network
+	// for "http+tipc" URLs.
 	// If Dial is nil, net.Dial is used.
 	Dial func(network, addr string) (net.Conn, error)
 
@@ -88,6 +106,25 @@ type Transport struct {
 	// wait for a TLS handshake. Zero means no timeout.
 	TLSHandshakeTimeout time.Duration
 
+	// TLSNextProto specifies how the Transport switches to an
+	// alternate protocol (such as HTTP/2) after a TLS NPN/ALPN
+	// protocol negotiation. If Transport dials a TLS connection
+	// with a non-empty protocol name and TLSNextProto contains a
+	// map entry for that key (such as "h2"), then the func is
+	// called with the request's authority (such as "example.com"
+	// or "example.com:1234") and the TLS connection. The function
+	// must return a RoundTripper that then handles the requests
+	// to that authority, sharing the connection.
+	//
+	// HTTP/2 is off by default. To turn it on, list "h2" in the
+	// NextProtos of TLSClientConfig: connections that negotiate
+	// "h2" then use the built-in HTTP/2 support unless
+	// TLSNextProto has an "h2" entry of its own. If TLSNextProto
+	// has an "h2" entry and TLSClientConfig does not list
+	// protocols of its own, the Transport offers "h2" and
+	// "http/1.1".
+	TLSNextProto map[string]func(authority string, c *tls.Conn) RoundTripper
+
 	// DisableKeepAlives, if true, prevents re-use of TCP connections
 	// between different HTTP requests.
 	DisableKeepAlives bool
@@ -107,14 +144,43 @@ type Transport struct {
 	// DefaultMaxIdleConnsPerHost is used.
 	MaxIdleConnsPerHost int
 
+	// MaxIdleConns, if non-zero, controls the maximum number of
+	// idle (keep-alive) connections kept across all hosts. When
+	// the pool is full, the connection idle the longest is
+	// closed to make room. Zero means no limit.
+	MaxIdleConns int
+
+	// MaxConnsPerHost, if non-zero, limits the number of
+	// connections to each host, counting those being dialed, in
+	// use and idle. Requests beyond the limit wait, in order, for
+	// a connection to become idle or for one to close so that
+	// another may be dialed. An HTTP/2 connection, shared by all
+	// requests to its host, counts only while it is being
+	// dialed. Zero means no limit.
+	MaxConnsPerHost int
+
+	// IdleConnTimeout, if non-zero, is the maximum amount of time
+	// an idle (keep-alive) connection stays in the pool before it
+	// is closed.
+	IdleConnTimeout time.Duration
+
 	// ResponseHeaderTimeout, if non-zero, specifies the amount of
 	// time to wait for a server's response headers after fully
 	// writing the request (including its body, if any). This
 	// time does not include the time to read the response body.
 	ResponseHeaderTimeout time.Duration
+}
+
+// ConnPoolStats describes a Transport's connections to one
+// destination. It is returned by Transport.PoolStats.
+type ConnPoolStats struct {
+	// Key identifies the destination: the proxy URL, if any, the
+	// scheme and the target host:port, separated by "|".
+	Key string
 
-	// TODO: tunable on global max cached connections
-	// TODO: tunable on timeout on cached connections
+	Idle    int // idle connections kept for reuse
+	Active  int // connections being dialed or in use
+	Waiting int // requests waiting for a connection under MaxConnsPerHost
 }
 
 // ProxyFromEnvironment returns the URL of the proxy to use for a
@@ -197,7 +263,7 @@ func (t *Transport) RoundTrip(req *Request) (resp *Response, err error) {
 		req.closeBody()
 		return nil, errors.New("http: nil Request.Header")
 	}
-	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" && req.URL.Scheme != "http+tipc" {
 		t.altMu.RLock()
 		var rt RoundTripper
 		if t.altProto != nil {
@@ -225,14 +291,24 @@ func (t *Transport) RoundTrip(req *Request) (resp *Response, err error) {
 	// host (for http or https), the http proxy, or the http proxy
 	// pre-CONNECTed to https server.  In any case, we'll be ready
 	// to send it requests.
-	pconn, err := t.getConn(req, cm)
-	if err != nil {
+	for {
+		pconn, err := t.getConn(req, cm)
+		if err != nil {
+			t.setReqCanceler(req, nil)
+			req.closeBody()
+			return nil, err
+		}
+		if pconn.alt == nil {
+			return pconn.roundTrip(treq)
+		}
 		t.setReqCanceler(req, nil)
-		req.closeBody()
-		return nil, err
+		resp, err := pconn.alt.RoundTrip(req)
+		if err != http2errClientConnUnusable {
+			return resp, err
+		}
+		// The connection stopped taking requests before this
+		// one was sent; try another.
 	}
-
-	return pconn.roundTrip(treq)
 }
 
 // RegisterProtocol registers a new protocol with scheme.
@@ -242,7 +318,7 @@ func (t *Transport) RoundTrip(req *Request) (resp *Response, err error) {
 // RegisterProtocol can be used by other packages to provide
 // implementations of protocol schemes like "ftp" or "file".
 func (t *Transport) RegisterProtocol(scheme string, rt RoundTripper) {
-	if scheme == "http" || scheme == "https" {
+	if scheme == "http" || scheme == "https" || scheme == "http+tipc" {
 		panic("protocol " + scheme + " already registered")
 	}
 	t.altMu.Lock()
@@ -269,11 +345,75 @@ func (t *Transport) CloseIdleConnections() {
 	t.idleMu.Unlock()
 	for _, conns := range m {
 		for _, pconn := range conns {
+			if pconn.idleTimer != nil {
+				pconn.idleTimer.Stop()
+			}
 			pconn.close()
 		}
 	}
 }
 
+// PoolStats returns a snapshot of the Transport's connections, one
+// entry per destination, sorted by Key. It may be called while
+// requests are in flight, for example to publish the statistics with
+// package expvar:
+//
+//	expvar.Publish("httpPool", expvar.Func(func() interface{} {
+//		return tr.PoolStats()
+//	}))
+func (t *Transport) PoolStats() []ConnPoolStats {
+	m := make(map[connectMethodKey]*ConnPoolStats)
+	stats := func(key connectMethodKey) *ConnPoolStats {
+		s := m[key]
+		if s == nil {
+			s = &ConnPoolStats{Key: key.String()}
+			m[key] = s
+		}
+		return s
+	}
+	t.connsPerHostMu.Lock()
+	for key, n := range t.connsPerHost {
+		s := stats(key)
+		s.Active = n
+		s.Waiting = len(t.connsPerHostWait[key])
+	}
+	t.connsPerHostMu.Unlock()
+	t.idleMu.Lock()
+	for key, pconns := range t.idleConn {
+		s := stats(key)
+		for _, pc := range pconns {
+			if pc.isBroken() {
+				// Closed, or about to leave the pool.
+				continue
+			}
+			if pc.alt != nil {
+				s.Active++
+			} else {
+				s.Idle++
+				s.Active--
+			}
+		}
+	}
+	t.idleMu.Unlock()
+
+	all := make([]ConnPoolStats, 0, len(m))
+	for _, s := range m {
+		if s.Active < 0 {
+			// A connection closed between the two snapshots.
+			s.Active = 0
+		}
+		all = append(all, *s)
+	}
+	sort.Sort(byPoolKey(all))
+	return all
+}
+
+type byPoolKey []ConnPoolStats
+
+func (s byPoolKey) Len() int           { return len(s) }
+func (s byPoolKey) Less(i, j int) bool { return s[i].Key < s[j].Key }
+func (s byPoolKey) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
 // CancelRequest cancels an in-flight request by closing its
 // connection.
 func (t *Transport) CancelRequest(req *Request) {
@@ -332,6 +472,10 @@ func (e *envOnce) reset() {
 
 func (t *Transport) connectMethodForRequest(treq *transportRequest) (cm connectMethod, err error) {
 	cm.targetScheme = treq.URL.Scheme
+	if cm.targetScheme == "http+tipc" {
+		cm.targetAddr, err = tipcName(treq.URL.Host)
+		return cm, err
+	}
 	cm.targetAddr = canonicalAddr(treq.URL)
 	if t.Proxy != nil {
 		cm.proxyURL, err = t.Proxy(treq.Request)
@@ -365,6 +509,9 @@ func (t *Transport) putIdleConn(pconn *persistConn) bool {
 	if pconn.isBroken() {
 		return false
 	}
+	if pconn.alt != nil {
+		return t.putAltConn(pconn)
+	}
 	key := pconn.cacheKey
 	max := t.MaxIdleConnsPerHost
 	if max == 0 {
@@ -397,7 +544,7 @@ func (t *Transport) putIdleConn(pconn *persistConn) bool {
 	if t.idleConn == nil {
 		t.idleConn = make(map[connectMethodKey][]*persistConn)
 	}
-	if len(t.idleConn[key]) >= max {
+	if idleConnCount(t.idleConn[key]) >= max {
 		t.idleMu.Unlock()
 		pconn.close()
 		return false
@@ -407,6 +554,141 @@ func (t *Transport) putIdleConn(pconn *persistConn) bool {
 			log.Fatalf("dup idle pconn %p in freelist", pconn)
 		}
 	}
+	var evicted *persistConn
+	if t.MaxIdleConns > 0 && t.idleCountLocked() >= t.MaxIdleConns {
+		evicted = t.oldestIdleConnLocked()
+		t.removeIdleConnLocked(evicted)
+	}
+	pconn.idleAt = time.Now()
+	if d := t.IdleConnTimeout; d > 0 {
+		if pconn.idleTimer != nil {
+			pconn.idleTimer.Reset(d)
+		} else {
+			pconn.idleTimer = time.AfterFunc(d, pconn.closeConnIfStillIdle)
+		}
+	}
+	t.idleConn[key] = append(t.idleConn[key], pconn)
+	t.idleMu.Unlock()
+	if evicted != nil {
+		evicted.close()
+	}
+	return true
+}
+
+// idleConnCount returns the number of usable idle HTTP/1 connections
+// in pconns.
+func idleConnCount(pconns []*persistConn) int {
+	n := 0
+	for _, pc := range pconns {
+		if pc.alt == nil && !pc.isBroken() {
+			n++
+		}
+	}
+	return n
+}
+
+// idleCountLocked returns the number of usable idle HTTP/1
+// connections in the pool. t.idleMu must be held.
+func (t *Transport) idleCountLocked() int {
+	n := 0
+	for _, pconns := range t.idleConn {
+		n += idleConnCount(pconns)
+	}
+	return n
+}
+
+// oldestIdleConnLocked returns the usable HTTP/1 connection that has
+// been idle the longest, or nil. t.idleMu must be held.
+func (t *Transport) oldestIdleConnLocked() *persistConn {
+	var oldest *persistConn
+	for _, pconns := range t.idleConn {
+		for _, pc := range pconns {
+			if pc.alt == nil && !pc.isBroken() && (oldest == nil || pc.idleAt.Before(oldest.idleAt)) {
+				oldest = pc
+			}
+		}
+	}
+	return oldest
+}
+
+// removeIdleConnLocked removes pconn from the idle pool and reports
+// whether it was there. t.idleMu must be held.
+func (t *Transport) removeIdleConnLocked(pconn *persistConn) bool {
+	if pconn.idleTimer != nil {
+		pconn.idleTimer.Stop()
+	}
+	key := pconn.cacheKey
+	pconns := t.idleConn[key]
+	for i, pc := range pconns {
+		if pc != pconn {
+			continue
+		}
+		if len(pconns) == 1 {
+			delete(t.idleConn, key)
+		} else {
+			copy(pconns[i:], pconns[i+1:])
+			pconns[len(pconns)-1] = nil
+			t.idleConn[key] = pconns[:len(pconns)-1]
+		}
+		return true
+	}
+	return false
+}
+
+// removeIdleConn removes pconn from the idle pool.
+func (t *Transport) removeIdleConn(pconn *persistConn) {
+	t.idleMu.Lock()
+	t.removeIdleConnLocked(pconn)
+	t.idleMu.Unlock()
+}
+
+// closeConnIfStillIdle closes pc once IdleConnTimeout has passed,
+// unless it was taken from the pool in the meantime.
+func (pc *persistConn) closeConnIfStillIdle() {
+	t := pc.t
+	t.idleMu.Lock()
+	idle := t.removeIdleConnLocked(pc)
+	t.idleMu.Unlock()
+	if idle {
+		pc.close()
+	}
+}
+
+// putAltConn adds pconn, a connection handed to a TLSNextProto
+// RoundTripper, to the pool. Such connections serve any number of
+// requests at once, so they stay in the pool while they are usable,
+// and dialers waiting for a connection to the same host all share
+// it.  A second connection to a host that already has one is closed
+// once idle.
+func (t *Transport) putAltConn(pconn *persistConn) bool {
+	key := pconn.cacheKey
+	t.idleMu.Lock()
+	for shared := true; shared; {
+		select {
+		case t.idleConnCh[key] <- pconn:
+		default:
+			shared = false
+		}
+	}
+	if t.wantIdle {
+		t.idleMu.Unlock()
+		pconn.close()
+		return false
+	}
+	if t.idleConn == nil {
+		t.idleConn = make(map[connectMethodKey][]*persistConn)
+	}
+	for _, exist := range t.idleConn[key] {
+		if exist == pconn {
+			t.idleMu.Unlock()
+			return true
+		}
+		if exist.alt != nil && !exist.isBroken() {
+			t.idleMu.Unlock()
+			pconn.close()
+			return false
+		}
+	}
 	t.idleConn[key] = append(t.idleConn[key], pconn)
 	t.idleMu.Unlock()
 	return true
@@ -446,6 +728,10 @@ func (t *Transport) getIdleConn(cm connectMethod) (pconn *persistConn) {
 		if !ok {
 			return nil
 		}
+		if pc := pconns[len(pconns)-1]; pc.alt != nil && !pc.isBroken() {
+			// Shared by all requests; it stays in the pool.
+			return pc
+		}
 		if len(pconns) == 1 {
 			pconn = pconns[0]
 			delete(t.idleConn, key)
@@ -455,6 +741,9 @@ func (t *Transport) getIdleConn(cm connectMethod) (pconn *persistConn) {
 			pconn = pconns[len(pconns)-1]
 			t.idleConn[key] = pconns[:len(pconns)-1]
 		}
+		if pconn.idleTimer != nil {
+			pconn.idleTimer.Stop()
+		}
 		if !pconn.isBroken() {
 			return
 		}
@@ -498,8 +787,10 @@ func (t *Transport) getConn(req *Request, cm connectMethod) (*persistConn, error
 		err error
 	}
 	dialc := make(chan dialRes)
+	abandonc := make(chan struct{})
 
 	handlePendingDial := func() {
+		close(abandonc)
 		if prePendingDial != nil {
 			prePendingDial()
 		}
@@ -515,9 +806,19 @@ func (t *Transport) getConn(req *Request, cm connectMethod) (*persistConn, error
 
 	cancelc := make(chan struct{})
 	t.setReqCanceler(req, func() { close(cancelc) })
+	deadlinec, stopTimer := req.deadlineTimer()
+	defer stopTimer()
 
 	go func() {
+		key := cm.key()
+		if !t.acquireConnSlot(key, abandonc) {
+			dialc <- dialRes{nil, errConnSlotAbandoned}
+			return
+		}
 		pc, err := t.dialConn(cm)
+		if err != nil || pc.alt != nil {
+			t.releaseConnSlot(key)
+		}
 		dialc <- dialRes{pc, err}
 	}()
 
@@ -525,6 +826,9 @@ func (t *Transport) getConn(req *Request, cm connectMethod) (*persistConn, error
 	select {
 	case v := <-dialc:
 		// Our dial finished.
+		if v.err == nil && v.pc.alt != nil {
+			t.putIdleConn(v.pc)
+		}
 		return v.pc, v.err
 	case pc := <-idleConnCh:
 		// Another request finished first and its net.Conn
@@ -537,6 +841,82 @@ func (t *Transport) getConn(req *Request, cm connectMethod) (*persistConn, error
 	case <-cancelc:
 		handlePendingDial()
 		return nil, errors.New("net/http: request canceled while waiting for connection")
+	case <-req.Cancel:
+		handlePendingDial()
+		return nil, errors.New("net/http: request canceled while waiting for connection")
+	case <-deadlinec:
+		handlePendingDial()
+		return nil, errDeadlineConn
+	}
+}
+
+// errConnSlotAbandoned is the dial result of a request that stopped
+// waiting before MaxConnsPerHost let it dial.
+var errConnSlotAbandoned = errors.New("net/http: dial abandoned while waiting for a connection slot")
+
+// acquireConnSlot counts a new connection to key, first waiting, if
+// MaxConnsPerHost connections are already counted, for one of them
+// to be released. It reports false if abandon was closed before the
+// connection could be counted.
+func (t *Transport) acquireConnSlot(key connectMethodKey, abandon <-chan struct{}) bool {
+	t.connsPerHostMu.Lock()
+	if t.connsPerHost == nil {
+		t.connsPerHost = make(map[connectMethodKey]int)
+	}
+	if max := t.MaxConnsPerHost; max <= 0 || t.connsPerHost[key] < max {
+		t.connsPerHost[key]++
+		t.connsPerHostMu.Unlock()
+		return true
+	}
+	if t.connsPerHostWait == nil {
+		t.connsPerHostWait = make(map[connectMethodKey][]chan struct{})
+	}
+	ch := make(chan struct{})
+	t.connsPerHostWait[key] = append(t.connsPerHostWait[key], ch)
+	t.connsPerHostMu.Unlock()
+
+	select {
+	case <-ch:
+		// The slot of a released connection was handed to us.
+		return true
+	case <-abandon:
+	}
+	t.connsPerHostMu.Lock()
+	q := t.connsPerHostWait[key]
+	for i, c := range q {
+		if c == ch {
+			t.connsPerHostWait[key] = append(q[:i:i], q[i+1:]...)
+			if len(q) == 1 {
+				delete(t.connsPerHostWait, key)
+			}
+			t.connsPerHostMu.Unlock()
+			return false
+		}
+	}
+	t.connsPerHostMu.Unlock()
+	// The slot was handed to us just as we gave up; pass it on.
+	t.releaseConnSlot(key)
+	return false
+}
+
+// releaseConnSlot uncounts a connection to key, handing its slot to
+// the first dialer waiting for one.
+func (t *Transport) releaseConnSlot(key connectMethodKey) {
+	t.connsPerHostMu.Lock()
+	defer t.connsPerHostMu.Unlock()
+	if q := t.connsPerHostWait[key]; len(q) > 0 {
+		close(q[0])
+		if len(q) == 1 {
+			delete(t.connsPerHostWait, key)
+		} else {
+			t.connsPerHostWait[key] = q[1:]
+		}
+		return
+	}
+	if n := t.connsPerHost[key]; n > 1 {
+		t.connsPerHost[key] = n - 1
+	} else {
+		delete(t.connsPerHost, key)
 	}
 }
 
@@ -559,9 +939,16 @@ func (t *Transport) dialConn(cm connectMethod) (*persistConn, error) {
 		if tc, ok := pconn.conn.(*tls.Conn); ok {
 			cs := tc.ConnectionState()
 			pconn.tlsState = &cs
+			if next := t.nextProto(cs.NegotiatedProtocol); next != nil {
+				return t.altConn(pconn, cm, tc, next)
+			}
 		}
 	} else {
-		conn, err := t.dial("tcp", cm.addr())
+		network := "tcp"
+		if cm.targetScheme == "http+tipc" {
+			network = "tipc"
+		}
+		conn, err := t.dial(network, cm.addr())
 		if err != nil {
 			if cm.proxyURL != nil {
 				err = fmt.Errorf("http: error connecting to proxy %s: %v", cm.proxyURL, err)
@@ -614,6 +1001,14 @@ func (t *Transport) dialConn(cm connectMethod) (*persistConn, error) {
 	if cm.targetScheme == "https" && !tlsDial {
 		// Initiate TLS and check remote host name against certificate.
 		cfg := t.TLSClientConfig
+		if t.TLSNextProto[http2NextProtoTLS] != nil && !t.DisableKeepAlives && (cfg == nil || len(cfg.NextProtos) == 0) {
+			var clone tls.Config
+			if cfg != nil {
+				clone = *cfg
+			}
+			clone.NextProtos = []string{http2NextProtoTLS, "http/1.1"}
+			cfg = &clone
+		}
 		if cfg == nil || cfg.ServerName == "" {
 			host := cm.tlsHost()
 			if cfg == nil {
@@ -653,6 +1048,9 @@ func (t *Transport) dialConn(cm connectMethod) (*persistConn, error) {
 		cs := tlsConn.ConnectionState()
 		pconn.tlsState = &cs
 		pconn.conn = tlsConn
+		if next := t.nextProto(cs.NegotiatedProtocol); next != nil {
+			return t.altConn(pconn, cm, tlsConn, next)
+		}
 	}
 
 	pconn.br = bufio.NewReader(noteEOFReader{pconn.conn, &pconn.sawEOF})
@@ -662,6 +1060,48 @@ func (t *Transport) dialConn(cm connectMethod) (*persistConn, error) {
 	return pconn, nil
 }
 
+// nextProto returns the function that takes over TLS connections on
+// which proto was negotiated, or nil if they speak HTTP/1.
+func (t *Transport) nextProto(proto string) func(string, *tls.Conn) RoundTripper {
+	if proto == "" || proto == "http/1.1" {
+		return nil
+	}
+	if fn := t.TLSNextProto[proto]; fn != nil {
+		return fn
+	}
+	if proto == http2NextProtoTLS {
+		return t.http2NextProto
+	}
+	return nil
+}
+
+// http2NextProto takes over "h2" connections that TLSNextProto does
+// not.
+func (t *Transport) http2NextProto(authority string, c *tls.Conn) RoundTripper {
+	cc, err := t.newHTTP2ClientConn(c)
+	if err != nil {
+		return nil
+	}
+	return cc
+}
+
+// altConn hands the TLS connection c of pconn over to next and
+// returns pconn, through which the Transport then sends requests to
+// the returned RoundTripper.
+func (t *Transport) altConn(pconn *persistConn, cm connectMethod, c *tls.Conn, next func(string, *tls.Conn) RoundTripper) (*persistConn, error) {
+	pconn.alt = next(cm.targetAddr, c)
+	if pconn.alt == nil {
+		c.Close()
+		return nil, errors.New("http: cannot use protocol " + pconn.tlsState.NegotiatedProtocol + " negotiated with " + cm.targetAddr)
+	}
+	if cc, ok := pconn.alt.(*http2clientConn); ok {
+		// The pool holds on to pconn for as long as cc can
+		// take requests; it must let go once cc is gone.
+		cc.setOnClosed(func() { t.removeIdleConn(pconn) })
+	}
+	return pconn, nil
+}
+
 // useProxy returns true if requests to addr should use a proxy,
 // according to the NO_PROXY or no_proxy environment variable.
 // addr is always a canonicalAddr with a host and port.
@@ -724,6 +1164,7 @@ func useProxy(addr string) bool {
 // -----------------             -------------------------
 // |http|foo.com                 http directly to server, no proxy
 // |https|foo.com                https directly to server, no proxy
+// |http+tipc|{1000,42}          http over TIPC to the name {1000,42}
 // http://proxy.com|https|foo.com  http to proxy, then CONNECT to foo.com
 // http://proxy.com|http           http to proxy, http to anywhere after that
 //
@@ -731,7 +1172,7 @@ func useProxy(addr string) bool {
 //
 type connectMethod struct {
 	proxyURL     *url.URL // nil for no proxy, else full proxy URL
-	targetScheme string   // "http" or "https"
+	targetScheme string   // "http", "https" or "http+tipc"
 	targetAddr   string   // Not used if proxy + http targetScheme (4th example in table)
 }
 
@@ -777,7 +1218,6 @@ type connectMethodKey struct {
 }
 
 func (k connectMethodKey) String() string {
-	// Only used by tests.
 	return fmt.Sprintf("%s|%s|%s", k.proxy, k.scheme, k.addr)
 }
 
@@ -788,6 +1228,7 @@ type persistConn struct {
 	cacheKey connectMethodKey
 	conn     net.Conn
 	tlsState *tls.ConnectionState
+	alt      RoundTripper        // TLSNextProto RoundTripper using conn, or nil
 	br       *bufio.Reader       // from conn
 	sawEOF   bool                // whether we've seen EOF from conn; owned by readLoop
 	bw       *bufio.Writer       // to conn
@@ -801,6 +1242,10 @@ type persistConn struct {
 	// whether or not a connection can be reused. Issue 7569.
 	writeErrCh chan error
 
+	// Guarded by t.idleMu.
+	idleAt    time.Time   // when the connection was last put in the idle pool
+	idleTimer *time.Timer // closes the connection after IdleConnTimeout
+
 	lk                   sync.Mutex // guards following fields
 	numExpectedResponses int
 	closed               bool // whether conn has been closed
@@ -816,6 +1261,9 @@ func (pc *persistConn) isBroken() bool {
 	pc.lk.Lock()
 	b := pc.broken
 	pc.lk.Unlock()
+	if cc, ok := pc.alt.(*http2clientConn); ok && !b {
+		b = !cc.canTakeNewRequest()
+	}
 	return b
 }
 
@@ -896,16 +1344,18 @@ func (pc *persistConn) readLoop() {
 		}
 
 		var waitForBodyRead chan bool
+		var body *bodyEOFSignal // resp.Body may be replaced once resp is sent
 		if hasBody {
 			waitForBodyRead = make(chan bool, 2)
-			resp.Body.(*bodyEOFSignal).earlyCloseFn = func() error {
+			body = resp.Body.(*bodyEOFSignal)
+			body.earlyCloseFn = func() error {
 				// Sending false here sets alive to
 				// false and closes the connection
 				// below.
 				waitForBodyRead <- false
 				return nil
 			}
-			resp.Body.(*bodyEOFSignal).fn = func(err error) {
+			body.fn = func(err error) {
 				waitForBodyRead <- alive &&
 					err == nil &&
 					!pc.sawEOF &&
@@ -925,11 +1375,19 @@ func (pc *persistConn) readLoop() {
 		// Wait for the just-returned response body to be fully consumed
 		// before we race and peek on the underlying bufio reader.
 		if waitForBodyRead != nil {
+			deadlinec, stopTimer := rc.req.deadlineTimer()
 			select {
 			case alive = <-waitForBodyRead:
 			case <-pc.closech:
 				alive = false
+			case <-rc.req.Cancel:
+				alive = false
+				body.cancel(errRequestCanceled)
+			case <-deadlinec:
+				alive = false
+				body.cancel(errDeadline)
 			}
+			stopTimer()
 		}
 
 		pc.t.setReqCanceler(rc.req, nil)
@@ -1027,6 +1485,9 @@ func (e *httpError) Temporary() bool { return true }
 
 var errTimeout error = &httpError{err: "net/http: timeout awaiting response headers", timeout: true}
 var errClosed error = &httpError{err: "net/http: transport closed before response was received"}
+var errRequestCanceled = errors.New("net/http: request canceled")
+var errDeadline error = &httpError{err: "net/http: request deadline exceeded", timeout: true}
+var errDeadlineConn error = &httpError{err: "net/http: request deadline exceeded while waiting for connection", timeout: true}
 
 func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err error) {
 	pc.t.setReqCanceler(req.Request, pc.cancelRequest)
@@ -1073,6 +1534,9 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 	resc := make(chan responseAndError, 1)
 	pc.reqch <- requestAndChan{req.Request, resc, requestedGzip}
 
+	deadlinec, stopTimer := req.deadlineTimer()
+	defer stopTimer()
+
 	var re responseAndError
 	var pconnDeadCh = pc.closech
 	var failTicker <-chan time.Time
@@ -1111,6 +1575,14 @@ WaitResponse:
 			pc.close()
 			re = responseAndError{err: errTimeout}
 			break WaitResponse
+		case <-req.Cancel:
+			pc.close()
+			re = responseAndError{err: errRequestCanceled}
+			break WaitResponse
+		case <-deadlinec:
+			pc.close()
+			re = responseAndError{err: errDeadline}
+			break WaitResponse
 		case re = <-resc:
 			break WaitResponse
 		}
@@ -1143,10 +1615,20 @@ func (pc *persistConn) close() {
 
 func (pc *persistConn) closeLocked() {
 	pc.broken = true
+	if pc.alt != nil {
+		// Requests in flight on the connection are left to
+		// finish.  An HTTP/2 connection leaves the idle pool
+		// once it is closed.
+		if cc, ok := pc.alt.(*http2clientConn); ok {
+			cc.closeIfIdle()
+		}
+		return
+	}
 	if !pc.closed {
 		pc.conn.Close()
 		pc.closed = true
 		close(pc.closech)
+		pc.t.releaseConnSlot(pc.cacheKey)
 	}
 	pc.mutateHeaderFunc = nil
 }
@@ -1165,6 +1647,25 @@ func canonicalAddr(url *url.URL) string {
 	return addr
 }
 
+// tipcName returns the TIPC name, in the form accepted by
+// net.ResolveTIPCAddr, of the host of an "http+tipc" URL, which is
+// written "service.instance".
+func tipcName(host string) (string, error) {
+	i := strings.Index(host, ".")
+	if i < 0 {
+		return "", &badStringError{"malformed TIPC name in URL host", host}
+	}
+	service, err := strconv.ParseUint(host[:i], 10, 32)
+	if err != nil {
+		return "", &badStringError{"malformed TIPC name in URL host", host}
+	}
+	instance, err := strconv.ParseUint(host[i+1:], 10, 32)
+	if err != nil {
+		return "", &badStringError{"malformed TIPC name in URL host", host}
+	}
+	return "{" + strconv.FormatUint(service, 10) + "," + strconv.FormatUint(instance, 10) + "}", nil
+}
+
 // bodyEOFSignal wraps a ReadCloser but runs fn (if non-nil) at most
 // once, right before its final (error-producing) Read or Close call
 // returns. If earlyCloseFn is non-nil and Close is called before
@@ -1197,11 +1698,23 @@ func (es *bodyEOFSignal) Read(p []byte) (n int, err error) {
 		if es.rerr == nil {
 			es.rerr = err
 		}
+		err = es.rerr
 		es.condfn(err)
 	}
 	return
 }
 
+// cancel makes Read return err from now on, unless the body has
+// already ended. The caller closes the connection under the body to
+// interrupt a Read in progress.
+func (es *bodyEOFSignal) cancel(err error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.rerr == nil {
+		es.rerr = err
+	}
+}
+
 func (es *bodyEOFSignal) Close() error {
 	es.mu.Lock()
 	defer es.mu.Unlock()
@@ -23,6 +23,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
@@ -404,6 +405,196 @@ func TestTransportMaxPerHostIdleConns(t *testing.T) {
 	}
 }
 
+// waitPoolStats polls tr.PoolStats until its only entry is want.
+func waitPoolStats(t *testing.T, tr *Transport, want ConnPoolStats) {
+	var got []ConnPoolStats
+	for i := 0; i < 100; i++ {
+		got = tr.PoolStats()
+		if len(got) == 1 && got[0] == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("PoolStats = %+v; want [%+v]", got, want)
+}
+
+func TestTransportMaxConnsPerHost(t *testing.T) {
+	defer afterTest(t)
+	release := make(chan bool)
+	gotReq := make(chan bool, 10)
+	var mu sync.Mutex
+	addrs := make(map[string]bool)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		mu.Lock()
+		addrs[r.RemoteAddr] = true
+		mu.Unlock()
+		gotReq <- true
+		<-release
+	}))
+	defer ts.Close()
+	tr := &Transport{MaxConnsPerHost: 2}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+	key := "|http|" + ts.Listener.Addr().String()
+
+	const n = 5
+	errc := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			res, err := c.Get(ts.URL)
+			if err == nil {
+				_, err = ioutil.ReadAll(res.Body)
+				res.Body.Close()
+			}
+			errc <- err
+		}()
+	}
+	<-gotReq
+	<-gotReq
+	waitPoolStats(t, tr, ConnPoolStats{Key: key, Active: 2, Waiting: n - 2})
+
+	// A queued request can be canceled.
+	cancel := make(chan struct{})
+	req, _ := NewRequest("GET", ts.URL, nil)
+	req.Cancel = cancel
+	time.AfterFunc(50*time.Millisecond, func() { close(cancel) })
+	if _, err := c.Do(req); err == nil || !strings.Contains(err.Error(), "canceled while waiting for connection") {
+		t.Errorf("canceled queued request: err = %v", err)
+	}
+	waitPoolStats(t, tr, ConnPoolStats{Key: key, Active: 2, Waiting: n - 2})
+
+	close(release)
+	for i := 0; i < n; i++ {
+		if err := <-errc; err != nil {
+			t.Error(err)
+		}
+	}
+	if len(addrs) > 2 {
+		t.Errorf("server saw %d connections; want at most 2", len(addrs))
+	}
+	waitPoolStats(t, tr, ConnPoolStats{Key: key, Idle: 2})
+}
+
+// Tests that an HTTP/2 connection leaves the pool, and PoolStats,
+// once the server closes it.
+func TestTransportDropsClosedHTTP2Conn(t *testing.T) {
+	defer afterTest(t)
+	var mu sync.Mutex
+	var conns []net.Conn
+	ts := httptest.NewUnstartedServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	ts.Config.ConnState = func(c net.Conn, state ConnState) {
+		if state == StateNew {
+			mu.Lock()
+			conns = append(conns, c)
+			mu.Unlock()
+		}
+	}
+	startHTTP2(ts)
+	defer ts.Close()
+	tr := newHTTP2Transport(t, ts)
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+	key := "|https|" + ts.Listener.Addr().String()
+
+	get := func() {
+		res, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if string(body) != "HTTP/2.0" {
+			t.Fatalf("request proto = %q; want HTTP/2.0", body)
+		}
+	}
+	get()
+	waitPoolStats(t, tr, ConnPoolStats{Key: key, Active: 1})
+
+	mu.Lock()
+	for _, c := range conns {
+		c.Close()
+	}
+	mu.Unlock()
+	for i := 0; ; i++ {
+		if len(tr.IdleConnKeysForTesting()) == 0 && len(tr.PoolStats()) == 0 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("after the server closed the connection, idle keys = %v, PoolStats = %+v; want none",
+				tr.IdleConnKeysForTesting(), tr.PoolStats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	get()
+	waitPoolStats(t, tr, ConnPoolStats{Key: key, Active: 1})
+}
+
+func TestTransportMaxIdleConns(t *testing.T) {
+	defer afterTest(t)
+	ts1 := httptest.NewServer(hostPortHandler)
+	defer ts1.Close()
+	ts2 := httptest.NewServer(hostPortHandler)
+	defer ts2.Close()
+	tr := &Transport{MaxIdleConns: 1}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	for _, ts := range []*httptest.Server{ts1, ts2} {
+		res, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(res.Body)
+		res.Body.Close()
+	}
+	keys := tr.IdleConnKeysForTesting()
+	if want := "|http|" + ts2.Listener.Addr().String(); len(keys) != 1 || keys[0] != want {
+		t.Errorf("idle conn keys = %q; want [%q]", keys, want)
+	}
+}
+
+func TestTransportIdleConnTimeout(t *testing.T) {
+	defer afterTest(t)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, r.RemoteAddr)
+	}))
+	defer ts.Close()
+	tr := &Transport{IdleConnTimeout: 100 * time.Millisecond}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+	key := "|http|" + ts.Listener.Addr().String()
+
+	get := func() string {
+		res, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		return string(body)
+	}
+	a1, a2 := get(), get()
+	if a1 != a2 {
+		t.Errorf("second request used a new connection")
+	}
+	if n := tr.IdleConnCountForTesting(key); n != 1 {
+		t.Errorf("idle conns = %d; want 1", n)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if n := tr.IdleConnCountForTesting(key); n != 0 {
+		t.Errorf("idle conns after IdleConnTimeout = %d; want 0", n)
+	}
+	if len(tr.PoolStats()) != 0 {
+		t.Errorf("PoolStats after IdleConnTimeout = %+v; want none", tr.PoolStats())
+	}
+	if a3 := get(); a3 == a2 {
+		t.Errorf("request after IdleConnTimeout reused the connection")
+	}
+}
+
 func TestTransportServerClosingUnexpectedly(t *testing.T) {
 	defer afterTest(t)
 	ts := httptest.NewServer(hostPortHandler)
@@ -1422,6 +1613,164 @@ Get = Get http://something.no-network.tld/: net/http: request canceled while wai
 	}
 }
 
+// Tests that closing Request.Cancel interrupts a request waiting for
+// its response, and one whose response body is being read.
+func TestTransportCancelRequestChan(t *testing.T) {
+	defer afterTest(t)
+	unblock := make(chan bool)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/body" {
+			w.WriteHeader(200)
+			w.(Flusher).Flush()
+		}
+		<-unblock
+	}))
+	defer ts.Close()
+	defer close(unblock)
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	cancel := make(chan struct{})
+	req, _ := NewRequest("GET", ts.URL+"/header", nil)
+	req.Cancel = cancel
+	time.AfterFunc(50*time.Millisecond, func() { close(cancel) })
+	if _, err := c.Do(req); err == nil || !strings.Contains(err.Error(), "request canceled") {
+		t.Errorf("Do = %v; want request canceled", err)
+	}
+
+	cancel = make(chan struct{})
+	req, _ = NewRequest("GET", ts.URL+"/body", nil)
+	req.Cancel = cancel
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(cancel)
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err == nil || err.Error() != "net/http: request canceled" {
+		t.Errorf("reading body = %q, %v; want request canceled", body, err)
+	}
+	if n := tr.NumPendingRequestsForTesting(); n != 0 {
+		t.Errorf("%d pending requests after cancel", n)
+	}
+}
+
+// Tests that Request.Cancel can interrupt reading a body that
+// Client.Timeout has wrapped.
+func TestTransportCancelRequestChanWithClientTimeout(t *testing.T) {
+	defer afterTest(t)
+	unblock := make(chan bool)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.WriteHeader(200)
+		w.(Flusher).Flush()
+		<-unblock
+	}))
+	defer ts.Close()
+	defer close(unblock)
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr, Timeout: 10 * time.Second}
+
+	cancel := make(chan struct{})
+	req, _ := NewRequest("GET", ts.URL, nil)
+	req.Cancel = cancel
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(50*time.Millisecond, func() { close(cancel) })
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err == nil || !strings.Contains(err.Error(), "request canceled") {
+		t.Errorf("reading body = %q, %v; want request canceled", body, err)
+	}
+}
+
+func TestTransportCancelRequestChanInDial(t *testing.T) {
+	defer afterTest(t)
+	// The canceled dial finishes after RoundTrip returns; wait for
+	// it so that its goroutine is not seen as leaked.
+	var wg sync.WaitGroup
+	SetPendingDialHooks(func() { wg.Add(1) }, wg.Done)
+	defer SetPendingDialHooks(nil, nil)
+	defer wg.Wait()
+	unblockDial := make(chan bool)
+	defer close(unblockDial)
+	inDial := make(chan bool)
+	tr := &Transport{
+		Dial: func(network, addr string) (net.Conn, error) {
+			inDial <- true
+			<-unblockDial
+			return nil, errors.New("nope")
+		},
+	}
+	cancel := make(chan struct{})
+	req, _ := NewRequest("GET", "http://something.no-network.tld/", nil)
+	req.Cancel = cancel
+	errc := make(chan error, 1)
+	go func() {
+		_, err := tr.RoundTrip(req)
+		errc <- err
+	}()
+	<-inDial
+	close(cancel)
+	select {
+	case err := <-errc:
+		if want := "net/http: request canceled while waiting for connection"; err == nil || err.Error() != want {
+			t.Errorf("RoundTrip = %v; want %q", err, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for canceled dial")
+	}
+}
+
+func TestTransportRequestDeadline(t *testing.T) {
+	defer afterTest(t)
+	unblock := make(chan bool)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/body" {
+			w.WriteHeader(200)
+			w.(Flusher).Flush()
+		}
+		<-unblock
+	}))
+	defer ts.Close()
+	defer close(unblock)
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+
+	isTimeout := func(err error) bool {
+		ne, ok := err.(net.Error)
+		return ok && ne.Timeout()
+	}
+	req, _ := NewRequest("GET", ts.URL+"/header", nil)
+	req.Deadline = time.Now().Add(50 * time.Millisecond)
+	if _, err := tr.RoundTrip(req); !isTimeout(err) {
+		t.Errorf("RoundTrip = %v; want a timeout error", err)
+	}
+
+	req, _ = NewRequest("GET", ts.URL+"/body", nil)
+	req.Deadline = time.Now().Add(200 * time.Millisecond)
+	res, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if !isTimeout(err) {
+		t.Errorf("reading body = %v; want a timeout error", err)
+	}
+
+	// A Deadline that has already passed fails the request.
+	req, _ = NewRequest("GET", ts.URL+"/header", nil)
+	req.Deadline = time.Now().Add(-time.Second)
+	if _, err := tr.RoundTrip(req); !isTimeout(err) {
+		t.Errorf("RoundTrip with passed deadline = %v; want a timeout error", err)
+	}
+}
+
 // golang.org/issue/3672 -- Client can't close HTTP stream
 // Calling Close on a Response.Body used to just read until EOF.
 // Now it actually closes the TCP connection.
@@ -1532,6 +1881,52 @@ func TestTransportNoHost(t *testing.T) {
 	}
 }
 
+func TestTransportTIPC(t *testing.T) {
+	defer afterTest(t)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "%s %s", r.Host, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	var dials []string
+	tr := &Transport{
+		Dial: func(n, addr string) (net.Conn, error) {
+			dials = append(dials, n+" "+addr)
+			return net.Dial("tcp", ts.Listener.Addr().String())
+		},
+		Proxy: func(*Request) (*url.URL, error) {
+			return nil, errors.New("proxy used for TIPC request")
+		},
+	}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+	for _, path := range []string{"/foo", "/bar"} {
+		res, err := c.Get("http+tipc://1000.42" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "1000.42 " + path; string(body) != want {
+			t.Errorf("got response %q; want %q", body, want)
+		}
+	}
+	// Both requests share one connection to the TIPC name.
+	if want := []string{"tipc {1000,42}"}; !reflect.DeepEqual(dials, want) {
+		t.Errorf("dials = %q; want %q", dials, want)
+	}
+
+	for _, host := range []string{"1000", "1000.x", "1000.42:80", "4294967296.1"} {
+		_, err := c.Get("http+tipc://" + host + "/")
+		if err == nil || !strings.Contains(err.Error(), "malformed TIPC name") {
+			t.Errorf("Get with host %q: err = %v; want malformed TIPC name", host, err)
+		}
+	}
+}
+
 func TestTransportSocketLateBinding(t *testing.T) {
 	defer afterTest(t)
 
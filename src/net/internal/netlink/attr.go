@@ -0,0 +1,117 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package netlink implements the generic netlink messages shared by
+// the TIPC support in net and net/tipcconfig.
+//
+// Attributes and requests are encoded in the byte order of the host.
+// The layouts from linux/netlink.h and linux/genetlink.h are spelled
+// out so that requests can be built on any system; only Linux
+// can parse the replies.
+package netlink
+
+import (
+	"errors"
+	"unsafe"
+)
+
+const (
+	attrHdrLen  = 4 // struct nlattr
+	attrAlignTo = 4
+
+	attrNested   = 0x8000 // NLA_F_NESTED
+	attrTypeMask = 0x3fff // ^(NLA_F_NESTED | NLA_F_NET_BYTEORDER)
+)
+
+// ErrMalformed is returned for netlink messages and attributes that
+// cannot be decoded.
+var ErrMalformed = errors.New("malformed netlink message")
+
+func attrAlignOf(attrlen int) int {
+	return (attrlen + attrAlignTo - 1) & ^(attrAlignTo - 1)
+}
+
+// An Attr is a netlink attribute.  The value of a nested attribute
+// holds the encoded nested attributes.
+type Attr struct {
+	Type  uint16
+	Value []byte
+}
+
+// Uint32Attr returns the attribute typ holding v.
+func Uint32Attr(typ uint16, v uint32) Attr {
+	b := make([]byte, 4)
+	*(*uint32)(unsafe.Pointer(&b[0])) = v
+	return Attr{Type: typ, Value: b}
+}
+
+// StringAttr returns the attribute typ holding s, NUL-terminated.
+func StringAttr(typ uint16, s string) Attr {
+	return Attr{Type: typ, Value: append([]byte(s), 0)}
+}
+
+// NestedAttr returns the attribute typ holding attrs.
+func NestedAttr(typ uint16, attrs ...Attr) Attr {
+	return Attr{Type: typ | attrNested, Value: AppendAttrs(nil, attrs...)}
+}
+
+// AppendAttrs appends the encoding of attrs to b, padding each to the
+// attribute alignment.
+func AppendAttrs(b []byte, attrs ...Attr) []byte {
+	for _, a := range attrs {
+		l := attrHdrLen + len(a.Value)
+		hdr := make([]byte, attrHdrLen)
+		*(*uint16)(unsafe.Pointer(&hdr[0])) = uint16(l)
+		*(*uint16)(unsafe.Pointer(&hdr[2])) = a.Type
+		b = append(b, hdr...)
+		b = append(b, a.Value...)
+		b = append(b, make([]byte, attrAlignOf(l)-l)...)
+	}
+	return b
+}
+
+// ParseAttrs parses the attributes in b.  The returned attribute
+// types have the nested and byte order flags cleared.
+func ParseAttrs(b []byte) ([]Attr, error) {
+	var attrs []Attr
+	for len(b) >= attrHdrLen {
+		l := int(*(*uint16)(unsafe.Pointer(&b[0])))
+		if l < attrHdrLen || l > len(b) {
+			return nil, ErrMalformed
+		}
+		typ := *(*uint16)(unsafe.Pointer(&b[2]))
+		attrs = append(attrs, Attr{Type: typ & attrTypeMask, Value: b[attrHdrLen:l]})
+		if l = attrAlignOf(l); l > len(b) {
+			break
+		}
+		b = b[l:]
+	}
+	return attrs, nil
+}
+
+// Uint16 returns the value of a as a uint16.
+func (a Attr) Uint16() (uint16, error) {
+	if len(a.Value) < 2 {
+		return 0, ErrMalformed
+	}
+	return *(*uint16)(unsafe.Pointer(&a.Value[0])), nil
+}
+
+// Uint32 returns the value of a as a uint32.
+func (a Attr) Uint32() (uint32, error) {
+	if len(a.Value) < 4 {
+		return 0, ErrMalformed
+	}
+	return *(*uint32)(unsafe.Pointer(&a.Value[0])), nil
+}
+
+// String returns the value of a up to its terminating NUL.
+func (a Attr) String() string {
+	for i, c := range a.Value {
+		if c == 0 {
+			return string(a.Value[:i])
+		}
+	}
+	return string(a.Value)
+}
@@ -0,0 +1,50 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package netlink
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAttrs(t *testing.T) {
+	b := AppendAttrs(nil,
+		StringAttr(1, "eth:eth0"),
+		NestedAttr(2, Uint32Attr(3, 4711), Attr{Type: 4, Value: []byte{1}}),
+	)
+	if len(b)%attrAlignTo != 0 {
+		t.Fatalf("encoding of %d bytes is not padded", len(b))
+	}
+	attrs, err := ParseAttrs(b)
+	if err != nil {
+		t.Fatalf("ParseAttrs failed: %v", err)
+	}
+	if len(attrs) != 2 || attrs[0].Type != 1 || attrs[1].Type != 2 {
+		t.Fatalf("got %d attributes %+v; expected types 1 and 2", len(attrs), attrs)
+	}
+	if s := attrs[0].String(); s != "eth:eth0" {
+		t.Errorf("got string %q; expected %q", s, "eth:eth0")
+	}
+	nested, err := ParseAttrs(attrs[1].Value)
+	if err != nil {
+		t.Fatalf("ParseAttrs of nested attributes failed: %v", err)
+	}
+	if len(nested) != 2 {
+		t.Fatalf("got %d nested attributes; expected 2", len(nested))
+	}
+	if v, err := nested[0].Uint32(); err != nil || v != 4711 {
+		t.Errorf("got %d, %v; expected 4711", v, err)
+	}
+	if !bytes.Equal(nested[1].Value, []byte{1}) {
+		t.Errorf("got value %x; expected 01", nested[1].Value)
+	}
+	if _, err := nested[1].Uint16(); err == nil {
+		t.Error("Uint16 of a 1-byte value succeeded")
+	}
+
+	if _, err := ParseAttrs(b[:len(b)-4]); err == nil {
+		t.Error("ParseAttrs of truncated attributes succeeded")
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package netlink
+
+import (
+	"errors"
+	"unsafe"
+)
+
+const (
+	msgHdrLen  = 16 // struct nlmsghdr
+	GenlHdrLen = 4  // struct genlmsghdr
+
+	flagRequest = 0x1 // NLM_F_REQUEST
+
+	// Generic netlink controller definitions.
+	GenlIDCtrl         = 0x10
+	CtrlCmdGetFamily   = 3
+	CtrlAttrFamilyID   = 1
+	CtrlAttrFamilyName = 2
+)
+
+// ErrNoFamily is returned by FamilyID when the replies do not name a
+// family.
+var ErrNoFamily = errors.New("generic netlink family not found")
+
+// A Message is a generic netlink message.
+type Message struct {
+	Type  uint16 // the family
+	Flags uint16
+	Seq   uint32
+	Pid   uint32
+	Cmd   uint8
+	Attrs []Attr
+}
+
+// NewRequest returns a generic netlink request for the command cmd
+// of family with the given attributes.
+func NewRequest(family, flags uint16, seq uint32, cmd, version uint8, attrs ...Attr) []byte {
+	b := make([]byte, msgHdrLen+GenlHdrLen)
+	b[msgHdrLen] = cmd
+	b[msgHdrLen+1] = version
+	b = AppendAttrs(b, attrs...)
+	*(*uint32)(unsafe.Pointer(&b[0])) = uint32(len(b))
+	*(*uint16)(unsafe.Pointer(&b[4])) = family
+	*(*uint16)(unsafe.Pointer(&b[6])) = flagRequest | flags
+	*(*uint32)(unsafe.Pointer(&b[8])) = seq
+	return b
+}
+
+// FamilyID returns the family identifier reported by the replies to
+// a CTRL_CMD_GETFAMILY request.
+func FamilyID(replies []Message) (uint16, error) {
+	for _, m := range replies {
+		for _, a := range m.Attrs {
+			if a.Type == CtrlAttrFamilyID {
+				return a.Uint16()
+			}
+		}
+	}
+	return 0, ErrNoFamily
+}
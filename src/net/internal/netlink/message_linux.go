@@ -0,0 +1,56 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package netlink
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// ParseReplies parses the netlink stream b of replies to the request
+// with sequence number seq.  It returns the generic netlink replies
+// and reports whether b ends them, that is with the end of a dump or
+// with an error or acknowledgement; requests other than dumps must
+// therefore ask for an acknowledgement.  An error reported by the
+// kernel is returned as a syscall.Errno.
+func ParseReplies(b []byte, seq uint32) (replies []Message, done bool, err error) {
+	msgs, err := syscall.ParseNetlinkMessage(b)
+	if err != nil || len(msgs) == 0 {
+		return nil, false, ErrMalformed
+	}
+	for _, m := range msgs {
+		if m.Header.Seq != seq {
+			return nil, false, ErrMalformed
+		}
+		switch m.Header.Type {
+		case syscall.NLMSG_DONE:
+			return replies, true, nil
+		case syscall.NLMSG_ERROR:
+			if len(m.Data) < 4 {
+				return nil, false, ErrMalformed
+			}
+			if errno := -*(*int32)(unsafe.Pointer(&m.Data[0])); errno != 0 {
+				return nil, true, syscall.Errno(errno)
+			}
+			return replies, true, nil
+		}
+		if len(m.Data) < GenlHdrLen {
+			return nil, false, ErrMalformed
+		}
+		attrs, err := ParseAttrs(m.Data[GenlHdrLen:])
+		if err != nil {
+			return nil, false, err
+		}
+		replies = append(replies, Message{
+			Type:  m.Header.Type,
+			Flags: m.Header.Flags,
+			Seq:   m.Header.Seq,
+			Pid:   m.Header.Pid,
+			Cmd:   m.Data[0],
+			Attrs: attrs,
+		})
+	}
+	return replies, false, nil
+}
@@ -0,0 +1,17 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package netlink
+
+import "errors"
+
+var errNoNetlink = errors.New("netlink not supported on this system")
+
+// ParseReplies parses the netlink stream b of replies to the request
+// with sequence number seq.  There is no netlink on this system.
+func ParseReplies(b []byte, seq uint32) (replies []Message, done bool, err error) {
+	return nil, true, errNoNetlink
+}
@@ -0,0 +1,389 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package net
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// mockTIPCNode is the node address of every endpoint of the mock
+// TIPC fabric, 1.1.1.
+const mockTIPCNode = 1<<24 | 1<<12 | 1
+
+// A mockTIPCFabric is an in-process loopback TIPC network for
+// machines without the tipc kernel module.  It takes over the creation
+// of TIPC sockets: each socket is backed by a Unix domain socket in
+// the abstract namespace named after its port reference, and the
+// names a socket is bound to when it is created are resolved to that
+// socket when another one is connected to them.  The fabric also runs
+// a topology service.  Messages sent to names, names published after
+// creation and socket options set after creation are left to the
+// kernel and are not supported.
+type mockTIPCFabric struct {
+	ln   *TIPCListener // topology service
+	done chan bool
+
+	mu    sync.Mutex
+	ref   uint32
+	socks map[uint32]*mockTIPCSocket // by port reference
+	pubs  []*mockTIPCPub             // in lookup order
+	subs  []*mockTIPCSub
+}
+
+// A mockTIPCSocket is the fabric's record of a TIPC socket.
+type mockTIPCSocket struct {
+	ref    uint32
+	sotype int
+	opts   tipcSockopts // as requested at creation
+}
+
+// A mockTIPCPub is a name sequence bound by a socket.
+type mockTIPCPub struct {
+	service, lower, upper uint32
+	sock                  *mockTIPCSocket
+}
+
+// A mockTIPCSub is a topology service subscription.
+type mockTIPCSub struct {
+	c                     *TIPCConn
+	raw                   syscall.TIPCSubscr // as sent by the subscriber
+	service, lower, upper uint32
+	filter                uint32
+}
+
+// A mockTIPCEvent is a topology event waiting to be written to a
+// subscriber.
+type mockTIPCEvent struct {
+	c *TIPCConn
+	b []byte
+}
+
+// installMockTIPCFabric routes all TIPC sockets through a new mock
+// fabric, which the caller must remove again.
+func installMockTIPCFabric(t *testing.T) *mockTIPCFabric {
+	f := &mockTIPCFabric{done: make(chan bool), socks: make(map[uint32]*mockTIPCSocket)}
+	tipcSocketFunc = f.socket
+	ln, err := ListenTIPC("tipc-seqpacket", &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: TIPC_TOP_SRV, Instance: TIPC_TOP_SRV})
+	if err != nil {
+		tipcSocketFunc = sysTIPCSocket
+		t.Fatalf("ListenTIPC failed: %v", err)
+	}
+	f.ln = ln
+	go f.serveTopology()
+	go f.sweep()
+	return f
+}
+
+// remove stops f and restores the kernel TIPC sockets.
+func (f *mockTIPCFabric) remove() {
+	close(f.done)
+	f.ln.Close()
+	tipcSocketFunc = sysTIPCSocket
+}
+
+func (s *mockTIPCSocket) sockaddr() syscall.Sockaddr {
+	return &syscall.SockaddrUnix{Name: "@go-tipc-mock-" + itoa(os.Getpid()) + "-" + itoa(int(s.ref))}
+}
+
+func (s *mockTIPCSocket) id() *TIPCAddr {
+	return &TIPCAddr{AddrType: TIPC_ADDR_ID, Ref: s.ref, Node: mockTIPCNode}
+}
+
+// released reports whether the Unix domain socket backing s has been
+// closed, which frees its name.
+func (s *mockTIPCSocket) released() bool {
+	p, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return false
+	}
+	defer syscall.Close(p)
+	return syscall.Bind(p, s.sockaddr()) == nil
+}
+
+// class returns the socket type that sockets of type sotype can
+// exchange messages with.
+func mockTIPCClass(sotype int) int {
+	if sotype == syscall.SOCK_RDM {
+		return syscall.SOCK_DGRAM
+	}
+	return sotype
+}
+
+func (f *mockTIPCFabric) socket(net string, sotype int, laddr, raddr sockaddr, deadline time.Time, opts *tipcSockopts) (*netFD, error) {
+	s, err := sysSocket(syscall.AF_UNIX, mockTIPCClass(sotype), 0)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	f.ref++
+	ms := &mockTIPCSocket{ref: f.ref, sotype: sotype}
+	if opts != nil {
+		ms.opts = *opts
+	}
+	f.mu.Unlock()
+	if err := syscall.Bind(s, ms.sockaddr()); err != nil {
+		closesocket(s)
+		return nil, err
+	}
+	fd, err := newFD(s, syscall.AF_TIPC, sotype, net)
+	if err != nil {
+		closesocket(s)
+		return nil, err
+	}
+	var peer *TIPCAddr
+	if raddr != nil {
+		f.mu.Lock()
+		target := f.lookup(raddr.(*TIPCAddr), mockTIPCClass(sotype))
+		f.mu.Unlock()
+		if target == nil {
+			fd.Close()
+			return nil, syscall.EHOSTUNREACH
+		}
+		if err := fd.connect(nil, target.sockaddr(), deadline); err != nil {
+			fd.Close()
+			return nil, err
+		}
+		fd.isConnected = true
+		peer = target.id()
+	} else {
+		if laddr != nil && (sotype == syscall.SOCK_STREAM || sotype == syscall.SOCK_SEQPACKET) {
+			if err := syscall.Listen(s, listenerBacklog); err != nil {
+				fd.Close()
+				return nil, err
+			}
+		}
+		if err := fd.init(); err != nil {
+			fd.Close()
+			return nil, err
+		}
+	}
+	fd.setAddr(ms.id(), peer)
+	f.mu.Lock()
+	f.socks[ms.ref] = ms
+	var evs []mockTIPCEvent
+	if laddr != nil {
+		p := &mockTIPCPub{sock: ms}
+		p.service, p.lower, p.upper = laddr.(*TIPCAddr).nameSeq()
+		f.pubs = append(f.pubs, p)
+		evs = f.notify(p, TIPC_PUBLISHED)
+	}
+	f.mu.Unlock()
+	f.deliver(evs)
+	return fd, nil
+}
+
+// lookup returns the socket of the given class that a message to a
+// is delivered to.  Like the kernel, lookup distributes messages to
+// a name that is bound more than once round-robin.  Lookup must be
+// called with f.mu held.
+func (f *mockTIPCFabric) lookup(a *TIPCAddr, class int) *mockTIPCSocket {
+	if a.AddrType == TIPC_ADDR_ID {
+		if s := f.socks[a.Ref]; s != nil && a.Node == mockTIPCNode && mockTIPCClass(s.sotype) == class {
+			return s
+		}
+		return nil
+	}
+	for i, p := range f.pubs {
+		if p.service == a.Service && p.lower <= a.Instance && a.Instance <= p.upper && mockTIPCClass(p.sock.sotype) == class {
+			copy(f.pubs[i:], f.pubs[i+1:])
+			f.pubs[len(f.pubs)-1] = p
+			return p.sock
+		}
+	}
+	return nil
+}
+
+// sockopts returns the options requested when the socket of fd was
+// created.
+func (f *mockTIPCFabric) sockopts(fd *netFD) tipcSockopts {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.socks[fd.laddr.(*TIPCAddr).Ref].opts
+}
+
+// sweep withdraws the names bound by closed sockets until f is
+// removed.
+func (f *mockTIPCFabric) sweep() {
+	for {
+		select {
+		case <-f.done:
+			return
+		case <-time.After(5 * time.Millisecond):
+		}
+		f.mu.Lock()
+		socks := make([]*mockTIPCSocket, 0, len(f.socks))
+		for _, ms := range f.socks {
+			socks = append(socks, ms)
+		}
+		f.mu.Unlock()
+		for _, ms := range socks {
+			if ms.released() {
+				f.closed(ms)
+			}
+		}
+	}
+}
+
+// closed withdraws the names bound by ms.
+func (f *mockTIPCFabric) closed(ms *mockTIPCSocket) {
+	f.mu.Lock()
+	delete(f.socks, ms.ref)
+	var evs []mockTIPCEvent
+	pubs := f.pubs[:0]
+	for _, p := range f.pubs {
+		if p.sock == ms {
+			evs = append(evs, f.notify(p, TIPC_WITHDRAWN)...)
+			continue
+		}
+		pubs = append(pubs, p)
+	}
+	f.pubs = pubs
+	f.mu.Unlock()
+	f.deliver(evs)
+}
+
+func (f *mockTIPCFabric) serveTopology() {
+	for {
+		c, err := f.ln.AcceptTIPC()
+		if err != nil {
+			return
+		}
+		go f.serveSubscriber(c)
+	}
+}
+
+func (f *mockTIPCFabric) serveSubscriber(c *TIPCConn) {
+	defer c.Close()
+	b := make([]byte, syscall.SizeofTIPCSubscr)
+	for {
+		n, err := c.Read(b)
+		if err != nil {
+			f.mu.Lock()
+			f.cancel(c, nil)
+			f.mu.Unlock()
+			return
+		}
+		if n < syscall.SizeofTIPCSubscr {
+			continue
+		}
+		s := *(*syscall.TIPCSubscr)(unsafe.Pointer(&b[0]))
+		sub := &mockTIPCSub{
+			c:       c,
+			raw:     s,
+			service: s.Seq.Type,
+			lower:   s.Seq.Lower,
+			upper:   s.Seq.Upper,
+			filter:  s.Filter,
+		}
+		f.mu.Lock()
+		if sub.filter&TIPC_SUB_CANCEL != 0 {
+			f.cancel(c, sub)
+			f.mu.Unlock()
+			continue
+		}
+		f.subs = append(f.subs, sub)
+		var evs []mockTIPCEvent
+		for _, p := range f.pubs {
+			if ev, ok := sub.event(p, TIPC_PUBLISHED); ok {
+				evs = append(evs, ev)
+			}
+		}
+		f.mu.Unlock()
+		f.deliver(evs)
+		if s.Timeout != TIPC_WAIT_FOREVER {
+			time.AfterFunc(time.Duration(s.Timeout)*time.Millisecond, func() { f.expire(sub) })
+		}
+	}
+}
+
+// cancel removes the subscriptions of c matching sub, or all of them
+// if sub is nil.  Cancel must be called with f.mu held.
+func (f *mockTIPCFabric) cancel(c *TIPCConn, sub *mockTIPCSub) bool {
+	found := false
+	subs := f.subs[:0]
+	for _, s := range f.subs {
+		if s.c == c && (sub == nil || s.matches(sub)) {
+			found = true
+			continue
+		}
+		subs = append(subs, s)
+	}
+	f.subs = subs
+	return found
+}
+
+func (f *mockTIPCFabric) expire(sub *mockTIPCSub) {
+	f.mu.Lock()
+	found := f.cancel(sub.c, sub)
+	f.mu.Unlock()
+	if found {
+		f.deliver([]mockTIPCEvent{{sub.c, sub.marshal(TIPC_SUBSCR_TIMEOUT, sub.lower, sub.upper, 0, 0)}})
+	}
+}
+
+// notify returns the events caused by the publication or withdrawal
+// of p.  Notify must be called with f.mu held.
+func (f *mockTIPCFabric) notify(p *mockTIPCPub, typ uint32) []mockTIPCEvent {
+	var evs []mockTIPCEvent
+	for _, sub := range f.subs {
+		if ev, ok := sub.event(p, typ); ok {
+			evs = append(evs, ev)
+		}
+	}
+	return evs
+}
+
+// deliver writes evs to their subscribers.  It is called without
+// f.mu held so that a slow subscriber cannot stall the fabric.
+func (f *mockTIPCFabric) deliver(evs []mockTIPCEvent) {
+	for _, ev := range evs {
+		ev.c.Write(ev.b)
+	}
+}
+
+// matches reports whether s and t are the same subscription, ignoring
+// the TIPC_SUB_CANCEL flag.
+func (s *mockTIPCSub) matches(t *mockTIPCSub) bool {
+	a, b := s.raw, t.raw
+	a.Filter &^= TIPC_SUB_CANCEL
+	b.Filter &^= TIPC_SUB_CANCEL
+	return a == b
+}
+
+// event returns the event that the publication or withdrawal of p
+// causes for s, if any.  The mock treats TIPC_SUB_SERVICE like
+// TIPC_SUB_PORTS and reports every overlapping publication.
+func (s *mockTIPCSub) event(p *mockTIPCPub, typ uint32) (mockTIPCEvent, bool) {
+	if p.service != s.service || p.upper < s.lower || s.upper < p.lower {
+		return mockTIPCEvent{}, false
+	}
+	lower, upper := p.lower, p.upper
+	if lower < s.lower {
+		lower = s.lower
+	}
+	if upper > s.upper {
+		upper = s.upper
+	}
+	return mockTIPCEvent{s.c, s.marshal(typ, lower, upper, p.sock.ref, mockTIPCNode)}, true
+}
+
+func (s *mockTIPCSub) marshal(typ, lower, upper, ref, node uint32) []byte {
+	b := make([]byte, syscall.SizeofTIPCEvent)
+	*(*syscall.TIPCEvent)(unsafe.Pointer(&b[0])) = syscall.TIPCEvent{
+		Event:       typ,
+		Found_lower: lower,
+		Found_upper: upper,
+		Port:        syscall.TIPCPortID{Ref: ref, Node: node},
+		S:           s.raw,
+	}
+	return b
+}
@@ -0,0 +1,271 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"net"
+	"sync"
+)
+
+// tipcMaxMsgSize is the largest payload of a TIPC message.
+const tipcMaxMsgSize = 66000
+
+var errTIPCMsgSize = errors.New("rpc: message too large for a TIPC datagram")
+
+// DialTIPC connects to an RPC server listening on the TIPC name
+// {service, instance} anywhere in the cluster.
+func DialTIPC(service, instance uint32) (*Client, error) {
+	conn, err := net.DialTIPC("tipc", nil, &net.TIPCAddr{AddrType: net.TIPC_ADDR_NAME, Service: service, Instance: instance})
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// ServeTIPC listens for TIPC connections on the name or name sequence
+// addr and serves requests on each incoming connection.  ServeTIPC
+// blocks until accepting a connection fails.
+func (server *Server) ServeTIPC(addr *net.TIPCAddr) error {
+	l, err := net.ListenTIPC("tipc", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go server.ServeConn(conn)
+	}
+}
+
+// ServeTIPC is like the ServeTIPC method but serves requests to
+// DefaultServer.
+func ServeTIPC(addr *net.TIPCAddr) error { return DefaultServer.ServeTIPC(addr) }
+
+// DialTIPCPacket returns a client that sends its calls as reliable
+// datagrams to the TIPC name addr; see NewTIPCClientCodec.
+func DialTIPCPacket(addr *net.TIPCAddr) (*Client, error) {
+	conn, err := net.ListenTIPCPacket("tipc-rdm", nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewClientWithCodec(NewTIPCClientCodec(conn, addr)), nil
+}
+
+// ServeTIPCPacket binds a reliable datagram socket to the name or
+// name sequence addr and serves the requests sent to it; see
+// NewTIPCServerCodec.  ServeTIPCPacket blocks until reading from the
+// socket fails.
+func (server *Server) ServeTIPCPacket(addr *net.TIPCAddr) error {
+	conn, err := net.ListenTIPCPacket("tipc-rdm", addr)
+	if err != nil {
+		return err
+	}
+	codec := NewTIPCServerCodec(conn).(*tipcServerCodec)
+	server.ServeCodec(codec)
+	return codec.err
+}
+
+// ServeTIPCPacket is like the ServeTIPCPacket method but serves
+// requests to DefaultServer.
+func ServeTIPCPacket(addr *net.TIPCAddr) error { return DefaultServer.ServeTIPCPacket(addr) }
+
+// tipcPacketConn is the part of *net.TIPCPacketConn used by the TIPC
+// datagram codecs.
+type tipcPacketConn interface {
+	ReadMsgTIPC(b, oob []byte) (n, oobn, flags int, addr *net.TIPCAddr, err error)
+	WriteToTIPC(b []byte, addr *net.TIPCAddr) (int, error)
+	Close() error
+}
+
+// encodeTIPCMsg encodes a request or response header and its body as
+// a self-contained datagram.
+func encodeTIPCMsg(header, body interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(header); err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(body); err != nil {
+		return nil, err
+	}
+	if buf.Len() > tipcMaxMsgSize {
+		return nil, errTIPCMsgSize
+	}
+	return buf.Bytes(), nil
+}
+
+type tipcClientCodec struct {
+	conn tipcPacketConn
+	addr *net.TIPCAddr
+	buf  []byte
+	oob  []byte
+	dec  *gob.Decoder // body of the current response; nil for returned requests
+}
+
+// NewTIPCClientCodec returns a ClientCodec that sends each request as
+// a single reliable datagram over conn, a "tipc-rdm" socket, to the
+// name addr and reads the responses from conn.  The servers bound to
+// the name or to a name sequence containing it share the calls, and
+// a single socket can reach them all.  A request that cannot be
+// delivered is returned by the kernel, and its call fails with an
+// error describing the reason.  Requests and responses must fit in a
+// TIPC message of 66000 bytes.
+func NewTIPCClientCodec(conn *net.TIPCPacketConn, addr *net.TIPCAddr) ClientCodec {
+	return newTIPCClientCodec(conn, addr)
+}
+
+func newTIPCClientCodec(conn tipcPacketConn, addr *net.TIPCAddr) *tipcClientCodec {
+	return &tipcClientCodec{
+		conn: conn,
+		addr: addr,
+		buf:  make([]byte, tipcMaxMsgSize),
+		oob:  make([]byte, tipcOOBSize),
+	}
+}
+
+func (c *tipcClientCodec) WriteRequest(r *Request, body interface{}) error {
+	b, err := encodeTIPCMsg(r, body)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.WriteToTIPC(b, c.addr)
+	return err
+}
+
+func (c *tipcClientCodec) ReadResponseHeader(r *Response) error {
+	for {
+		n, oobn, _, _, err := c.conn.ReadMsgTIPC(c.buf, c.oob)
+		if err != nil {
+			return err
+		}
+		reason, data, err := tipcReturned(c.oob[:oobn])
+		if err != nil {
+			return err
+		}
+		if reason == "" {
+			c.dec = gob.NewDecoder(bytes.NewReader(c.buf[:n]))
+			return c.dec.Decode(r)
+		}
+		// One of our requests came back undelivered; its
+		// header tells which call failed.
+		var req Request
+		if gob.NewDecoder(bytes.NewReader(data)).Decode(&req) != nil {
+			continue
+		}
+		r.ServiceMethod = req.ServiceMethod
+		r.Seq = req.Seq
+		r.Error = "rpc: TIPC request not delivered: " + reason
+		c.dec = nil
+		return nil
+	}
+}
+
+func (c *tipcClientCodec) ReadResponseBody(body interface{}) error {
+	if c.dec == nil {
+		return nil
+	}
+	return c.dec.Decode(body)
+}
+
+func (c *tipcClientCodec) Close() error {
+	return c.conn.Close()
+}
+
+// A tipcCall identifies a request received by a tipcServerCodec.
+type tipcCall struct {
+	addr *net.TIPCAddr // sender
+	seq  uint64        // sequence number chosen by the sender
+}
+
+type tipcServerCodec struct {
+	conn tipcPacketConn
+	buf  []byte
+	dec  *gob.Decoder // body of the current request
+	err  error        // read error that stopped the server
+
+	// Requests from different clients can carry the same
+	// sequence number, so the codec numbers them itself and
+	// keeps the sender of each pending request.
+	mu      sync.Mutex // protects seq, pending
+	seq     uint64
+	pending map[uint64]tipcCall
+}
+
+// NewTIPCServerCodec returns a ServerCodec that reads requests sent as
+// datagrams by codecs made with NewTIPCClientCodec from conn, a
+// "tipc-rdm" socket, and sends every response as a single datagram
+// back to the socket that sent the request.
+func NewTIPCServerCodec(conn *net.TIPCPacketConn) ServerCodec {
+	return newTIPCServerCodec(conn)
+}
+
+func newTIPCServerCodec(conn tipcPacketConn) *tipcServerCodec {
+	return &tipcServerCodec{
+		conn:    conn,
+		buf:     make([]byte, tipcMaxMsgSize),
+		pending: make(map[uint64]tipcCall),
+	}
+}
+
+func (c *tipcServerCodec) ReadRequestHeader(r *Request) error {
+	for {
+		n, _, _, addr, err := c.conn.ReadMsgTIPC(c.buf, nil)
+		if err != nil {
+			c.err = err
+			return err
+		}
+		// Datagrams that do not hold a request, such as
+		// responses returned because their client went away,
+		// are dropped rather than ending the session.
+		dec := gob.NewDecoder(bytes.NewReader(c.buf[:n]))
+		if n == 0 || addr == nil || dec.Decode(r) != nil {
+			continue
+		}
+		c.dec = dec
+		c.mu.Lock()
+		c.seq++
+		c.pending[c.seq] = tipcCall{addr: addr, seq: r.Seq}
+		r.Seq = c.seq
+		c.mu.Unlock()
+		return nil
+	}
+}
+
+func (c *tipcServerCodec) ReadRequestBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *tipcServerCodec) WriteResponse(r *Response, body interface{}) error {
+	c.mu.Lock()
+	call, ok := c.pending[r.Seq]
+	if !ok {
+		c.mu.Unlock()
+		return errors.New("invalid sequence number in response")
+	}
+	delete(c.pending, r.Seq)
+	c.mu.Unlock()
+	r.Seq = call.seq
+	b, err := encodeTIPCMsg(r, body)
+	if err == errTIPCMsgSize {
+		// Let the call fail rather than leave it pending.
+		r.Error = err.Error()
+		b, err = encodeTIPCMsg(r, invalidRequest)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.WriteToTIPC(b, call.addr)
+	return err
+}
+
+func (c *tipcServerCodec) Close() error {
+	return c.conn.Close()
+}
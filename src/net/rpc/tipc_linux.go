@@ -0,0 +1,46 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"strconv"
+	"syscall"
+)
+
+// tipcErrors describes why the kernel returned a message to its
+// sender.
+var tipcErrors = map[int]string{
+	syscall.TIPC_ERR_NO_NAME:  "no server bound to the name",
+	syscall.TIPC_ERR_NO_PORT:  "server socket closed",
+	syscall.TIPC_ERR_NO_NODE:  "server node unreachable",
+	syscall.TIPC_ERR_OVERLOAD: "server overloaded",
+}
+
+// tipcOOBSize is the room needed for the TIPC_ERRINFO and
+// TIPC_RETDATA control messages of a returned request.
+var tipcOOBSize = syscall.CmsgSpace(8) + syscall.CmsgSpace(tipcMaxMsgSize)
+
+// tipcReturned parses the control messages received with a TIPC
+// message.  If the message is one of ours returned by the kernel
+// because it could not be delivered, tipcReturned describes why and
+// returns the original message.
+func tipcReturned(oob []byte) (reason string, data []byte, err error) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := syscall.ParseTIPCMsgInfo(msgs)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.ErrorCode == syscall.TIPC_OK {
+		return "", nil, nil
+	}
+	reason, ok := tipcErrors[info.ErrorCode]
+	if !ok {
+		reason = "error " + strconv.Itoa(info.ErrorCode)
+	}
+	return reason, info.ReturnedData, nil
+}
@@ -0,0 +1,15 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package rpc
+
+const tipcOOBSize = 0
+
+// tipcReturned reports no returned messages; TIPC is only supported
+// on Linux.
+func tipcReturned(oob []byte) (reason string, data []byte, err error) {
+	return "", nil, nil
+}
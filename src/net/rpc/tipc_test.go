@@ -0,0 +1,223 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package rpc
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// fakeTIPCNet delivers datagrams between fakeTIPCConns like the
+// kernel delivers reliable datagrams between TIPC sockets, returning
+// messages to unbound names to their sender.
+type fakeTIPCNet struct {
+	mu    sync.Mutex
+	ref   uint32
+	conns []*fakeTIPCConn
+}
+
+type fakeTIPCMsg struct {
+	b, oob []byte
+	from   *net.TIPCAddr
+}
+
+type fakeTIPCConn struct {
+	net    *fakeTIPCNet
+	id     *net.TIPCAddr
+	name   *net.TIPCAddr // name sequence bound, or nil
+	in     chan fakeTIPCMsg
+	closed bool // guarded by net.mu
+}
+
+var errFakeTIPCClosed = errors.New("use of closed fake TIPC socket")
+
+func (n *fakeTIPCNet) listen(name *net.TIPCAddr) *fakeTIPCConn {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.ref++
+	c := &fakeTIPCConn{
+		net:  n,
+		id:   &net.TIPCAddr{AddrType: net.TIPC_ADDR_ID, Ref: n.ref, Node: 0x01001001},
+		name: name,
+		in:   make(chan fakeTIPCMsg, 16),
+	}
+	n.conns = append(n.conns, c)
+	return c
+}
+
+// lookup returns the socket that a message to addr is delivered to.
+// Lookup must be called with n.mu held.
+func (n *fakeTIPCNet) lookup(addr *net.TIPCAddr) *fakeTIPCConn {
+	for _, c := range n.conns {
+		switch addr.AddrType {
+		case net.TIPC_ADDR_ID:
+			if c.id.Ref == addr.Ref {
+				return c
+			}
+		case net.TIPC_ADDR_NAME:
+			if c.name != nil && c.name.Service == addr.Service && c.name.Instance <= addr.Instance && addr.Instance <= c.name.Domain {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// appendCmsg appends a SOL_TIPC control message of type typ to b.
+func appendCmsg(b []byte, typ int, data []byte) []byte {
+	m := make([]byte, syscall.CmsgSpace(len(data)))
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&m[0]))
+	h.Level = syscall.SOL_TIPC
+	h.Type = int32(typ)
+	h.SetLen(syscall.CmsgLen(len(data)))
+	copy(m[syscall.CmsgLen(0):], data)
+	return append(b, m...)
+}
+
+func (c *fakeTIPCConn) WriteToTIPC(b []byte, addr *net.TIPCAddr) (int, error) {
+	msg := fakeTIPCMsg{b: append([]byte(nil), b...), from: c.id}
+	c.net.mu.Lock()
+	defer c.net.mu.Unlock()
+	if c.closed {
+		return 0, errFakeTIPCClosed
+	}
+	dst := c.net.lookup(addr)
+	if dst == nil {
+		// Return the message with TIPC_ERRINFO and
+		// TIPC_RETDATA, as the kernel does.
+		info := make([]byte, 8)
+		*(*uint32)(unsafe.Pointer(&info[0])) = syscall.TIPC_ERR_NO_NAME
+		*(*uint32)(unsafe.Pointer(&info[4])) = uint32(len(b))
+		msg.oob = appendCmsg(appendCmsg(nil, syscall.TIPC_ERRINFO, info), syscall.TIPC_RETDATA, msg.b)
+		msg.b = nil
+		dst = c
+	}
+	dst.in <- msg
+	return len(b), nil
+}
+
+func (c *fakeTIPCConn) ReadMsgTIPC(b, oob []byte) (n, oobn, flags int, addr *net.TIPCAddr, err error) {
+	msg, ok := <-c.in
+	if !ok {
+		return 0, 0, 0, nil, errFakeTIPCClosed
+	}
+	return copy(b, msg.b), copy(oob, msg.oob), 0, msg.from, nil
+}
+
+func (c *fakeTIPCConn) Close() error {
+	c.net.mu.Lock()
+	defer c.net.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	for i, cc := range c.net.conns {
+		if cc == c {
+			c.net.conns = append(c.net.conns[:i], c.net.conns[i+1:]...)
+			break
+		}
+	}
+	close(c.in)
+	return nil
+}
+
+func TestTIPCPacketCodec(t *testing.T) {
+	server := NewServer()
+	server.Register(new(Arith))
+	fnet := &fakeTIPCNet{}
+	sc := fnet.listen(&net.TIPCAddr{AddrType: net.TIPC_ADDR_NAMESEQ, Service: 4711, Instance: 0, Domain: 99})
+	done := make(chan bool)
+	go func() {
+		server.ServeCodec(newTIPCServerCodec(sc))
+		done <- true
+	}()
+
+	// Two clients whose calls carry the same sequence numbers
+	// share the server.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		client := NewClientWithCodec(newTIPCClientCodec(fnet.listen(nil), &net.TIPCAddr{AddrType: net.TIPC_ADDR_NAME, Service: 4711, Instance: uint32(42 + i)}))
+		defer client.Close()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				args := &Args{i, j}
+				reply := new(Reply)
+				if err := client.Call("Arith.Add", args, reply); err != nil {
+					t.Errorf("Add: %v", err)
+					return
+				}
+				if reply.C != i+j {
+					t.Errorf("Add: got %d; expected %d", reply.C, i+j)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	client := NewClientWithCodec(newTIPCClientCodec(fnet.listen(nil), &net.TIPCAddr{AddrType: net.TIPC_ADDR_NAME, Service: 4711, Instance: 7}))
+	defer client.Close()
+	err := client.Call("Arith.Div", &Args{7, 0}, new(Reply))
+	if err == nil || err.Error() != "divide by zero" {
+		t.Errorf("Div: got %v; expected divide by zero", err)
+	}
+	err = client.Call("Arith.Unknown", &Args{7, 0}, new(Reply))
+	if err == nil || !strings.Contains(err.Error(), "can't find method") {
+		t.Errorf("Unknown: got %v; expected unknown method", err)
+	}
+
+	sc.Close()
+	<-done
+}
+
+func TestTIPCPacketCodecReturned(t *testing.T) {
+	fnet := &fakeTIPCNet{}
+	client := NewClientWithCodec(newTIPCClientCodec(fnet.listen(nil), &net.TIPCAddr{AddrType: net.TIPC_ADDR_NAME, Service: 4711, Instance: 1}))
+	defer client.Close()
+	err := client.Call("Arith.Add", &Args{1, 2}, new(Reply))
+	if _, ok := err.(ServerError); !ok || !strings.Contains(err.Error(), "no server bound to the name") {
+		t.Fatalf("got %v; expected the request to be returned undelivered", err)
+	}
+	// The client survives returned requests.
+	sc := fnet.listen(&net.TIPCAddr{AddrType: net.TIPC_ADDR_NAMESEQ, Service: 4711, Instance: 1, Domain: 1})
+	defer sc.Close()
+	server := NewServer()
+	server.Register(new(Arith))
+	go server.ServeCodec(newTIPCServerCodec(sc))
+	reply := new(Reply)
+	if err := client.Call("Arith.Add", &Args{1, 2}, reply); err != nil || reply.C != 3 {
+		t.Errorf("got %d, %v; expected 3", reply.C, err)
+	}
+}
+
+func TestServeTIPCPacket(t *testing.T) {
+	addr := &net.TIPCAddr{AddrType: net.TIPC_ADDR_NAMESEQ, Service: 4711, Instance: 0, Domain: 99}
+	conn, err := net.ListenTIPCPacket("tipc-rdm", addr)
+	if err != nil {
+		t.Skipf("TIPC not available: %v", err)
+	}
+	server := NewServer()
+	server.Register(new(Arith))
+	go server.ServeCodec(NewTIPCServerCodec(conn))
+	defer conn.Close()
+
+	client, err := DialTIPCPacket(&net.TIPCAddr{AddrType: net.TIPC_ADDR_NAME, Service: 4711, Instance: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	reply := new(Reply)
+	if err := client.Call("Arith.Mul", &Args{6, 7}, reply); err != nil || reply.C != 42 {
+		t.Errorf("got %d, %v; expected 42", reply.C, err)
+	}
+}
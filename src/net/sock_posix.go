@@ -35,8 +35,10 @@ type sockaddr interface {
 }
 
 // socket returns a network file descriptor that is ready for
-// asynchronous I/O using the network poller.
-func socket(net string, family, sotype, proto int, ipv6only bool, laddr, raddr sockaddr, deadline time.Time) (fd *netFD, err error) {
+// asynchronous I/O using the network poller.  If sockopts is not nil,
+// it is called to set protocol-specific socket options before the
+// socket is bound or connected.
+func socket(net string, family, sotype, proto int, ipv6only bool, laddr, raddr sockaddr, deadline time.Time, sockopts func(*netFD) error) (fd *netFD, err error) {
 	s, err := sysSocket(family, sotype, proto)
 	if err != nil {
 		return nil, err
@@ -49,6 +51,12 @@ func socket(net string, family, sotype, proto int, ipv6only bool, laddr, raddr s
 		closesocket(s)
 		return nil, err
 	}
+	if sockopts != nil {
+		if err := sockopts(fd); err != nil {
+			fd.Close()
+			return nil, err
+		}
+	}
 
 	// This function makes a network file descriptor for the
 	// following applications:
@@ -96,13 +104,7 @@ func socket(net string, family, sotype, proto int, ipv6only bool, laddr, raddr s
 }
 
 func (fd *netFD) addrFunc() func(syscall.Sockaddr) Addr {
-
 	switch fd.family {
-	case syscall.AF_TIPC:
-        switch fd.sotype {
-		case syscall.SOCK_STREAM, syscall.SOCK_SEQPACKET, syscall.SOCK_DGRAM, syscall.SOCK_RDM:
-            return sockaddrToTIPC
-        }
 	case syscall.AF_INET, syscall.AF_INET6:
 		switch fd.sotype {
 		case syscall.SOCK_STREAM:
@@ -121,6 +123,10 @@ func (fd *netFD) addrFunc() func(syscall.Sockaddr) Addr {
 		case syscall.SOCK_SEQPACKET:
 			return sockaddrToUnixpacket
 		}
+	default:
+		if f := tipcAddrFunc(fd.family, fd.sotype); f != nil {
+			return f
+		}
 	}
 	return func(syscall.Sockaddr) Addr { return nil }
 }
@@ -0,0 +1,1149 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package net
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// skipTIPCTest skips the calling test when the running kernel does
+// not provide TIPC sockets, which is the case unless the tipc module
+// has been loaded.
+func skipTIPCTest(t *testing.T) {
+	s, err := syscall.Socket(syscall.AF_TIPC, syscall.SOCK_RDM, 0)
+	if err != nil {
+		if err == syscall.EAFNOSUPPORT {
+			t.Skip("skipping test; TIPC is not available")
+		}
+		t.Fatalf("syscall.Socket failed: %v", err)
+	}
+	syscall.Close(s)
+}
+
+var resolveTIPCNetworkTests = []struct {
+	net  string
+	addr string
+}{
+	{"tipc-seqpacket", "18888;17"},
+	{"tipc-rdm", "18888;17"},
+	{"tipc-dgram", "18888;17"},
+}
+
+func TestResolveTIPCAddrNetworks(t *testing.T) {
+	for _, tt := range resolveTIPCNetworkTests {
+		a, err := ResolveTIPCAddr(tt.net, tt.addr)
+		if err != nil {
+			t.Errorf("ResolveTIPCAddr(%q, %q) failed: %v", tt.net, tt.addr, err)
+			continue
+		}
+		if a.AddrType != TIPC_ADDR_NAME || a.Service != 18888 || a.Instance != 17 {
+			t.Errorf("ResolveTIPCAddr(%q, %q) = %+v", tt.net, tt.addr, a)
+		}
+	}
+}
+
+func TestListenTIPCPacketUnknownNetwork(t *testing.T) {
+	if _, err := ListenTIPCPacket("tipc", &TIPCAddr{AddrType: TIPC_ADDR_NAME, Scope: TIPC_ZONE_SCOPE, Service: 18888, Instance: 17}); err == nil {
+		t.Fatal("ListenTIPCPacket on stream network succeeded")
+	}
+	if _, err := Listen("tipc-rdm", "18888;17"); err == nil {
+		t.Fatal("Listen on datagram network succeeded")
+	}
+	if _, err := ListenTIPC("tipc-seqpacket", nil); err == nil {
+		t.Fatal("ListenTIPC without local address succeeded")
+	}
+}
+
+func TestTIPCPacketConn(t *testing.T) {
+	skipTIPCTest(t)
+
+	for _, net := range []string{"tipc-rdm", "tipc-dgram"} {
+		c1, err := ListenPacket(net, "18888;17")
+		if err != nil {
+			t.Fatalf("ListenPacket(%q) failed: %v", net, err)
+		}
+		defer c1.Close()
+		c2, err := ListenPacket(net, "18888;18")
+		if err != nil {
+			t.Fatalf("ListenPacket(%q) failed: %v", net, err)
+		}
+		defer c2.Close()
+		c1.SetDeadline(time.Now().Add(time.Second))
+		c2.SetDeadline(time.Now().Add(time.Second))
+
+		dst, err := ResolveTIPCAddr(net, "18888;17")
+		if err != nil {
+			t.Fatalf("ResolveTIPCAddr failed: %v", err)
+		}
+		wb := []byte("TIPC PACKETCONN TEST")
+		if _, err := c2.WriteTo(wb, dst); err != nil {
+			t.Fatalf("PacketConn.WriteTo failed: %v", err)
+		}
+		rb := make([]byte, 128)
+		n, from, err := c1.ReadFrom(rb)
+		if err != nil {
+			t.Fatalf("PacketConn.ReadFrom failed: %v", err)
+		}
+		if string(rb[:n]) != string(wb) {
+			t.Fatalf("got %q; expected %q", rb[:n], wb)
+		}
+		if _, err := c1.WriteTo(wb, from); err != nil {
+			t.Fatalf("PacketConn.WriteTo to sender failed: %v", err)
+		}
+		if _, _, err := c2.ReadFrom(rb); err != nil {
+			t.Fatalf("PacketConn.ReadFrom failed: %v", err)
+		}
+	}
+}
+
+func TestTIPCSeqpacketConn(t *testing.T) {
+	skipTIPCTest(t)
+
+	ln, err := Listen("tipc-seqpacket", "18889;1")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer c.Close()
+		for _, m := range []string{"FIRST TIPC SEQPACKET MESSAGE", "SECOND"} {
+			if _, err := c.Write([]byte(m)); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	c, err := Dial("tipc-seqpacket", "18889;1")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(time.Second))
+
+	b := make([]byte, 5)
+	n, _, flags, _, err := c.(*TIPCConn).ReadMsgTIPC(b, nil)
+	if err != nil {
+		t.Fatalf("TIPCConn.ReadMsgTIPC failed: %v", err)
+	}
+	if n != len(b) || flags&syscall.MSG_TRUNC == 0 {
+		t.Fatalf("got %d bytes, flags %#x; expected %d bytes with MSG_TRUNC", n, flags, len(b))
+	}
+	b = make([]byte, 64)
+	n, err = c.Read(b)
+	if err != nil {
+		t.Fatalf("TIPCConn.Read failed: %v", err)
+	}
+	if string(b[:n]) != "SECOND" {
+		t.Fatalf("got %q; expected %q", b[:n], "SECOND")
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTIPCTopologyMessages(t *testing.T) {
+	a := &TIPCAddr{AddrType: TIPC_ADDR_NAMESEQ, Service: 18888, Instance: 10, Domain: 20}
+	b := marshalTIPCSubscr(a, TIPC_SUB_PORTS, 0)
+	if len(b) != syscall.SizeofTIPCSubscr {
+		t.Fatalf("got %d bytes; expected %d", len(b), syscall.SizeofTIPCSubscr)
+	}
+	sub := *(*syscall.TIPCSubscr)(unsafe.Pointer(&b[0]))
+	want := syscall.TIPCSubscr{
+		Seq:     syscall.TIPCNameSeq{Type: 18888, Lower: 10, Upper: 20},
+		Timeout: TIPC_WAIT_FOREVER,
+		Filter:  TIPC_SUB_PORTS,
+	}
+	if sub != want {
+		t.Fatalf("got %+v; expected %+v", sub, want)
+	}
+	for _, tt := range []struct {
+		timeout time.Duration
+		ms      uint32
+	}{
+		{1500 * time.Millisecond, 1500},
+		{time.Microsecond, 1},
+		{1500 * time.Microsecond, 2},
+		{-time.Second, TIPC_WAIT_FOREVER},
+		{(TIPC_WAIT_FOREVER - 1) * time.Millisecond, TIPC_WAIT_FOREVER - 1},
+		{TIPC_WAIT_FOREVER * time.Millisecond, TIPC_WAIT_FOREVER - 1},
+		{1<<63 - 1, TIPC_WAIT_FOREVER - 1},
+	} {
+		b = marshalTIPCSubscr(a, TIPC_SUB_SERVICE, tt.timeout)
+		if sub := (*syscall.TIPCSubscr)(unsafe.Pointer(&b[0])); sub.Timeout != tt.ms || sub.Filter != TIPC_SUB_SERVICE {
+			t.Errorf("timeout %v: got %dms, filter %d; expected %dms, %d", tt.timeout, sub.Timeout, sub.Filter, tt.ms, TIPC_SUB_SERVICE)
+		}
+	}
+
+	ev := make([]byte, syscall.SizeofTIPCEvent)
+	*(*syscall.TIPCEvent)(unsafe.Pointer(&ev[0])) = syscall.TIPCEvent{
+		Event:       TIPC_PUBLISHED,
+		Found_lower: 12,
+		Found_upper: 15,
+		Port:        syscall.TIPCPortID{Ref: 0x12345678, Node: 0x01001001},
+		S:           want,
+	}
+	e, err := parseTIPCEvent(ev)
+	if err != nil {
+		t.Fatalf("parseTIPCEvent failed: %v", err)
+	}
+	if e.Type != TIPC_PUBLISHED || e.Lower != 12 || e.Upper != 15 || e.Ref != 0x12345678 || e.Node != 0x01001001 {
+		t.Fatalf("got %+v", e)
+	}
+	if e.Addr.Service != 18888 || e.Addr.Instance != 10 || e.Addr.Domain != 20 {
+		t.Fatalf("got subscription %+v", e.Addr)
+	}
+	if _, err := parseTIPCEvent(ev[:len(ev)-1]); err == nil {
+		t.Fatal("parseTIPCEvent accepted a short event")
+	}
+}
+
+func TestTIPCTopologySubscriber(t *testing.T) {
+	skipTIPCTest(t)
+
+	s, err := DialTIPCTopology()
+	if err != nil {
+		t.Fatalf("DialTIPCTopology failed: %v", err)
+	}
+	defer s.Close()
+	a := &TIPCAddr{AddrType: TIPC_ADDR_NAMESEQ, Service: 18890, Instance: 0, Domain: 100}
+	if err := s.Subscribe(a, TIPC_SUB_PORTS, 5*time.Second); err != nil {
+		t.Fatalf("TIPCTopologySubscriber.Subscribe failed: %v", err)
+	}
+
+	ln, err := Listen("tipc", "18890;42")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	for _, typ := range []uint32{TIPC_PUBLISHED, TIPC_WITHDRAWN} {
+		select {
+		case e, ok := <-s.Events():
+			if !ok {
+				t.Fatalf("event channel closed: %v", s.Err())
+			}
+			if e.Type != typ || e.Lower != 42 || e.Upper != 42 {
+				t.Fatalf("got %+v; expected type %d for instance 42", e, typ)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for event type %d", typ)
+		}
+		if typ == TIPC_PUBLISHED {
+			ln.Close()
+		}
+	}
+}
+
+func TestDialTIPCMulticast(t *testing.T) {
+	for _, net := range []string{"tipc", "tipc-rdm"} {
+		_, err := Dial(net, "18891;0-10")
+		if err == nil {
+			t.Fatalf("Dial(%q) to multicast address succeeded", net)
+		}
+		if _, ok := err.(*OpError).Err.(InvalidAddrError); !ok {
+			t.Fatalf("Dial(%q) to multicast address failed with unexpected error: %v", net, err)
+		}
+	}
+}
+
+func TestTIPCMulticast(t *testing.T) {
+	skipTIPCTest(t)
+
+	var rcvs []PacketConn
+	for _, addr := range []string{"18891;0-9", "18891;5-14", "18891;20-29"} {
+		c, err := ListenPacket("tipc-rdm", addr)
+		if err != nil {
+			t.Fatalf("ListenPacket failed: %v", err)
+		}
+		defer c.Close()
+		c.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		rcvs = append(rcvs, c)
+	}
+	s, err := ListenTIPCPacket("tipc-rdm", nil)
+	if err != nil {
+		t.Fatalf("ListenTIPCPacket failed: %v", err)
+	}
+	defer s.Close()
+
+	dst, err := ResolveTIPCAddr("tipc-rdm", "18891;7-8")
+	if err != nil {
+		t.Fatalf("ResolveTIPCAddr failed: %v", err)
+	}
+	wb := []byte("TIPC MULTICAST TEST")
+	if _, err := s.WriteToTIPC(wb, dst); err != nil {
+		t.Fatalf("TIPCPacketConn.WriteToTIPC failed: %v", err)
+	}
+	rb := make([]byte, 128)
+	for i, c := range rcvs {
+		n, _, err := c.ReadFrom(rb)
+		if i < 2 {
+			if err != nil {
+				t.Fatalf("receiver %d: PacketConn.ReadFrom failed: %v", i, err)
+			}
+			if string(rb[:n]) != string(wb) {
+				t.Fatalf("receiver %d: got %q; expected %q", i, rb[:n], wb)
+			}
+		} else if err == nil {
+			t.Fatalf("receiver %d outside the multicast range got %q", i, rb[:n])
+		}
+	}
+}
+
+var resolveTIPCAddrTests = []struct {
+	in  string
+	out *TIPCAddr
+	str string // canonical form, if different from in
+	err string
+}{
+	{"{1000,7}", &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: 1000, Instance: 7}, "", ""},
+	{"{1000,0,4294967295}", &TIPCAddr{AddrType: TIPC_ADDR_NAMESEQ, Service: 1000, Instance: 0, Domain: 0xffffffff}, "", ""},
+	{"{1000,7}@1.2.3", &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: 1000, Instance: 7, Domain: 0x01002003}, "", ""},
+	{"{1000,7}@1.2.3/node", &TIPCAddr{AddrType: TIPC_ADDR_NAME, Scope: TIPC_NODE_SCOPE, Service: 1000, Instance: 7, Domain: 0x01002003}, "", ""},
+	{"{1000,7}/zone", &TIPCAddr{AddrType: TIPC_ADDR_NAME, Scope: TIPC_ZONE_SCOPE, Service: 1000, Instance: 7}, "", ""},
+	{"{1000,5,9}/cluster", &TIPCAddr{AddrType: TIPC_ADDR_NAMESEQ, Scope: TIPC_CLUSTER_SCOPE, Service: 1000, Instance: 5, Domain: 9}, "", ""},
+	{"{1000,7}@0.0.0", &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: 1000, Instance: 7}, "{1000,7}", ""},
+	{"1000;7", &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: 1000, Instance: 7}, "{1000,7}", ""},
+	{"1000;5-9", &TIPCAddr{AddrType: TIPC_ADDR_NAMESEQ, Service: 1000, Instance: 5, Domain: 9}, "{1000,5,9}", ""},
+	{"1000;7@1.1.1/node", &TIPCAddr{AddrType: TIPC_ADDR_NAME, Scope: TIPC_NODE_SCOPE, Service: 1000, Instance: 7, Domain: 0x01001001}, "{1000,7}@1.1.1/node", ""},
+	{"<1.1.1:42>", &TIPCAddr{AddrType: TIPC_ADDR_ID, Ref: 42, Node: 0x01001001}, "", ""},
+
+	{"", nil, "", "missing TIPC address"},
+	{"1000", nil, "", "malformed TIPC name"},
+	{"{1000}", nil, "", "malformed TIPC name"},
+	{"{1000,7", nil, "", "malformed TIPC name"},
+	{"{1000,1,2,3}", nil, "", "malformed TIPC name"},
+	{"{x,7}", nil, "", "invalid TIPC service type"},
+	{"{,7}", nil, "", "invalid TIPC service type"},
+	{"{4294967296,7}", nil, "", "invalid TIPC service type"},
+	{"{1000,}", nil, "", "invalid TIPC instance"},
+	{"{1000,-1}", nil, "", "invalid TIPC instance"},
+	{"{1000,1,x}", nil, "", "invalid TIPC instance"},
+	{"1;2-3-4", nil, "", "invalid TIPC instance"},
+	{"1;2;3", nil, "", "invalid TIPC instance"},
+	{"{1000,9,5}", nil, "", "invalid TIPC name sequence bounds"},
+	{"{1000,7}@", nil, "", "invalid TIPC lookup domain"},
+	{"{1000,7}@1.1", nil, "", "invalid TIPC lookup domain"},
+	{"{1000,7}@256.0.0", nil, "", "invalid TIPC lookup domain"},
+	{"{1000,5,9}@1.1.1", nil, "", "unexpected lookup domain in TIPC name sequence"},
+	{"{1000,7}/", nil, "", "unknown TIPC scope"},
+	{"{1000,7}/world", nil, "", "unknown TIPC scope"},
+	{"{1000,7}/node/zone", nil, "", "malformed TIPC name"},
+	{"<1.1.1:42>/node", nil, "", "invalid TIPC port identity"},
+}
+
+func TestResolveTIPCAddr(t *testing.T) {
+	for _, tt := range resolveTIPCAddrTests {
+		a, err := ResolveTIPCAddr("tipc", tt.in)
+		if tt.err != "" {
+			if ae, ok := err.(*AddrError); !ok || ae.Err != tt.err || ae.Addr != tt.in {
+				t.Errorf("ResolveTIPCAddr(%q) = %v, %v; expected AddrError %q", tt.in, a, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ResolveTIPCAddr(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if *a != *tt.out {
+			t.Errorf("ResolveTIPCAddr(%q) = %+v; expected %+v", tt.in, a, tt.out)
+		}
+		str := tt.str
+		if str == "" {
+			str = tt.in
+		}
+		if s := a.String(); s != str {
+			t.Errorf("ResolveTIPCAddr(%q).String() = %q; expected %q", tt.in, s, str)
+		}
+		b, err := ResolveTIPCAddr("tipc", a.String())
+		if err != nil || *b != *a {
+			t.Errorf("ResolveTIPCAddr(%q) = %+v, %v; expected %+v", a.String(), b, err, a)
+		}
+	}
+}
+
+var tipcPortIDTests = []struct {
+	in   string
+	ref  uint32
+	node uint32
+	ok   bool
+}{
+	{"<1.1.1:12345>", 12345, 0x01001001, true},
+	{"<0.0.0:0>", 0, 0, true},
+	{"<255.4095.4095:4294967295>", 0xffffffff, 0xffffffff, true},
+
+	{"<1.1.1:>", 0, 0, false},
+	{"<1.1:5>", 0, 0, false},
+	{"<1.1.1.1:5>", 0, 0, false},
+	{"<256.1.1:5>", 0, 0, false},
+	{"<1.4096.1:5>", 0, 0, false},
+	{"<1.1.1:5", 0, 0, false},
+	{"<1..1:5>", 0, 0, false},
+}
+
+func TestResolveTIPCPortID(t *testing.T) {
+	for _, tt := range tipcPortIDTests {
+		a, err := ResolveTIPCAddr("tipc-rdm", tt.in)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("ResolveTIPCAddr(%q) = %v; expected error", tt.in, a)
+			} else if _, ok := err.(*AddrError); !ok {
+				t.Errorf("ResolveTIPCAddr(%q) failed with unexpected error type %T", tt.in, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ResolveTIPCAddr(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if a.AddrType != TIPC_ADDR_ID || a.Ref != tt.ref || a.Node != tt.node {
+			t.Errorf("ResolveTIPCAddr(%q) = %+v", tt.in, a)
+		}
+		if s := a.String(); s != tt.in {
+			t.Errorf("String() = %q; expected %q", s, tt.in)
+		}
+		sa, err := a.sockaddr(syscall.AF_TIPC)
+		if err != nil {
+			t.Errorf("sockaddr failed: %v", err)
+			continue
+		}
+		if b := sockaddrToTIPC(sa).(*TIPCAddr); b.AddrType != TIPC_ADDR_ID || b.Ref != tt.ref || b.Node != tt.node {
+			t.Errorf("sockaddrToTIPC(%+v) = %+v", sa, b)
+		}
+	}
+}
+
+func TestDialTIPCPortID(t *testing.T) {
+	skipTIPCTest(t)
+
+	c1, err := ListenTIPCPacket("tipc-rdm", nil)
+	if err != nil {
+		t.Fatalf("ListenTIPCPacket failed: %v", err)
+	}
+	defer c1.Close()
+	id := c1.LocalAddr().(*TIPCAddr)
+	if id.AddrType != TIPC_ADDR_ID {
+		t.Fatalf("got local address %+v; expected a port identity", id)
+	}
+	c2, err := Dial("tipc-rdm", id.String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c2.Close()
+	if _, err := c2.Write([]byte("TIPC PORT ID TEST")); err != nil {
+		t.Fatalf("Conn.Write failed: %v", err)
+	}
+	c1.SetReadDeadline(time.Now().Add(time.Second))
+	rb := make([]byte, 128)
+	_, from, err := c1.ReadFromTIPC(rb)
+	if err != nil {
+		t.Fatalf("TIPCPacketConn.ReadFromTIPC failed: %v", err)
+	}
+	if la := c2.LocalAddr().(*TIPCAddr); from.Ref != la.Ref || from.Node != la.Node {
+		t.Fatalf("got message from %v; expected %v", from, la)
+	}
+}
+
+func TestTIPCSockopts(t *testing.T) {
+	skipTIPCTest(t)
+
+	c, err := ListenTIPCPacket("tipc-rdm", nil)
+	if err != nil {
+		t.Fatalf("ListenTIPCPacket failed: %v", err)
+	}
+	defer c.Close()
+	if err := c.SetImportance(syscall.TIPC_HIGH_IMPORTANCE); err != nil {
+		t.Errorf("TIPCPacketConn.SetImportance failed: %v", err)
+	}
+	if err := c.SetSrcDroppable(false); err != nil {
+		t.Errorf("TIPCPacketConn.SetSrcDroppable failed: %v", err)
+	}
+	if err := c.SetDestDroppable(true); err != nil {
+		t.Errorf("TIPCPacketConn.SetDestDroppable failed: %v", err)
+	}
+	if n, err := c.SockRecvQueueDepth(); err != nil || n != 0 {
+		t.Errorf("TIPCPacketConn.SockRecvQueueDepth = %d, %v; expected 0, nil", n, err)
+	}
+	if _, err := c.NodeRecvQueueDepth(); err != nil {
+		t.Errorf("TIPCPacketConn.NodeRecvQueueDepth failed: %v", err)
+	}
+	if err := c.SetImportance(-1); err == nil {
+		t.Error("TIPCPacketConn.SetImportance accepted an invalid importance")
+	}
+}
+
+func TestTIPCReturnedMessage(t *testing.T) {
+	skipTIPCTest(t)
+
+	c, err := ListenTIPCPacket("tipc-rdm", nil)
+	if err != nil {
+		t.Fatalf("ListenTIPCPacket failed: %v", err)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(time.Second))
+
+	// Nobody is bound to the destination name, so the message
+	// comes back to the sender.
+	wb := []byte("UNDELIVERABLE TIPC MESSAGE")
+	dst := &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: 18892, Instance: 1, Domain: 0}
+	if _, err := c.WriteToTIPC(wb, dst); err != nil {
+		t.Fatalf("TIPCPacketConn.WriteToTIPC failed: %v", err)
+	}
+	b, oob := make([]byte, 128), make([]byte, 256)
+	_, oobn, _, _, err := c.ReadMsgTIPC(b, oob)
+	if err != nil {
+		t.Fatalf("TIPCPacketConn.ReadMsgTIPC failed: %v", err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		t.Fatalf("syscall.ParseSocketControlMessage failed: %v", err)
+	}
+	info, err := syscall.ParseTIPCMsgInfo(msgs)
+	if err != nil {
+		t.Fatalf("syscall.ParseTIPCMsgInfo failed: %v", err)
+	}
+	if info.ErrorCode != syscall.TIPC_ERR_NO_NAME || !bytes.Equal(info.ReturnedData, wb) {
+		t.Fatalf("got %+v; expected returned message with TIPC_ERR_NO_NAME", info)
+	}
+}
+
+func TestTIPCFileConn(t *testing.T) {
+	skipTIPCTest(t)
+
+	ln, err := Listen("tipc-seqpacket", "18893;1")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+	f, err := ln.(*TIPCListener).File()
+	if err != nil {
+		t.Fatalf("TIPCListener.File failed: %v", err)
+	}
+	l, err := FileListener(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("FileListener failed: %v", err)
+	}
+	defer l.Close()
+	if _, ok := l.(*TIPCListener); !ok {
+		t.Fatalf("FileListener returned %T; expected *TIPCListener", l)
+	}
+
+	c, err := Dial("tipc-seqpacket", "18893;1")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c.Close()
+	f, err = c.(*TIPCConn).File()
+	if err != nil {
+		t.Fatalf("TIPCConn.File failed: %v", err)
+	}
+	fc, err := FileConn(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("FileConn failed: %v", err)
+	}
+	defer fc.Close()
+	tc, ok := fc.(*TIPCConn)
+	if !ok {
+		t.Fatalf("FileConn returned %T; expected *TIPCConn", fc)
+	}
+	if tc.fd.net != "tipc-seqpacket" {
+		t.Fatalf("FileConn returned connection on %q; expected tipc-seqpacket", tc.fd.net)
+	}
+
+	pc, err := ListenTIPCPacket("tipc-rdm", nil)
+	if err != nil {
+		t.Fatalf("ListenTIPCPacket failed: %v", err)
+	}
+	defer pc.Close()
+	f, err = pc.File()
+	if err != nil {
+		t.Fatalf("TIPCPacketConn.File failed: %v", err)
+	}
+	defer f.Close()
+	fpc, err := FilePacketConn(f)
+	if err != nil {
+		t.Fatalf("FilePacketConn failed: %v", err)
+	}
+	defer fpc.Close()
+	if _, ok := fpc.(*TIPCPacketConn); !ok {
+		t.Fatalf("FilePacketConn returned %T; expected *TIPCPacketConn", fpc)
+	}
+	if l, err := FileListener(f); err == nil {
+		l.Close()
+		t.Fatal("FileListener on a reliable datagram socket succeeded")
+	}
+}
+
+func TestMockTIPCNameBinding(t *testing.T) {
+	defer installMockTIPCFabric(t).remove()
+
+	for _, net := range []string{"tipc", "tipc-seqpacket"} {
+		ln, err := ListenTIPC(net, &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: 18900, Instance: 7})
+		if err != nil {
+			t.Fatalf("ListenTIPC(%q) failed: %v", net, err)
+		}
+		c, err := Dial(net, "18900;7")
+		if err != nil {
+			ln.Close()
+			t.Fatalf("Dial(%q) failed: %v", net, err)
+		}
+		s, err := ln.AcceptTIPC()
+		if err != nil {
+			c.Close()
+			ln.Close()
+			t.Fatalf("TIPCListener.AcceptTIPC failed: %v", err)
+		}
+		if a := c.RemoteAddr().(*TIPCAddr); a.AddrType != TIPC_ADDR_ID || a.Ref != ln.Addr().(*TIPCAddr).Ref {
+			t.Errorf("got remote address %v; expected %v", a, ln.Addr())
+		}
+		if _, err := c.Write([]byte("HELLO")); err != nil {
+			t.Errorf("Conn.Write failed: %v", err)
+		}
+		b := make([]byte, 16)
+		if n, err := s.Read(b); err != nil || string(b[:n]) != "HELLO" {
+			t.Errorf("got %q, %v; expected %q", b[:n], err, "HELLO")
+		}
+		s.Close()
+		c.Close()
+		ln.Close()
+	}
+
+	if _, err := Dial("tipc", "18900;7"); err == nil {
+		t.Fatal("Dial to withdrawn name succeeded")
+	}
+}
+
+func TestMockTIPCRangeLookup(t *testing.T) {
+	defer installMockTIPCFabric(t).remove()
+
+	var rcvs []*TIPCPacketConn
+	for _, a := range []*TIPCAddr{
+		{AddrType: TIPC_ADDR_NAMESEQ, Service: 18901, Instance: 0, Domain: 9},
+		{AddrType: TIPC_ADDR_NAMESEQ, Service: 18901, Instance: 10, Domain: 19},
+	} {
+		c, err := ListenTIPCPacket("tipc-rdm", a)
+		if err != nil {
+			t.Fatalf("ListenTIPCPacket failed: %v", err)
+		}
+		defer c.Close()
+		c.SetReadDeadline(time.Now().Add(time.Second))
+		rcvs = append(rcvs, c)
+	}
+	for i, inst := range []uint32{5, 15} {
+		c, err := Dial("tipc-rdm", "18901;"+itoa(int(inst)))
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer c.Close()
+		if a := c.RemoteAddr().(*TIPCAddr); a.Ref != rcvs[i].LocalAddr().(*TIPCAddr).Ref {
+			t.Errorf("got remote address %v; expected %v", a, rcvs[i].LocalAddr())
+		}
+		if _, err := c.Write([]byte{byte(inst)}); err != nil {
+			t.Fatalf("Conn.Write failed: %v", err)
+		}
+		b := make([]byte, 16)
+		n, err := rcvs[i].Read(b)
+		if err != nil {
+			t.Fatalf("TIPCPacketConn.Read failed: %v", err)
+		}
+		if n != 1 || b[0] != byte(inst) {
+			t.Errorf("receiver %d got %v; expected [%d]", i, b[:n], inst)
+		}
+	}
+	if c, err := Dial("tipc-rdm", "18901;20"); err == nil {
+		c.Close()
+		t.Error("Dial to unbound instance succeeded")
+	}
+}
+
+func TestMockTIPCTopology(t *testing.T) {
+	defer installMockTIPCFabric(t).remove()
+
+	s, err := DialTIPCTopology()
+	if err != nil {
+		t.Fatalf("DialTIPCTopology failed: %v", err)
+	}
+	defer s.Close()
+	a := &TIPCAddr{AddrType: TIPC_ADDR_NAMESEQ, Service: 18903, Instance: 0, Domain: 99}
+	if err := s.Subscribe(a, TIPC_SUB_PORTS, 0); err != nil {
+		t.Fatalf("TIPCTopologySubscriber.Subscribe failed: %v", err)
+	}
+	if err := s.Subscribe(a, TIPC_SUB_SERVICE, 50*time.Millisecond); err != nil {
+		t.Fatalf("TIPCTopologySubscriber.Subscribe failed: %v", err)
+	}
+
+	c, err := ListenTIPCPacket("tipc-rdm", &TIPCAddr{AddrType: TIPC_ADDR_NAMESEQ, Service: 18903, Instance: 90, Domain: 200})
+	if err != nil {
+		t.Fatalf("ListenTIPCPacket failed: %v", err)
+	}
+	ref := c.LocalAddr().(*TIPCAddr).Ref
+	expected := []TIPCEvent{
+		{Type: TIPC_PUBLISHED, Lower: 90, Upper: 99, Ref: ref, Node: mockTIPCNode},
+		{Type: TIPC_PUBLISHED, Lower: 90, Upper: 99, Ref: ref, Node: mockTIPCNode},
+		{Type: TIPC_SUBSCR_TIMEOUT, Lower: 0, Upper: 99},
+		{Type: TIPC_WITHDRAWN, Lower: 90, Upper: 99, Ref: ref, Node: mockTIPCNode},
+	}
+	for i, want := range expected {
+		select {
+		case e, ok := <-s.Events():
+			if !ok {
+				t.Fatalf("event channel closed: %v", s.Err())
+			}
+			if e.Type != want.Type || e.Lower != want.Lower || e.Upper != want.Upper || e.Ref != want.Ref || e.Node != want.Node {
+				t.Fatalf("got %+v; expected %+v", e, want)
+			}
+			if e.Addr.Service != 18903 {
+				t.Fatalf("got subscription %v; expected service 18903", e.Addr)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %+v", want)
+		}
+		if i == 2 {
+			c.Close()
+		}
+	}
+}
+
+func TestTIPCPublish(t *testing.T) {
+	skipTIPCTest(t)
+
+	ln, err := ListenTIPC("tipc", &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: 18904, Instance: 1})
+	if err != nil {
+		t.Fatalf("ListenTIPC failed: %v", err)
+	}
+	defer ln.Close()
+	shard := &TIPCAddr{AddrType: TIPC_ADDR_NAMESEQ, Scope: TIPC_NODE_SCOPE, Service: 18904, Instance: 10, Domain: 19}
+	if err := ln.Publish(shard); err != nil {
+		t.Fatalf("TIPCListener.Publish failed: %v", err)
+	}
+	if addrs := ln.Addrs(); len(addrs) != 2 || *addrs[1] != *shard {
+		t.Fatalf("got %v; expected [{18904,1} %v]", addrs, shard)
+	}
+	c, err := Dial("tipc", "{18904,15}")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+
+	if err := ln.Withdraw(&TIPCAddr{AddrType: TIPC_ADDR_NAMESEQ, Service: 18904, Instance: 10, Domain: 19}); err != nil {
+		t.Fatalf("TIPCListener.Withdraw failed: %v", err)
+	}
+	if addrs := ln.Addrs(); len(addrs) != 1 || addrs[0].Instance != 1 {
+		t.Fatalf("got %v; expected [{18904,1}]", addrs)
+	}
+	if _, err := Dial("tipc", "{18904,15}"); err == nil {
+		t.Fatal("Dial to withdrawn name succeeded")
+	}
+	if err := ln.Withdraw(shard); err == nil {
+		t.Fatal("TIPCListener.Withdraw of withdrawn name succeeded")
+	}
+	if err := ln.Publish(&TIPCAddr{AddrType: TIPC_ADDR_ID, Ref: 1, Node: 1}); err == nil {
+		t.Fatal("TIPCListener.Publish of port identity succeeded")
+	}
+
+	pc, err := ListenTIPCPacket("tipc-rdm", nil)
+	if err != nil {
+		t.Fatalf("ListenTIPCPacket failed: %v", err)
+	}
+	defer pc.Close()
+	if addrs := pc.Addrs(); len(addrs) != 0 {
+		t.Fatalf("got %v; expected none", addrs)
+	}
+	if err := pc.Publish(&TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: 18904, Instance: 2}); err != nil {
+		t.Fatalf("TIPCPacketConn.Publish failed: %v", err)
+	}
+	if _, err := pc.WriteTo([]byte("SELF"), &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: 18904, Instance: 2}); err != nil {
+		t.Fatalf("TIPCPacketConn.WriteTo failed: %v", err)
+	}
+	b := make([]byte, 16)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	if n, _, err := pc.ReadFrom(b); err != nil || string(b[:n]) != "SELF" {
+		t.Fatalf("got %q, %v; expected %q", b[:n], err, "SELF")
+	}
+	if err := pc.Withdraw(&TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: 18904, Instance: 2}); err != nil {
+		t.Fatalf("TIPCPacketConn.Withdraw failed: %v", err)
+	}
+	if _, err := pc.WriteTo([]byte("SELF"), &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: 18904, Instance: 2}); err == nil {
+		t.Fatal("TIPCPacketConn.WriteTo to withdrawn name succeeded")
+	}
+}
+
+func TestTIPCGroupConn(t *testing.T) {
+	skipTIPCTest(t)
+
+	var ms []*TIPCGroupConn
+	for i := uint32(1); i <= 2; i++ {
+		c, err := ListenTIPCGroup("tipc-rdm")
+		if err != nil {
+			t.Fatalf("ListenTIPCGroup failed: %v", err)
+		}
+		defer c.Close()
+		c.SetReadDeadline(time.Now().Add(time.Second))
+		if err := c.Join(&TIPCAddr{AddrType: TIPC_ADDR_NAME, Scope: TIPC_NODE_SCOPE, Service: 18905, Instance: i}, syscall.TIPC_GROUP_MEMBER_EVTS); err != nil {
+			if err.(*OpError).Err.(*os.SyscallError).Err == syscall.ENOPROTOOPT {
+				t.Skip("skipping test; TIPC communication groups are not supported")
+			}
+			t.Fatalf("TIPCGroupConn.Join failed: %v", err)
+		}
+		ms = append(ms, c)
+	}
+
+	b := make([]byte, 16)
+	read := func(c *TIPCGroupConn, event uint32, instance uint32, data string) *TIPCAddr {
+		n, from, member, ev, err := c.ReadFromGroup(b)
+		if err != nil {
+			t.Fatalf("TIPCGroupConn.ReadFromGroup failed: %v", err)
+		}
+		if ev != event || member == nil || member.Service != 18905 || member.Instance != instance || string(b[:n]) != data {
+			t.Fatalf("got %q, %v, %v, event %d; expected %q from member %d, event %d", b[:n], from, member, ev, data, instance, event)
+		}
+		return from
+	}
+	from := read(ms[0], TIPC_PUBLISHED, 2, "")
+
+	if _, err := ms[0].Broadcast([]byte("BCAST")); err != nil {
+		t.Fatalf("TIPCGroupConn.Broadcast failed: %v", err)
+	}
+	read(ms[1], TIPC_PUBLISHED, 1, "")
+	read(ms[1], 0, 1, "BCAST")
+	if _, err := ms[1].Anycast([]byte("ANY"), 1); err != nil {
+		t.Fatalf("TIPCGroupConn.Anycast failed: %v", err)
+	}
+	read(ms[0], 0, 2, "ANY")
+	if _, err := ms[1].Multicast([]byte("MCAST"), 0, 1); err != nil {
+		t.Fatalf("TIPCGroupConn.Multicast failed: %v", err)
+	}
+	read(ms[0], 0, 2, "MCAST")
+	if _, err := ms[0].Unicast([]byte("UCAST"), from); err != nil {
+		t.Fatalf("TIPCGroupConn.Unicast failed: %v", err)
+	}
+	read(ms[1], 0, 1, "UCAST")
+
+	if err := ms[1].Leave(); err != nil {
+		t.Fatalf("TIPCGroupConn.Leave failed: %v", err)
+	}
+	read(ms[0], TIPC_WITHDRAWN, 2, "")
+	if _, err := ms[1].Broadcast([]byte("BCAST")); err == nil {
+		t.Fatal("TIPCGroupConn.Broadcast after Leave succeeded")
+	}
+}
+
+var tipcGroupReqTests = []struct {
+	member *TIPCAddr
+	flags  int
+	opt    int
+	req    *syscall.TIPCGroupReq
+}{
+	{
+		&TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: 18905, Instance: 1},
+		0,
+		syscall.TIPC_GROUP_JOIN,
+		&syscall.TIPCGroupReq{Type: 18905, Instance: 1, Scope: TIPC_CLUSTER_SCOPE},
+	},
+	{
+		&TIPCAddr{AddrType: TIPC_ADDR_NAME, Scope: TIPC_NODE_SCOPE, Service: 18905, Instance: 2},
+		syscall.TIPC_GROUP_LOOPBACK | syscall.TIPC_GROUP_MEMBER_EVTS,
+		syscall.TIPC_GROUP_JOIN,
+		&syscall.TIPCGroupReq{Type: 18905, Instance: 2, Scope: TIPC_NODE_SCOPE, Flags: syscall.TIPC_GROUP_LOOPBACK | syscall.TIPC_GROUP_MEMBER_EVTS},
+	},
+	{nil, 0, syscall.TIPC_GROUP_LEAVE, nil},
+}
+
+func TestTIPCGroupReq(t *testing.T) {
+	for _, tt := range tipcGroupReqTests {
+		opt, req := tipcGroupReq(tt.member, tt.flags)
+		if opt != tt.opt || (req == nil) != (tt.req == nil) || req != nil && *req != *tt.req {
+			t.Errorf("tipcGroupReq(%v, %#x) = %#x, %+v; expected %#x, %+v", tt.member, tt.flags, opt, req, tt.opt, tt.req)
+		}
+	}
+}
+
+func TestTIPCGroupAddrs(t *testing.T) {
+	c := &TIPCGroupConn{conn: conn{&netFD{family: syscall.AF_TIPC, net: "tipc-rdm"}}}
+	if _, err := c.anycastAddr(1); err != errNotTIPCGroupMember {
+		t.Fatalf("got %v before joining; expected %v", err, errNotTIPCGroupMember)
+	}
+	if _, err := c.Unicast(nil, &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: 18905, Instance: 1}); err == nil {
+		t.Fatal("Unicast to a name succeeded")
+	}
+	c.member = &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: 18905, Instance: 1}
+
+	for _, tt := range []struct {
+		name string
+		addr func() (*TIPCAddr, error)
+		sa   syscall.SockaddrTIPC
+	}{
+		{
+			"unicast",
+			func() (*TIPCAddr, error) {
+				return &TIPCAddr{AddrType: TIPC_ADDR_ID, Ref: 0x12345678, Node: 0x01001001}, nil
+			},
+			syscall.SockaddrTIPC{AddrType: TIPC_ADDR_ID, Scope: TIPC_ZONE_SCOPE, ID: syscall.TIPCPortID{Ref: 0x12345678, Node: 0x01001001}},
+		},
+		{
+			"anycast",
+			func() (*TIPCAddr, error) { return c.anycastAddr(2) },
+			syscall.SockaddrTIPC{AddrType: TIPC_ADDR_NAME, Scope: TIPC_ZONE_SCOPE, Name: syscall.TIPCName{Type: 18905, Instance: 2}},
+		},
+		{
+			"multicast",
+			func() (*TIPCAddr, error) { return c.multicastAddr(0, 9) },
+			syscall.SockaddrTIPC{AddrType: TIPC_ADDR_MCAST, Scope: TIPC_ZONE_SCOPE, NameSeq: syscall.TIPCNameSeq{Type: 18905, Lower: 0, Upper: 9}},
+		},
+	} {
+		a, err := tt.addr()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		sa, err := a.sockaddr(syscall.AF_TIPC)
+		if err != nil {
+			t.Errorf("%s: sockaddr failed: %v", tt.name, err)
+			continue
+		}
+		if got := sa.(*syscall.SockaddrTIPC); *got != tt.sa {
+			t.Errorf("%s: got %+v; expected %+v", tt.name, got, &tt.sa)
+		}
+	}
+}
+
+func TestTIPCGroupEvent(t *testing.T) {
+	for _, tt := range []struct {
+		flags int
+		event uint32
+	}{
+		{0, 0},
+		{syscall.MSG_EOR, 0},
+		{syscall.MSG_OOB, TIPC_PUBLISHED},
+		{syscall.MSG_OOB | syscall.MSG_EOR, TIPC_WITHDRAWN},
+		{syscall.MSG_OOB | syscall.MSG_TRUNC, TIPC_PUBLISHED},
+	} {
+		if event := tipcGroupEvent(tt.flags); event != tt.event {
+			t.Errorf("tipcGroupEvent(%#x) = %d; expected %d", tt.flags, event, tt.event)
+		}
+	}
+}
+
+var parseTIPCNodeAddrTests = []struct {
+	in                  string
+	zone, cluster, node int
+	ok                  bool
+}{
+	{"1.1.1", 1, 1, 1, true},
+	{"0.0.0", 0, 0, 0, true},
+	{"255.4095.4095", 255, 4095, 4095, true},
+
+	{"", 0, 0, 0, false},
+	{"1.1", 0, 0, 0, false},
+	{"1.1.1.", 0, 0, 0, false},
+	{"256.1.1", 0, 0, 0, false},
+	{"1.1.4096", 0, 0, 0, false},
+	{"a.b.c", 0, 0, 0, false},
+}
+
+func TestParseTIPCNodeAddr(t *testing.T) {
+	for _, tt := range parseTIPCNodeAddrTests {
+		n, err := ParseTIPCNodeAddr(tt.in)
+		if !tt.ok {
+			if _, ok := err.(*AddrError); !ok {
+				t.Errorf("ParseTIPCNodeAddr(%q) = %v, %v; expected AddrError", tt.in, n, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTIPCNodeAddr(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if n.Zone() != tt.zone || n.Cluster() != tt.cluster || n.Node() != tt.node {
+			t.Errorf("ParseTIPCNodeAddr(%q) = %d.%d.%d", tt.in, n.Zone(), n.Cluster(), n.Node())
+		}
+		if s := n.String(); s != tt.in {
+			t.Errorf("String() = %q; expected %q", s, tt.in)
+		}
+	}
+}
+
+func TestMockTIPCNodeWatcher(t *testing.T) {
+	defer installMockTIPCFabric(t).remove()
+
+	local, err := TIPCLocalNode()
+	if err != nil {
+		t.Fatalf("TIPCLocalNode failed: %v", err)
+	}
+	if local != mockTIPCNode {
+		t.Fatalf("got local node %v; expected %v", local, TIPCNodeAddr(mockTIPCNode))
+	}
+
+	w, err := WatchTIPCNodes()
+	if err != nil {
+		t.Fatalf("WatchTIPCNodes failed: %v", err)
+	}
+	defer w.Close()
+
+	// Stand in for the topology service announcing node 1.1.2.
+	peer, _ := ParseTIPCNodeAddr("1.1.2")
+	c, err := ListenTIPCPacket("tipc-rdm", &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: TIPC_NODE_STATE, Instance: uint32(peer)})
+	if err != nil {
+		t.Fatalf("ListenTIPCPacket failed: %v", err)
+	}
+	for _, up := range []bool{true, false} {
+		select {
+		case ev, ok := <-w.Events():
+			if !ok {
+				t.Fatalf("event channel closed: %v", w.Err())
+			}
+			if ev.Node != peer || ev.Up != up {
+				t.Fatalf("got %+v; expected node %v up %v", ev, peer, up)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for node %v up %v", peer, up)
+		}
+		if up {
+			c.Close()
+		}
+	}
+}
+
+func TestMockTIPCDialer(t *testing.T) {
+	f := installMockTIPCFabric(t)
+	defer f.remove()
+
+	ln, err := Listen("tipc", "{4711,1}")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	origHook := testHookSetKeepAlive
+	defer func() { testHookSetKeepAlive = origHook }()
+	keepAlive := false
+	testHookSetKeepAlive = func() { keepAlive = true }
+
+	d := &Dialer{Timeout: 5 * time.Second, KeepAlive: time.Second}
+	c, err := d.Dial("tipc", "{4711,1}")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c.Close()
+	if timeout := f.sockopts(c.(*TIPCConn).fd).connTimeout; timeout <= 4*time.Second || timeout > 5*time.Second {
+		t.Errorf("got TIPC_CONN_TIMEOUT %v; expected close to %v", timeout, d.Timeout)
+	}
+	if keepAlive {
+		t.Error("keep-alive set on TIPC connection")
+	}
+
+	c2, err := Dial("tipc", "{4711,1}")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c2.Close()
+	if timeout := f.sockopts(c2.(*TIPCConn).fd).connTimeout; timeout != 0 {
+		t.Errorf("got TIPC_CONN_TIMEOUT %v without a timeout; expected the kernel default", timeout)
+	}
+
+	d = &Dialer{LocalAddr: &UnixAddr{Name: "@tipc", Net: "tipc"}}
+	if c, err := d.Dial("tipc", "{4711,1}"); err == nil {
+		c.Close()
+		t.Fatal("Dial succeeded with a foreign local address")
+	} else if _, ok := err.(*OpError).Err.(*AddrError); !ok {
+		t.Errorf("got %v; expected an address error", err)
+	}
+}
+
+func TestTIPCConnTimeout(t *testing.T) {
+	skipTIPCTest(t)
+
+	ln, err := ListenTIPC("tipc", &TIPCAddr{AddrType: TIPC_ADDR_NAME, Scope: TIPC_NODE_SCOPE, Service: 18906, Instance: 1})
+	if err != nil {
+		t.Fatalf("ListenTIPC failed: %v", err)
+	}
+	defer ln.Close()
+	c, err := DialTIPC("tipc", nil, &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: 18906, Instance: 1})
+	if err != nil {
+		t.Fatalf("DialTIPC failed: %v", err)
+	}
+	defer c.Close()
+
+	for _, tt := range []struct {
+		d    time.Duration
+		want int
+	}{
+		{3 * time.Second, 3000},
+		{1500 * time.Microsecond, 2},
+		{0, 0},
+	} {
+		if err := c.SetConnTimeout(tt.d); err != nil {
+			t.Fatalf("SetConnTimeout(%v) failed: %v", tt.d, err)
+		}
+		ms, err := syscall.GetsockoptInt(c.fd.sysfd, syscall.SOL_TIPC, syscall.TIPC_CONN_TIMEOUT)
+		if err != nil {
+			t.Fatalf("GetsockoptInt failed: %v", err)
+		}
+		if ms != tt.want {
+			t.Errorf("SetConnTimeout(%v): got TIPC_CONN_TIMEOUT %dms; expected %dms", tt.d, ms, tt.want)
+		}
+	}
+
+	c.Close()
+	if err := c.SetConnTimeout(time.Second); err == nil {
+		t.Error("SetConnTimeout succeeded on a closed connection")
+	}
+}
+
+func TestMockTIPCListenConfig(t *testing.T) {
+	f := installMockTIPCFabric(t)
+	defer f.remove()
+
+	lc := &TIPCListenConfig{Importance: syscall.TIPC_HIGH_IMPORTANCE, Scope: TIPC_NODE_SCOPE}
+	ln, err := lc.Listen("tipc", "{4711,1}")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+	l := ln.(*TIPCListener)
+	if opts := f.sockopts(l.fd); opts.importance != syscall.TIPC_HIGH_IMPORTANCE {
+		t.Errorf("got importance %d; expected %d", opts.importance, syscall.TIPC_HIGH_IMPORTANCE)
+	}
+	if addrs := l.Addrs(); len(addrs) != 1 || addrs[0].String() != "{4711,1}/node" {
+		t.Errorf("got names %v; expected [{4711,1}/node]", addrs)
+	}
+
+	c, err := lc.ListenPacket("tipc-rdm", "{4711,2}")
+	if err != nil {
+		t.Fatalf("ListenPacket failed: %v", err)
+	}
+	defer c.Close()
+	if opts := f.sockopts(c.(*TIPCPacketConn).fd); opts.importance != syscall.TIPC_HIGH_IMPORTANCE {
+		t.Errorf("got importance %d; expected %d", opts.importance, syscall.TIPC_HIGH_IMPORTANCE)
+	}
+
+	if _, err := lc.Listen("tcp", "127.0.0.1:0"); err == nil {
+		t.Error("Listen succeeded on a non-TIPC network")
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tipcconfig
+
+import (
+	"net/internal/netlink"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// netlinkTransport exchanges messages over a NETLINK_GENERIC socket.
+type netlinkTransport struct {
+	s      int
+	kernel *syscall.SockaddrNetlink
+}
+
+func dialNetlink() (transport, error) {
+	s, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW, syscall.NETLINK_GENERIC)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	syscall.CloseOnExec(s)
+	kernel := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}
+	if err := syscall.Bind(s, kernel); err != nil {
+		syscall.Close(s)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	return &netlinkTransport{s: s, kernel: kernel}, nil
+}
+
+func (t *netlinkTransport) roundTrip(req []byte) ([]byte, error) {
+	if err := syscall.Sendto(t.s, req, 0, t.kernel); err != nil {
+		return nil, os.NewSyscallError("sendto", err)
+	}
+	seq := (*syscall.NlMsghdr)(unsafe.Pointer(&req[0])).Seq
+	var b []byte
+	rb := make([]byte, syscall.Getpagesize())
+	for {
+		n, _, err := syscall.Recvfrom(t.s, rb, 0)
+		if err != nil {
+			return nil, os.NewSyscallError("recvfrom", err)
+		}
+		b = append(b, rb[:n]...)
+		if _, done, err := netlink.ParseReplies(rb[:n], seq); done || err != nil {
+			return b, nil
+		}
+	}
+}
+
+func (t *netlinkTransport) close() error {
+	return os.NewSyscallError("close", syscall.Close(t.s))
+}
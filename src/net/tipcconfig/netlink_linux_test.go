@@ -0,0 +1,29 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tipcconfig
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestDial(t *testing.T) {
+	c, err := Dial()
+	if e, ok := err.(*Error); ok && e.Err == syscall.ENOENT {
+		t.Skip("TIPC module not loaded")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	n, err := c.Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("network %d, address %v, identity %x", n.NetID, n.Addr, n.ID)
+	if _, err := c.Links(); err != nil {
+		t.Error(err)
+	}
+}
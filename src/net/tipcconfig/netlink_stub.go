@@ -0,0 +1,15 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package tipcconfig
+
+import "errors"
+
+var errNoNetlink = errors.New("generic netlink not supported on this system")
+
+func dialNetlink() (transport, error) {
+	return nil, errNoNetlink
+}
@@ -0,0 +1,698 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tipcconfig configures the TIPC protocol stack of the local
+// node through the "TIPCv2" generic netlink family of the Linux
+// kernel.  It enables and disables the bearers that attach the node
+// to its cluster, reports the links to other nodes with their
+// statistics, tunes link properties and manages the address and
+// identity of the node.
+//
+// Most requests that change the configuration require the
+// CAP_NET_ADMIN capability.
+package tipcconfig
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/internal/netlink"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Netlink request flags from linux/netlink.h.
+const (
+	nlmFAck  = 0x4
+	nlmFDump = 0x300
+)
+
+// TIPC generic netlink definitions from linux/tipc_netlink.h.
+const (
+	familyName    = "TIPCv2"
+	familyVersion = 1
+
+	cmdBearerDisable = 2
+	cmdBearerEnable  = 3
+	cmdBearerGet     = 4
+	cmdLinkGet       = 8
+	cmdLinkSet       = 9
+	cmdMediaGet      = 11
+	cmdNetGet        = 14
+	cmdNetSet        = 15
+
+	attrBearer = 1
+	attrLink   = 4
+	attrMedia  = 5
+	attrNet    = 7
+
+	bearerName    = 1
+	bearerProp    = 2
+	bearerDomain  = 3
+	bearerUDPOpts = 4
+
+	udpLocal  = 1
+	udpRemote = 2
+
+	linkName      = 2
+	linkDest      = 3
+	linkMTU       = 4
+	linkBroadcast = 5
+	linkUp        = 6
+	linkActive    = 7
+	linkProp      = 8
+	linkStats     = 9
+	linkRx        = 10
+	linkTx        = 11
+
+	mediaName = 1
+	mediaProp = 2
+
+	netID       = 1
+	netAddr     = 2
+	netNodeID   = 3
+	netNodeIDW1 = 4
+
+	propPrio = 1
+	propTol  = 2
+	propWin  = 3
+)
+
+// Attributes of struct tipc_nla_stats.
+const (
+	statsRxInfo = 1 + iota
+	statsRxFragments
+	statsRxFragmented
+	statsRxBundles
+	statsRxBundled
+	statsTxInfo
+	statsTxFragments
+	statsTxFragmented
+	statsTxBundles
+	statsTxBundled
+	statsMsgProfTot
+	statsMsgLenCnt
+	statsMsgLenTot
+	statsMsgLenP0
+	statsMsgLenP1
+	statsMsgLenP2
+	statsMsgLenP3
+	statsMsgLenP4
+	statsMsgLenP5
+	statsMsgLenP6
+	statsRxStates
+	statsRxProbes
+	statsRxNacks
+	statsRxDeferred
+	statsTxStates
+	statsTxProbes
+	statsTxNacks
+	statsTxAcks
+	statsRetransmitted
+	statsDuplicates
+	statsLinkCongs
+	statsMaxQueue
+	statsAvgQueue
+)
+
+// nodeIDLen is the size of a TIPC node identity.
+const nodeIDLen = 16
+
+var (
+	errClosed       = errors.New("use of closed connection")
+	errBearerName   = errors.New("bearer name must be of the form media:interface")
+	errUDPAddrs     = errors.New("UDP bearer needs a local and a remote address")
+	errNodeIDLength = errors.New("node identity longer than 16 bytes")
+)
+
+// Error reports a failed configuration request.
+type Error struct {
+	Op  string // the request, such as "enable bearer"
+	Err error  // the error reported by the kernel or the package
+}
+
+func (e *Error) Error() string { return "tipcconfig: " + e.Op + ": " + e.Err.Error() }
+
+// A transport exchanges netlink messages with the kernel.
+type transport interface {
+	// roundTrip sends the request req and returns the stream of
+	// replies up to the one that netlink.ParseReplies reports as
+	// the last.
+	roundTrip(req []byte) ([]byte, error)
+	close() error
+}
+
+// Conn is a connection to the TIPC configuration interface of the
+// kernel.  It is safe for concurrent use by multiple goroutines.
+type Conn struct {
+	mu     sync.Mutex
+	t      transport // nil once closed
+	seq    uint32
+	family uint16
+}
+
+// Dial connects to the TIPC configuration interface of the kernel.
+func Dial() (*Conn, error) {
+	t, err := dialNetlink()
+	if err != nil {
+		return nil, &Error{Op: "dial", Err: err}
+	}
+	c, err := newConn(t)
+	if err != nil {
+		t.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// newConn returns a connection over t after resolving the TIPCv2
+// family.
+func newConn(t transport) (*Conn, error) {
+	c := &Conn{t: t}
+	msgs, err := c.request("resolve family", netlink.GenlIDCtrl, netlink.CtrlCmdGetFamily, nlmFAck, netlink.StringAttr(netlink.CtrlAttrFamilyName, familyName))
+	if err != nil {
+		return nil, err
+	}
+	if c.family, err = netlink.FamilyID(msgs); err != nil {
+		return nil, &Error{Op: "resolve family", Err: err}
+	}
+	return c, nil
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.t == nil {
+		return &Error{Op: "close", Err: errClosed}
+	}
+	err := c.t.close()
+	c.t = nil
+	return err
+}
+
+// request sends the command cmd to family and returns the replies.
+func (c *Conn) request(op string, family uint16, cmd uint8, flags uint16, attrs ...netlink.Attr) ([]netlink.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.t == nil {
+		return nil, &Error{Op: op, Err: errClosed}
+	}
+	c.seq++
+	b, err := c.t.roundTrip(netlink.NewRequest(family, flags, c.seq, cmd, familyVersion, attrs...))
+	if err != nil {
+		return nil, &Error{Op: op, Err: err}
+	}
+	msgs, _, err := netlink.ParseReplies(b, c.seq)
+	if err != nil {
+		return nil, &Error{Op: op, Err: err}
+	}
+	return msgs, nil
+}
+
+// do sends the TIPC command cmd and waits for its acknowledgement.
+func (c *Conn) do(op string, cmd uint8, attrs ...netlink.Attr) error {
+	_, err := c.request(op, c.family, cmd, nlmFAck, attrs...)
+	return err
+}
+
+// dump sends the TIPC dump request cmd and returns the values of the
+// top-level attributes typ of the replies.
+func (c *Conn) dump(op string, cmd uint8, typ uint16, attrs ...netlink.Attr) ([][]byte, error) {
+	msgs, err := c.request(op, c.family, cmd, nlmFDump, attrs...)
+	if err != nil {
+		return nil, err
+	}
+	var vals [][]byte
+	for _, m := range msgs {
+		for _, a := range m.Attrs {
+			if a.Type == typ {
+				vals = append(vals, a.Value)
+			}
+		}
+	}
+	return vals, nil
+}
+
+// Props are the properties of the links established over a bearer
+// or a media, or of a single link.
+type Props struct {
+	Priority  int           // link selection priority, 0 to 31
+	Tolerance time.Duration // time before an unresponsive link is reset
+	Window    int           // send window, in packets
+}
+
+func parseProps(b []byte) (Props, error) {
+	var p Props
+	attrs, err := netlink.ParseAttrs(b)
+	if err != nil {
+		return p, err
+	}
+	for _, a := range attrs {
+		switch a.Type {
+		case propPrio, propTol, propWin:
+		default:
+			continue
+		}
+		v, err := a.Uint32()
+		if err != nil {
+			return p, err
+		}
+		switch a.Type {
+		case propPrio:
+			p.Priority = int(v)
+		case propTol:
+			p.Tolerance = time.Duration(v) * time.Millisecond
+		case propWin:
+			p.Window = int(v)
+		}
+	}
+	return p, nil
+}
+
+// BearerConfig holds the optional parameters of a bearer being
+// enabled.
+type BearerConfig struct {
+	// Domain limits neighbour discovery to the nodes within the
+	// given zone, cluster or node.  Zero means the own cluster.
+	Domain net.TIPCNodeAddr
+
+	// Priority, if nonzero, overrides the link priority of the
+	// media.
+	Priority int
+
+	// Local and Remote are required for "udp:" bearers.  Local is
+	// the address the bearer listens on and Remote the multicast
+	// group or the unicast peer used for neighbour discovery.
+	Local, Remote *net.UDPAddr
+}
+
+// EnableBearer enables the bearer name, such as "eth:eth0" or
+// "udp:bearer1".  A nil cfg means the defaults, which do not suit
+// UDP bearers.
+func (c *Conn) EnableBearer(name string, cfg *BearerConfig) error {
+	if cfg == nil {
+		cfg = &BearerConfig{}
+	}
+	media := strings.SplitN(name, ":", 2)
+	if len(media) != 2 || media[0] == "" || media[1] == "" {
+		return &Error{Op: "enable bearer", Err: errBearerName}
+	}
+	attrs := []netlink.Attr{netlink.StringAttr(bearerName, name)}
+	if cfg.Domain != 0 {
+		attrs = append(attrs, netlink.Uint32Attr(bearerDomain, uint32(cfg.Domain)))
+	}
+	if cfg.Priority != 0 {
+		attrs = append(attrs, netlink.NestedAttr(bearerProp, netlink.Uint32Attr(propPrio, uint32(cfg.Priority))))
+	}
+	if media[0] == "udp" {
+		if cfg.Local == nil || cfg.Remote == nil {
+			return &Error{Op: "enable bearer", Err: errUDPAddrs}
+		}
+		local, err := marshalSockaddr(cfg.Local)
+		if err != nil {
+			return &Error{Op: "enable bearer", Err: err}
+		}
+		remote, err := marshalSockaddr(cfg.Remote)
+		if err != nil {
+			return &Error{Op: "enable bearer", Err: err}
+		}
+		attrs = append(attrs, netlink.NestedAttr(bearerUDPOpts, netlink.Attr{Type: udpLocal, Value: local}, netlink.Attr{Type: udpRemote, Value: remote}))
+	}
+	return c.do("enable bearer", cmdBearerEnable, netlink.NestedAttr(attrBearer, attrs...))
+}
+
+// DisableBearer disables the bearer name, taking down the links
+// established over it.
+func (c *Conn) DisableBearer(name string) error {
+	return c.do("disable bearer", cmdBearerDisable, netlink.NestedAttr(attrBearer, netlink.StringAttr(bearerName, name)))
+}
+
+// Linux socket address definitions for UDP bearer addresses.
+const (
+	afInet                = 2
+	afInet6               = 10
+	sizeofSockaddrStorage = 128
+)
+
+// nativeEndian is the byte order of the host, in which the address
+// family of a socket address is encoded.
+var nativeEndian binary.ByteOrder
+
+func init() {
+	i := uint16(1)
+	if *(*byte)(unsafe.Pointer(&i)) == 1 {
+		nativeEndian = binary.LittleEndian
+	} else {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// marshalSockaddr encodes a as the struct sockaddr_storage expected
+// in the options of a UDP bearer.
+func marshalSockaddr(a *net.UDPAddr) ([]byte, error) {
+	b := make([]byte, sizeofSockaddrStorage)
+	b[2], b[3] = byte(a.Port>>8), byte(a.Port)
+	if ip4 := a.IP.To4(); ip4 != nil {
+		nativeEndian.PutUint16(b[0:2], afInet)
+		copy(b[4:8], ip4)
+		return b, nil
+	}
+	if len(a.IP) != net.IPv6len {
+		return nil, &net.AddrError{Err: "invalid UDP bearer address", Addr: a.String()}
+	}
+	nativeEndian.PutUint16(b[0:2], afInet6)
+	copy(b[8:24], a.IP)
+	if a.Zone != "" {
+		ifi, err := net.InterfaceByName(a.Zone)
+		if err != nil {
+			return nil, err
+		}
+		nativeEndian.PutUint32(b[24:28], uint32(ifi.Index))
+	}
+	return b, nil
+}
+
+// Bearer describes an enabled bearer.
+type Bearer struct {
+	Name string
+	Props
+}
+
+// Bearers returns the enabled bearers.
+func (c *Conn) Bearers() ([]Bearer, error) {
+	vals, err := c.dump("list bearers", cmdBearerGet, attrBearer)
+	if err != nil {
+		return nil, err
+	}
+	var bearers []Bearer
+	for _, v := range vals {
+		var b Bearer
+		if b.Name, b.Props, err = parseNamedProps(v, bearerName, bearerProp); err != nil {
+			return nil, &Error{Op: "list bearers", Err: err}
+		}
+		bearers = append(bearers, b)
+	}
+	return bearers, nil
+}
+
+// Media describes a media type, such as "eth" or "udp", that bearers
+// can be enabled on.
+type Media struct {
+	Name string
+	Props
+}
+
+// Media returns the media types known to the kernel.
+func (c *Conn) Media() ([]Media, error) {
+	vals, err := c.dump("list media", cmdMediaGet, attrMedia)
+	if err != nil {
+		return nil, err
+	}
+	var media []Media
+	for _, v := range vals {
+		var m Media
+		if m.Name, m.Props, err = parseNamedProps(v, mediaName, mediaProp); err != nil {
+			return nil, &Error{Op: "list media", Err: err}
+		}
+		media = append(media, m)
+	}
+	return media, nil
+}
+
+// parseNamedProps decodes the name and properties of a bearer or a
+// media.
+func parseNamedProps(b []byte, nameTyp, propTyp uint16) (name string, p Props, err error) {
+	attrs, err := netlink.ParseAttrs(b)
+	if err != nil {
+		return "", p, err
+	}
+	for _, a := range attrs {
+		switch a.Type {
+		case nameTyp:
+			name = a.String()
+		case propTyp:
+			if p, err = parseProps(a.Value); err != nil {
+				return "", p, err
+			}
+		}
+	}
+	return name, p, nil
+}
+
+// LinkStats are the traffic statistics of a link.
+type LinkStats struct {
+	RxInfo, TxInfo             uint32 // data messages
+	RxFragments, TxFragments   uint32 // fragments of fragmented messages
+	RxFragmented, TxFragmented uint32 // fragmented messages
+	RxBundles, TxBundles       uint32 // bundle packets
+	RxBundled, TxBundled       uint32 // messages carried in bundles
+	RxStates, TxStates         uint32 // link state messages
+	RxProbes, TxProbes         uint32 // link probes
+	RxNacks, TxNacks           uint32 // negative acknowledgements
+	RxDeferred                 uint32 // packets received out of order
+	TxAcks                     uint32 // acknowledgements sent
+	Retransmitted              uint32 // packets retransmitted
+	Duplicates                 uint32 // duplicate packets received
+	Congestions                uint32 // times the link was congested
+	MaxQueue                   uint32 // longest send queue, in packets
+	AvgQueue                   uint32 // average send queue, in packets
+}
+
+func parseLinkStats(b []byte) (LinkStats, error) {
+	var s LinkStats
+	attrs, err := netlink.ParseAttrs(b)
+	if err != nil {
+		return s, err
+	}
+	for _, a := range attrs {
+		var v *uint32
+		switch a.Type {
+		case statsRxInfo:
+			v = &s.RxInfo
+		case statsRxFragments:
+			v = &s.RxFragments
+		case statsRxFragmented:
+			v = &s.RxFragmented
+		case statsRxBundles:
+			v = &s.RxBundles
+		case statsRxBundled:
+			v = &s.RxBundled
+		case statsTxInfo:
+			v = &s.TxInfo
+		case statsTxFragments:
+			v = &s.TxFragments
+		case statsTxFragmented:
+			v = &s.TxFragmented
+		case statsTxBundles:
+			v = &s.TxBundles
+		case statsTxBundled:
+			v = &s.TxBundled
+		case statsRxStates:
+			v = &s.RxStates
+		case statsRxProbes:
+			v = &s.RxProbes
+		case statsRxNacks:
+			v = &s.RxNacks
+		case statsRxDeferred:
+			v = &s.RxDeferred
+		case statsTxStates:
+			v = &s.TxStates
+		case statsTxProbes:
+			v = &s.TxProbes
+		case statsTxNacks:
+			v = &s.TxNacks
+		case statsTxAcks:
+			v = &s.TxAcks
+		case statsRetransmitted:
+			v = &s.Retransmitted
+		case statsDuplicates:
+			v = &s.Duplicates
+		case statsLinkCongs:
+			v = &s.Congestions
+		case statsMaxQueue:
+			v = &s.MaxQueue
+		case statsAvgQueue:
+			v = &s.AvgQueue
+		default:
+			continue
+		}
+		if *v, err = a.Uint32(); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// Link describes a link to another node, or the broadcast link.
+type Link struct {
+	Name      string
+	Peer      net.TIPCNodeAddr // zero for the broadcast link
+	MTU       int
+	Up        bool
+	Active    bool // whether the link carries traffic
+	Broadcast bool
+	Props
+	RxPackets uint32
+	TxPackets uint32
+	Stats     LinkStats
+}
+
+// Links returns the links of the local node with their statistics.
+func (c *Conn) Links() ([]Link, error) {
+	vals, err := c.dump("list links", cmdLinkGet, attrLink)
+	if err != nil {
+		return nil, err
+	}
+	var links []Link
+	for _, v := range vals {
+		l, err := parseLink(v)
+		if err != nil {
+			return nil, &Error{Op: "list links", Err: err}
+		}
+		links = append(links, l)
+	}
+	return links, nil
+}
+
+func parseLink(b []byte) (Link, error) {
+	var l Link
+	attrs, err := netlink.ParseAttrs(b)
+	if err != nil {
+		return l, err
+	}
+	for _, a := range attrs {
+		var v uint32
+		switch a.Type {
+		case linkName:
+			l.Name = a.String()
+		case linkBroadcast:
+			l.Broadcast = true
+		case linkUp:
+			l.Up = true
+		case linkActive:
+			l.Active = true
+		case linkProp:
+			l.Props, err = parseProps(a.Value)
+		case linkStats:
+			l.Stats, err = parseLinkStats(a.Value)
+		case linkDest:
+			v, err = a.Uint32()
+			l.Peer = net.TIPCNodeAddr(v)
+		case linkMTU:
+			v, err = a.Uint32()
+			l.MTU = int(v)
+		case linkRx:
+			l.RxPackets, err = a.Uint32()
+		case linkTx:
+			l.TxPackets, err = a.Uint32()
+		}
+		if err != nil {
+			return l, err
+		}
+	}
+	return l, nil
+}
+
+func (c *Conn) setLinkProp(name string, prop netlink.Attr) error {
+	return c.do("set link", cmdLinkSet, netlink.NestedAttr(attrLink, netlink.StringAttr(linkName, name), netlink.NestedAttr(linkProp, prop)))
+}
+
+// SetLinkTolerance sets the time after which the link name is reset
+// when the peer stops responding.
+func (c *Conn) SetLinkTolerance(name string, d time.Duration) error {
+	return c.setLinkProp(name, netlink.Uint32Attr(propTol, uint32(d/time.Millisecond)))
+}
+
+// SetLinkPriority sets the priority of the link name.  Of the links
+// to a peer, those of the highest priority carry the traffic.
+func (c *Conn) SetLinkPriority(name string, prio int) error {
+	return c.setLinkProp(name, netlink.Uint32Attr(propPrio, uint32(prio)))
+}
+
+// SetLinkWindow sets the send window of the link name, in packets.
+func (c *Conn) SetLinkWindow(name string, win int) error {
+	return c.setLinkProp(name, netlink.Uint32Attr(propWin, uint32(win)))
+}
+
+// Node describes the identity of the local node.
+type Node struct {
+	NetID uint32           // identifier of the cluster network
+	Addr  net.TIPCNodeAddr // zero until the node has an address
+	ID    []byte           // node identity; nil if not reported
+}
+
+// Node returns the identity of the local node.
+func (c *Conn) Node() (*Node, error) {
+	vals, err := c.dump("get node", cmdNetGet, attrNet)
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) == 0 {
+		return nil, &Error{Op: "get node", Err: netlink.ErrMalformed}
+	}
+	n, err := parseNode(vals[0])
+	if err != nil {
+		return nil, &Error{Op: "get node", Err: err}
+	}
+	return n, nil
+}
+
+func parseNode(b []byte) (*Node, error) {
+	n := &Node{}
+	attrs, err := netlink.ParseAttrs(b)
+	if err != nil {
+		return nil, err
+	}
+	var id [nodeIDLen]byte
+	for _, a := range attrs {
+		var v uint32
+		switch a.Type {
+		case netID:
+			n.NetID, err = a.Uint32()
+		case netAddr:
+			v, err = a.Uint32()
+			n.Addr = net.TIPCNodeAddr(v)
+		case netNodeID, netNodeIDW1:
+			// The identity is reported as two 64-bit words
+			// holding its bytes in memory order.
+			if len(a.Value) < 8 {
+				return nil, netlink.ErrMalformed
+			}
+			off := 0
+			if a.Type == netNodeIDW1 {
+				off = 8
+			}
+			copy(id[off:off+8], a.Value)
+			n.ID = id[:]
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// SetNodeAddr sets the network address of the local node.
+func (c *Conn) SetNodeAddr(addr net.TIPCNodeAddr) error {
+	return c.do("set node", cmdNetSet, netlink.NestedAttr(attrNet, netlink.Uint32Attr(netAddr, uint32(addr))))
+}
+
+// SetNodeID sets the identity of the local node, of up to 16 bytes.
+func (c *Conn) SetNodeID(id []byte) error {
+	if len(id) > nodeIDLen {
+		return &Error{Op: "set node", Err: errNodeIDLength}
+	}
+	var b [nodeIDLen]byte
+	copy(b[:], id)
+	return c.do("set node", cmdNetSet, netlink.NestedAttr(attrNet, netlink.Attr{Type: netNodeID, Value: b[:8]}, netlink.Attr{Type: netNodeIDW1, Value: b[8:]}))
+}
+
+// SetNetID sets the identifier of the cluster network that the local
+// node belongs to.
+func (c *Conn) SetNetID(id uint32) error {
+	return c.do("set node", cmdNetSet, netlink.NestedAttr(attrNet, netlink.Uint32Attr(netID, id)))
+}
@@ -0,0 +1,385 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package tipcconfig
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/internal/netlink"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// The netlink streams below were recorded on a little-endian host.
+// Requests are as sent by the package; replies as sent by the kernel,
+// with consecutive datagrams concatenated.
+
+// getFamilyRequest is the CTRL_CMD_GETFAMILY request for "TIPCv2".
+var getFamilyRequest = []byte{
+	0x20, 0x00, 0x00, 0x00, 0x10, 0x00, 0x05, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // nlmsghdr: GENL_ID_CTRL, NLM_F_REQUEST|NLM_F_ACK, seq 1; CTRL_CMD_GETFAMILY
+	0x03, 0x01, 0x00, 0x00, // genlmsghdr
+	0x0b, 0x00, 0x02, 0x00, 0x54, 0x49, 0x50, 0x43, 0x76, 0x32, 0x00, 0x00, // CTRL_ATTR_FAMILY_NAME
+}
+
+// getFamilyReply is the kernel reply resolving "TIPCv2" to family 0x1a,
+// followed by the acknowledgement.
+var getFamilyReply = []byte{
+	0x40, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x2a, 0x3f, 0x00, 0x00, // nlmsghdr: GENL_ID_CTRL, seq 1; CTRL_CMD_NEWFAMILY
+	0x01, 0x02, 0x00, 0x00, // genlmsghdr
+	0x0b, 0x00, 0x02, 0x00, 0x54, 0x49, 0x50, 0x43, 0x76, 0x32, 0x00, 0x00, // CTRL_ATTR_FAMILY_NAME
+	0x06, 0x00, 0x01, 0x00, 0x1a, 0x00, 0x00, 0x00, // CTRL_ATTR_FAMILY_ID
+	0x08, 0x00, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, // CTRL_ATTR_VERSION
+	0x08, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, // CTRL_ATTR_HDRSIZE
+	0x08, 0x00, 0x05, 0x00, 0x0a, 0x00, 0x00, 0x00, // CTRL_ATTR_MAXATTR
+
+	0x24, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x2a, 0x3f, 0x00, 0x00, // nlmsghdr: NLMSG_ERROR, seq 1, acknowledgement
+	0x00, 0x00, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0x10, 0x00, 0x05, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // error and request header
+}
+
+// enableBearerRequest enables "eth:eth0" with discovery domain 1.1.0
+// and priority 20.
+var enableBearerRequest = []byte{
+	0x3c, 0x00, 0x00, 0x00, 0x1a, 0x00, 0x05, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // nlmsghdr: TIPCv2, NLM_F_REQUEST|NLM_F_ACK, seq 2; TIPC_NL_BEARER_ENABLE
+	0x03, 0x01, 0x00, 0x00, // genlmsghdr
+	0x28, 0x00, 0x01, 0x80, // TIPC_NLA_BEARER, nested
+	0x0d, 0x00, 0x01, 0x00, 0x65, 0x74, 0x68, 0x3a, 0x65, 0x74, 0x68, 0x30, 0x00, 0x00, 0x00, 0x00, // TIPC_NLA_BEARER_NAME
+	0x08, 0x00, 0x03, 0x00, 0x00, 0x10, 0x00, 0x01, // TIPC_NLA_BEARER_DOMAIN
+	0x0c, 0x00, 0x02, 0x80, // TIPC_NLA_BEARER_PROP, nested
+	0x08, 0x00, 0x01, 0x00, 0x14, 0x00, 0x00, 0x00, // TIPC_NLA_PROP_PRIO
+}
+
+// enableBearerReply acknowledges enableBearerRequest.
+var enableBearerReply = []byte{
+	0x24, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x2a, 0x3f, 0x00, 0x00, // nlmsghdr: NLMSG_ERROR, seq 2, acknowledgement
+	0x00, 0x00, 0x00, 0x00, 0x3c, 0x00, 0x00, 0x00, 0x1a, 0x00, 0x05, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // error and request header
+}
+
+// setLinkToleranceRequest sets the tolerance of a link to 2s.
+var setLinkToleranceRequest = []byte{
+	0x40, 0x00, 0x00, 0x00, 0x1a, 0x00, 0x05, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // nlmsghdr: TIPCv2, NLM_F_REQUEST|NLM_F_ACK, seq 2; TIPC_NL_LINK_SET
+	0x09, 0x01, 0x00, 0x00, // genlmsghdr
+	0x2c, 0x00, 0x04, 0x80, // TIPC_NLA_LINK, nested
+	0x1a, 0x00, 0x02, 0x00, 0x31, 0x2e, 0x31, 0x2e, 0x31, 0x3a, 0x65, 0x74, 0x68, 0x30, 0x2d, 0x31, 0x2e, 0x31, 0x2e, 0x32, 0x3a, 0x65, 0x74, 0x68, 0x30, 0x00, 0x00, 0x00, // TIPC_NLA_LINK_NAME
+	0x0c, 0x00, 0x08, 0x80, // TIPC_NLA_LINK_PROP, nested
+	0x08, 0x00, 0x02, 0x00, 0xd0, 0x07, 0x00, 0x00, // TIPC_NLA_PROP_TOL
+}
+
+// setLinkToleranceEPERM rejects setLinkToleranceRequest with EPERM.
+var setLinkToleranceEPERM = []byte{
+	0x24, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x2a, 0x3f, 0x00, 0x00, // nlmsghdr: NLMSG_ERROR, seq 2, -EPERM
+	0xff, 0xff, 0xff, 0xff, 0x40, 0x00, 0x00, 0x00, 0x1a, 0x00, 0x05, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // error and request header
+}
+
+// bearerDump lists two bearers, the second one with attributes
+// flagged as nested.
+var bearerDump = []byte{
+	0x44, 0x00, 0x00, 0x00, 0x1a, 0x00, 0x02, 0x00, 0x02, 0x00, 0x00, 0x00, 0x2a, 0x3f, 0x00, 0x00, // nlmsghdr: TIPCv2, NLM_F_MULTI, seq 2; TIPC_NL_BEARER_GET
+	0x04, 0x01, 0x00, 0x00, // genlmsghdr
+	0x30, 0x00, 0x01, 0x00, // TIPC_NLA_BEARER
+	0x0d, 0x00, 0x01, 0x00, 0x65, 0x74, 0x68, 0x3a, 0x65, 0x74, 0x68, 0x30, 0x00, 0x00, 0x00, 0x00, // TIPC_NLA_BEARER_NAME
+	0x1c, 0x00, 0x02, 0x00, // TIPC_NLA_BEARER_PROP
+	0x08, 0x00, 0x01, 0x00, 0x0a, 0x00, 0x00, 0x00, // TIPC_NLA_PROP_PRIO
+	0x08, 0x00, 0x02, 0x00, 0xdc, 0x05, 0x00, 0x00, // TIPC_NLA_PROP_TOL
+	0x08, 0x00, 0x03, 0x00, 0x32, 0x00, 0x00, 0x00, // TIPC_NLA_PROP_WIN
+
+	0x40, 0x00, 0x00, 0x00, 0x1a, 0x00, 0x02, 0x00, 0x02, 0x00, 0x00, 0x00, 0x2a, 0x3f, 0x00, 0x00, // nlmsghdr: TIPCv2, NLM_F_MULTI, seq 2; TIPC_NL_BEARER_GET
+	0x04, 0x01, 0x00, 0x00, // genlmsghdr
+	0x2c, 0x00, 0x01, 0x80, // TIPC_NLA_BEARER, nested
+	0x0b, 0x00, 0x01, 0x00, 0x75, 0x64, 0x70, 0x3a, 0x62, 0x31, 0x00, 0x00, // TIPC_NLA_BEARER_NAME
+	0x1c, 0x00, 0x02, 0x80, // TIPC_NLA_BEARER_PROP, nested
+	0x08, 0x00, 0x01, 0x00, 0x0a, 0x00, 0x00, 0x00, // TIPC_NLA_PROP_PRIO
+	0x08, 0x00, 0x02, 0x00, 0xdc, 0x05, 0x00, 0x00, // TIPC_NLA_PROP_TOL
+	0x08, 0x00, 0x03, 0x00, 0x32, 0x00, 0x00, 0x00, // TIPC_NLA_PROP_WIN
+
+	0x14, 0x00, 0x00, 0x00, 0x03, 0x00, 0x02, 0x00, 0x02, 0x00, 0x00, 0x00, 0x2a, 0x3f, 0x00, 0x00, // nlmsghdr: NLMSG_DONE, NLM_F_MULTI, seq 2
+	0x00, 0x00, 0x00, 0x00, // error and request header
+}
+
+// linkDump lists the broadcast link and a link to node 1.1.2.
+var linkDump = []byte{
+	0x60, 0x00, 0x00, 0x00, 0x1a, 0x00, 0x02, 0x00, 0x02, 0x00, 0x00, 0x00, 0x2a, 0x3f, 0x00, 0x00, // nlmsghdr: TIPCv2, NLM_F_MULTI, seq 2; TIPC_NL_LINK_GET
+	0x08, 0x01, 0x00, 0x00, // genlmsghdr
+	0x4c, 0x00, 0x04, 0x00, // TIPC_NLA_LINK
+	0x13, 0x00, 0x02, 0x00, 0x62, 0x72, 0x6f, 0x61, 0x64, 0x63, 0x61, 0x73, 0x74, 0x2d, 0x6c, 0x69, 0x6e, 0x6b, 0x00, 0x00, // TIPC_NLA_LINK_NAME
+	0x04, 0x00, 0x05, 0x00, // TIPC_NLA_LINK_BROADCAST
+	0x08, 0x00, 0x0a, 0x00, 0x11, 0x00, 0x00, 0x00, // TIPC_NLA_LINK_RX
+	0x08, 0x00, 0x0b, 0x00, 0x2a, 0x00, 0x00, 0x00, // TIPC_NLA_LINK_TX
+	0x0c, 0x00, 0x08, 0x00, // TIPC_NLA_LINK_PROP
+	0x08, 0x00, 0x03, 0x00, 0x32, 0x00, 0x00, 0x00, // TIPC_NLA_PROP_WIN
+	0x14, 0x00, 0x09, 0x00, // TIPC_NLA_LINK_STATS
+	0x08, 0x00, 0x01, 0x00, 0x0c, 0x00, 0x00, 0x00, // TIPC_NLA_STATS_RX_INFO
+	0x08, 0x00, 0x06, 0x00, 0x22, 0x00, 0x00, 0x00, // TIPC_NLA_STATS_TX_INFO
+
+	0xac, 0x00, 0x00, 0x00, 0x1a, 0x00, 0x02, 0x00, 0x02, 0x00, 0x00, 0x00, 0x2a, 0x3f, 0x00, 0x00, // nlmsghdr: TIPCv2, NLM_F_MULTI, seq 2; TIPC_NL_LINK_GET
+	0x08, 0x01, 0x00, 0x00, // genlmsghdr
+	0x98, 0x00, 0x04, 0x00, // TIPC_NLA_LINK
+	0x1a, 0x00, 0x02, 0x00, 0x31, 0x2e, 0x31, 0x2e, 0x31, 0x3a, 0x65, 0x74, 0x68, 0x30, 0x2d, 0x31, 0x2e, 0x31, 0x2e, 0x32, 0x3a, 0x65, 0x74, 0x68, 0x30, 0x00, 0x00, 0x00, // TIPC_NLA_LINK_NAME
+	0x08, 0x00, 0x03, 0x00, 0x02, 0x10, 0x00, 0x01, // TIPC_NLA_LINK_DEST
+	0x08, 0x00, 0x04, 0x00, 0xdc, 0x05, 0x00, 0x00, // TIPC_NLA_LINK_MTU
+	0x04, 0x00, 0x06, 0x00, // TIPC_NLA_LINK_UP
+	0x04, 0x00, 0x07, 0x00, // TIPC_NLA_LINK_ACTIVE
+	0x08, 0x00, 0x0a, 0x00, 0x00, 0x04, 0x00, 0x00, // TIPC_NLA_LINK_RX
+	0x08, 0x00, 0x0b, 0x00, 0x00, 0x08, 0x00, 0x00, // TIPC_NLA_LINK_TX
+	0x1c, 0x00, 0x08, 0x00, // TIPC_NLA_LINK_PROP
+	0x08, 0x00, 0x01, 0x00, 0x0a, 0x00, 0x00, 0x00, // TIPC_NLA_PROP_PRIO
+	0x08, 0x00, 0x02, 0x00, 0xdc, 0x05, 0x00, 0x00, // TIPC_NLA_PROP_TOL
+	0x08, 0x00, 0x03, 0x00, 0x32, 0x00, 0x00, 0x00, // TIPC_NLA_PROP_WIN
+	0x34, 0x00, 0x09, 0x00, // TIPC_NLA_LINK_STATS
+	0x08, 0x00, 0x01, 0x00, 0xe8, 0x03, 0x00, 0x00, // TIPC_NLA_STATS_RX_INFO
+	0x08, 0x00, 0x06, 0x00, 0xd0, 0x07, 0x00, 0x00, // TIPC_NLA_STATS_TX_INFO
+	0x08, 0x00, 0x17, 0x00, 0x05, 0x00, 0x00, 0x00, // TIPC_NLA_STATS_RX_NACKS
+	0x08, 0x00, 0x1d, 0x00, 0x03, 0x00, 0x00, 0x00, // TIPC_NLA_STATS_RETRANSMITTED
+	0x08, 0x00, 0x1f, 0x00, 0x01, 0x00, 0x00, 0x00, // TIPC_NLA_STATS_LINK_CONGS
+	0x08, 0x00, 0x20, 0x00, 0x07, 0x00, 0x00, 0x00, // TIPC_NLA_STATS_MAX_QUEUE
+
+	0x14, 0x00, 0x00, 0x00, 0x03, 0x00, 0x02, 0x00, 0x02, 0x00, 0x00, 0x00, 0x2a, 0x3f, 0x00, 0x00, // nlmsghdr: NLMSG_DONE, NLM_F_MULTI, seq 2
+	0x00, 0x00, 0x00, 0x00, // error and request header
+}
+
+// netDump reports network 4711, address 1.1.1 and identity
+// a0a1...af.
+var netDump = []byte{
+	0x40, 0x00, 0x00, 0x00, 0x1a, 0x00, 0x02, 0x00, 0x02, 0x00, 0x00, 0x00, 0x2a, 0x3f, 0x00, 0x00, // nlmsghdr: TIPCv2, NLM_F_MULTI, seq 2; TIPC_NL_NET_GET
+	0x0e, 0x01, 0x00, 0x00, // genlmsghdr
+	0x2c, 0x00, 0x07, 0x00, // TIPC_NLA_NET
+	0x08, 0x00, 0x01, 0x00, 0x67, 0x12, 0x00, 0x00, // TIPC_NLA_NET_ID
+	0x08, 0x00, 0x02, 0x00, 0x01, 0x10, 0x00, 0x01, // TIPC_NLA_NET_ADDR
+	0x0c, 0x00, 0x03, 0x00, 0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, // TIPC_NLA_NET_NODEID
+	0x0c, 0x00, 0x04, 0x00, 0xa8, 0xa9, 0xaa, 0xab, 0xac, 0xad, 0xae, 0xaf, // TIPC_NLA_NET_NODEID_W1
+
+	0x14, 0x00, 0x00, 0x00, 0x03, 0x00, 0x02, 0x00, 0x02, 0x00, 0x00, 0x00, 0x2a, 0x3f, 0x00, 0x00, // nlmsghdr: NLMSG_DONE, NLM_F_MULTI, seq 2
+	0x00, 0x00, 0x00, 0x00, // error and request header
+}
+
+// setNodeIDRequest sets the node identity to "node-7".
+var setNodeIDRequest = []byte{
+	0x30, 0x00, 0x00, 0x00, 0x1a, 0x00, 0x05, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // nlmsghdr: TIPCv2, NLM_F_REQUEST|NLM_F_ACK, seq 2; TIPC_NL_NET_SET
+	0x0f, 0x01, 0x00, 0x00, // genlmsghdr
+	0x1c, 0x00, 0x07, 0x80, // TIPC_NLA_NET, nested
+	0x0c, 0x00, 0x03, 0x00, 0x6e, 0x6f, 0x64, 0x65, 0x2d, 0x37, 0x00, 0x00, // TIPC_NLA_NET_NODEID
+	0x0c, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // TIPC_NLA_NET_NODEID_W1
+}
+
+// setNodeIDReply acknowledges setNodeIDRequest.
+var setNodeIDReply = []byte{
+	0x24, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x2a, 0x3f, 0x00, 0x00, // nlmsghdr: NLMSG_ERROR, seq 2, acknowledgement
+	0x00, 0x00, 0x00, 0x00, 0x30, 0x00, 0x00, 0x00, 0x1a, 0x00, 0x05, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // error and request header
+}
+
+// An exchange is a recorded request and the replies to it.  A nil
+// request matches any request.
+type exchange struct {
+	req, resp []byte
+}
+
+// recordedTransport replays recorded exchanges in order.
+type recordedTransport struct {
+	t         *testing.T
+	exchanges []exchange
+	last      []byte // last request sent
+	closed    bool
+}
+
+func (rt *recordedTransport) roundTrip(req []byte) ([]byte, error) {
+	if len(rt.exchanges) == 0 {
+		rt.t.Fatalf("unexpected request %x", req)
+	}
+	e := rt.exchanges[0]
+	rt.exchanges = rt.exchanges[1:]
+	if e.req != nil && !bytes.Equal(req, e.req) {
+		rt.t.Errorf("got request\n%x\nwant\n%x", req, e.req)
+	}
+	rt.last = req
+	return e.resp, nil
+}
+
+func (rt *recordedTransport) close() error {
+	rt.closed = true
+	return nil
+}
+
+// newRecordedConn returns a connection that resolves the TIPCv2
+// family and then replays exchanges.
+func newRecordedConn(t *testing.T, exchanges ...exchange) (*Conn, *recordedTransport) {
+	if nativeEndian != binary.LittleEndian {
+		t.Skip("recorded netlink streams are little-endian")
+	}
+	rt := &recordedTransport{t: t, exchanges: append([]exchange{{getFamilyRequest, getFamilyReply}}, exchanges...)}
+	c, err := newConn(rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, rt
+}
+
+func TestResolveFamily(t *testing.T) {
+	c, rt := newRecordedConn(t)
+	if c.family != 0x1a {
+		t.Errorf("got family %#x; want 0x1a", c.family)
+	}
+	if err := c.Close(); err != nil || !rt.closed {
+		t.Errorf("Close: %v, transport closed: %v", err, rt.closed)
+	}
+	if _, err := c.Bearers(); err == nil {
+		t.Error("Bearers succeeded on closed connection")
+	}
+}
+
+func TestEnableBearer(t *testing.T) {
+	c, _ := newRecordedConn(t, exchange{enableBearerRequest, enableBearerReply})
+	if err := c.EnableBearer("eth:eth0", &BearerConfig{Domain: 0x01001000, Priority: 20}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.EnableBearer("eth0", nil); err == nil {
+		t.Error("EnableBearer accepted a bearer name without media")
+	}
+	if err := c.EnableBearer("udp:b1", nil); err == nil {
+		t.Error("EnableBearer accepted a UDP bearer without addresses")
+	}
+}
+
+func TestEnableUDPBearer(t *testing.T) {
+	c, rt := newRecordedConn(t, exchange{nil, enableBearerReply})
+	cfg := &BearerConfig{
+		Local:  &net.UDPAddr{IP: net.IPv4(192, 168, 1, 1), Port: 6118},
+		Remote: &net.UDPAddr{IP: net.ParseIP("ff02::1"), Port: 6118},
+	}
+	if err := c.EnableBearer("udp:b1", cfg); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(rt.last)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("got %d messages, %v; want 1", len(msgs), err)
+	}
+	attrs, err := netlink.ParseAttrs(msgs[0].Data[netlink.GenlHdrLen:])
+	if err != nil || len(attrs) != 1 || attrs[0].Type != attrBearer {
+		t.Fatalf("got %v, %v; want a TIPC_NLA_BEARER attribute", attrs, err)
+	}
+	battrs, err := netlink.ParseAttrs(attrs[0].Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opts []netlink.Attr
+	for _, a := range battrs {
+		if a.Type == bearerUDPOpts {
+			if opts, err = netlink.ParseAttrs(a.Value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if len(opts) != 2 {
+		t.Fatalf("got %d UDP options; want 2", len(opts))
+	}
+	local := []byte{afInet, 0, 0x17, 0xe6, 192, 168, 1, 1}
+	if opts[0].Type != udpLocal || len(opts[0].Value) != sizeofSockaddrStorage || !bytes.Equal(opts[0].Value[:8], local) {
+		t.Errorf("got local address %d %x; want %d %x", opts[0].Type, opts[0].Value, udpLocal, local)
+	}
+	remote := append([]byte{afInet6, 0, 0x17, 0xe6, 0, 0, 0, 0}, net.ParseIP("ff02::1")...)
+	if opts[1].Type != udpRemote || len(opts[1].Value) != sizeofSockaddrStorage || !bytes.Equal(opts[1].Value[:24], remote) {
+		t.Errorf("got remote address %d %x; want %d %x", opts[1].Type, opts[1].Value, udpRemote, remote)
+	}
+}
+
+func TestBearers(t *testing.T) {
+	c, _ := newRecordedConn(t, exchange{nil, bearerDump})
+	bearers, err := c.Bearers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := Props{Priority: 10, Tolerance: 1500 * time.Millisecond, Window: 50}
+	want := []Bearer{{"eth:eth0", props}, {"udp:b1", props}}
+	if !reflect.DeepEqual(bearers, want) {
+		t.Errorf("got %+v; want %+v", bearers, want)
+	}
+}
+
+func TestLinks(t *testing.T) {
+	c, _ := newRecordedConn(t, exchange{nil, linkDump})
+	links, err := c.Links()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Link{
+		{
+			Name:      "broadcast-link",
+			Broadcast: true,
+			Props:     Props{Window: 50},
+			RxPackets: 17,
+			TxPackets: 42,
+			Stats:     LinkStats{RxInfo: 12, TxInfo: 34},
+		},
+		{
+			Name:      "1.1.1:eth0-1.1.2:eth0",
+			Peer:      0x01001002,
+			MTU:       1500,
+			Up:        true,
+			Active:    true,
+			Props:     Props{Priority: 10, Tolerance: 1500 * time.Millisecond, Window: 50},
+			RxPackets: 1024,
+			TxPackets: 2048,
+			Stats: LinkStats{
+				RxInfo:        1000,
+				TxInfo:        2000,
+				RxNacks:       5,
+				Retransmitted: 3,
+				Congestions:   1,
+				MaxQueue:      7,
+			},
+		},
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("got %+v; want %+v", links, want)
+	}
+}
+
+func TestSetLinkTolerance(t *testing.T) {
+	c, _ := newRecordedConn(t, exchange{setLinkToleranceRequest, setLinkToleranceEPERM})
+	err := c.SetLinkTolerance("1.1.1:eth0-1.1.2:eth0", 2*time.Second)
+	e, ok := err.(*Error)
+	if !ok || e.Op != "set link" || e.Err != syscall.EPERM {
+		t.Errorf("got %v; want the EPERM reported by the kernel", err)
+	}
+}
+
+func TestNode(t *testing.T) {
+	c, _ := newRecordedConn(t, exchange{nil, netDump})
+	n, err := c.Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := []byte{0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xab, 0xac, 0xad, 0xae, 0xaf}
+	if n.NetID != 4711 || n.Addr.String() != "1.1.1" || !bytes.Equal(n.ID, id) {
+		t.Errorf("got %+v; want network 4711, address 1.1.1 and identity %x", n, id)
+	}
+}
+
+func TestSetNodeID(t *testing.T) {
+	c, _ := newRecordedConn(t, exchange{setNodeIDRequest, setNodeIDReply})
+	if err := c.SetNodeID([]byte("node-7")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetNodeID(make([]byte, 17)); err == nil {
+		t.Error("SetNodeID accepted a 17-byte identity")
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	for _, b := range [][]byte{
+		linkDump[:10],
+		linkDump[:len(linkDump)-30],
+	} {
+		if msgs, _, err := netlink.ParseReplies(b, 2); err == nil {
+			t.Errorf("parsed truncated stream %x into %v", b, msgs)
+		}
+	}
+	if _, _, err := netlink.ParseReplies(bearerDump, 3); err == nil {
+		t.Error("accepted replies to another request")
+	}
+}
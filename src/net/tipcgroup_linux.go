@@ -0,0 +1,217 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errNotTIPCGroupMember = errors.New("not a member of a TIPC communication group")
+
+// ListenTIPCGroup creates a TIPC socket that can join a communication
+// group with Join.  Net must be "tipc-rdm".
+func ListenTIPCGroup(net string) (*TIPCGroupConn, error) {
+	switch net {
+	case "tipc-rdm":
+	default:
+		return nil, &OpError{Op: "listen", Net: net, Addr: nil, Err: UnknownNetworkError(net)}
+	}
+	fd, err := tipcSocket(net, nil, nil, "listen", noDeadline, nil)
+	if err != nil {
+		return nil, &OpError{Op: "listen", Net: net, Addr: nil, Err: err}
+	}
+	return &TIPCGroupConn{conn: conn{fd}}, nil
+}
+
+// Join makes c a member of the communication group addr.Service
+// under the member name addr, which must be a name.  The scope of
+// addr, TIPC_NODE_SCOPE or TIPC_CLUSTER_SCOPE, limits the group to
+// the local node or cluster; a zero scope means the cluster.  Flags
+// is a combination of TIPC_GROUP_LOOPBACK, to receive the messages
+// that c broadcasts or multicasts itself, and TIPC_GROUP_MEMBER_EVTS,
+// to be told by ReadFromGroup when other members join or leave.
+func (c *TIPCGroupConn) Join(addr *TIPCAddr, flags int) error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	if addr == nil {
+		return &OpError{Op: "join", Net: c.fd.net, Addr: nil, Err: errMissingAddress}
+	}
+	if addr.AddrType != TIPC_ADDR_NAME {
+		return &OpError{Op: "join", Net: c.fd.net, Addr: addr, Err: InvalidAddrError("TIPC group member address must be a name")}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := setTIPCGroupReq(c.fd, addr, flags); err != nil {
+		return &OpError{Op: "join", Net: c.fd.net, Addr: addr, Err: err}
+	}
+	a := *addr
+	c.member = &a
+	return nil
+}
+
+// Leave withdraws c from its communication group.
+func (c *TIPCGroupConn) Leave() error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := setTIPCGroupReq(c.fd, nil, 0); err != nil {
+		return &OpError{Op: "leave", Net: c.fd.net, Addr: c.member.toAddr(), Err: err}
+	}
+	c.member = nil
+	return nil
+}
+
+// tipcGroupReq returns the socket option and its value that make a
+// socket join its group as member, or leave it if member is nil.
+func tipcGroupReq(member *TIPCAddr, flags int) (opt int, req *syscall.TIPCGroupReq) {
+	if member == nil {
+		return syscall.TIPC_GROUP_LEAVE, nil
+	}
+	req = &syscall.TIPCGroupReq{
+		Type:     member.Service,
+		Instance: member.Instance,
+		Scope:    TIPC_CLUSTER_SCOPE,
+		Flags:    uint32(flags),
+	}
+	if member.Scope != 0 {
+		req.Scope = uint32(member.Scope)
+	}
+	return syscall.TIPC_GROUP_JOIN, req
+}
+
+func setTIPCGroupReq(fd *netFD, member *TIPCAddr, flags int) error {
+	if err := fd.incref(); err != nil {
+		return err
+	}
+	defer fd.decref()
+	opt, req := tipcGroupReq(member, flags)
+	return os.NewSyscallError("setsockopt", syscall.SetsockoptTIPCGroupReq(fd.sysfd, syscall.SOL_TIPC, opt, req))
+}
+
+// group returns the service type of the group that c is a member of.
+func (c *TIPCGroupConn) group() (uint32, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.member == nil {
+		return 0, errNotTIPCGroupMember
+	}
+	return c.member.Service, nil
+}
+
+// anycastAddr returns the name of the members of c's group joined
+// with instance.
+func (c *TIPCGroupConn) anycastAddr(instance uint32) (*TIPCAddr, error) {
+	group, err := c.group()
+	if err != nil {
+		return nil, err
+	}
+	return &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: group, Instance: instance}, nil
+}
+
+// multicastAddr returns the name sequence of the members of c's
+// group joined with an instance between lower and upper.
+func (c *TIPCGroupConn) multicastAddr(lower, upper uint32) (*TIPCAddr, error) {
+	group, err := c.group()
+	if err != nil {
+		return nil, err
+	}
+	return &TIPCAddr{AddrType: TIPC_ADDR_NAMESEQ, Service: group, Instance: lower, Domain: upper}, nil
+}
+
+func (c *TIPCGroupConn) writeTo(b []byte, addr *TIPCAddr) (int, error) {
+	sa, err := addr.sockaddr(c.fd.family)
+	if err != nil {
+		return 0, &OpError{Op: "write", Net: c.fd.net, Addr: addr, Err: err}
+	}
+	return c.fd.writeTo(b, sa)
+}
+
+// Unicast sends b to the group member with the port identity addr.
+func (c *TIPCGroupConn) Unicast(b []byte, addr *TIPCAddr) (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	if addr == nil || addr.AddrType != TIPC_ADDR_ID {
+		return 0, &OpError{Op: "write", Net: c.fd.net, Addr: addr.toAddr(), Err: InvalidAddrError("TIPC unicast address must be a port identity")}
+	}
+	return c.writeTo(b, addr)
+}
+
+// Anycast sends b to one of the group members joined with the given
+// instance.
+func (c *TIPCGroupConn) Anycast(b []byte, instance uint32) (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	addr, err := c.anycastAddr(instance)
+	if err != nil {
+		return 0, &OpError{Op: "write", Net: c.fd.net, Addr: nil, Err: err}
+	}
+	return c.writeTo(b, addr)
+}
+
+// Multicast sends b to every group member joined with an instance
+// between lower and upper inclusive.
+func (c *TIPCGroupConn) Multicast(b []byte, lower, upper uint32) (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	addr, err := c.multicastAddr(lower, upper)
+	if err != nil {
+		return 0, &OpError{Op: "write", Net: c.fd.net, Addr: nil, Err: err}
+	}
+	return c.writeTo(b, addr)
+}
+
+// Broadcast sends b to every member of the group.
+func (c *TIPCGroupConn) Broadcast(b []byte) (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	if _, err := c.group(); err != nil {
+		return 0, &OpError{Op: "write", Net: c.fd.net, Addr: nil, Err: err}
+	}
+	return c.fd.Write(b)
+}
+
+// ReadFromGroup reads a message or a membership event from c,
+// copying the payload into b.  It returns the number of bytes copied
+// into b, the port identity and the member name of the sending
+// member, and the kind of event: zero for messages, TIPC_PUBLISHED
+// when the member joined the group and TIPC_WITHDRAWN when it left.
+// Membership events carry no payload and are only delivered to
+// members that joined with TIPC_GROUP_MEMBER_EVTS.
+func (c *TIPCGroupConn) ReadFromGroup(b []byte) (n int, from, member *TIPCAddr, event uint32, err error) {
+	if !c.ok() {
+		return 0, nil, nil, 0, syscall.EINVAL
+	}
+	n, _, flags, sa, err := c.fd.readMsg(b, nil)
+	if sa, ok := sa.(*syscall.SockaddrTIPC); ok {
+		from, _ = sockaddrToTIPC(sa).(*TIPCAddr)
+		if sa.Member != nil {
+			member, _ = sockaddrToTIPC(sa.Member).(*TIPCAddr)
+		}
+	}
+	event = tipcGroupEvent(flags)
+	return
+}
+
+// tipcGroupEvent returns the membership event that the kernel reports
+// with the flags of a received message: MSG_OOB marks a membership
+// event and MSG_EOR, in addition, a member that left.
+func tipcGroupEvent(flags int) uint32 {
+	if flags&syscall.MSG_OOB == 0 {
+		return 0
+	}
+	if flags&syscall.MSG_EOR != 0 {
+		return TIPC_WITHDRAWN
+	}
+	return TIPC_PUBLISHED
+}
@@ -0,0 +1,179 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// TIPC name table access through generic netlink
+
+package net
+
+import (
+	"net/internal/netlink"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// TIPC generic netlink definitions from linux/tipc_netlink.h.
+const (
+	tipcGenlName    = "TIPCv2"
+	tipcGenlVersion = 1
+
+	tipcNLNameTableGet = 16
+
+	tipcNLANameTable     = 8
+	tipcNLANameTablePubl = 1
+
+	tipcNLAPublType  = 1
+	tipcNLAPublLower = 2
+	tipcNLAPublUpper = 3
+	tipcNLAPublScope = 4
+	tipcNLAPublNode  = 5
+	tipcNLAPublRef   = 6
+)
+
+// genlExchange sends the generic netlink request req to the kernel
+// and returns the replies up to the end of a dump or the
+// acknowledgement of a plain request.
+func genlExchange(req []byte) ([]netlink.Message, error) {
+	s, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW, syscall.NETLINK_GENERIC)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	defer syscall.Close(s)
+	lsa := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}
+	if err := syscall.Bind(s, lsa); err != nil {
+		return nil, os.NewSyscallError("bind", err)
+	}
+	sa, err := syscall.Getsockname(s)
+	if err != nil {
+		return nil, os.NewSyscallError("getsockname", err)
+	}
+	pid := sa.(*syscall.SockaddrNetlink).Pid
+	seq := (*syscall.NlMsghdr)(unsafe.Pointer(&req[0])).Seq
+	if err := syscall.Sendto(s, req, 0, lsa); err != nil {
+		return nil, os.NewSyscallError("sendto", err)
+	}
+	var replies []netlink.Message
+	for {
+		rb := make([]byte, syscall.Getpagesize())
+		nr, _, err := syscall.Recvfrom(s, rb, 0)
+		if err != nil {
+			return nil, os.NewSyscallError("recvfrom", err)
+		}
+		msgs, done, err := netlink.ParseReplies(rb[:nr], seq)
+		if err != nil {
+			return nil, os.NewSyscallError("netlink", err)
+		}
+		for _, m := range msgs {
+			if m.Pid != pid {
+				return nil, os.NewSyscallError("netlink", netlink.ErrMalformed)
+			}
+		}
+		replies = append(replies, msgs...)
+		if done {
+			return replies, nil
+		}
+	}
+}
+
+// genlFamily returns the identifier of the generic netlink family
+// name.
+func genlFamily(name string) (uint16, error) {
+	req := netlink.NewRequest(netlink.GenlIDCtrl, syscall.NLM_F_ACK, 1, netlink.CtrlCmdGetFamily, 1, netlink.StringAttr(netlink.CtrlAttrFamilyName, name))
+	msgs, err := genlExchange(req)
+	if err != nil {
+		return 0, err
+	}
+	return netlink.FamilyID(msgs)
+}
+
+// tipcNameTable returns the publications in the TIPC name table.
+func tipcNameTable() ([]TIPCPublication, error) {
+	family, err := genlFamily(tipcGenlName)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := genlExchange(netlink.NewRequest(family, syscall.NLM_F_DUMP, 1, tipcNLNameTableGet, tipcGenlVersion))
+	if err != nil {
+		return nil, err
+	}
+	return parseTIPCNameTable(msgs)
+}
+
+// parseTIPCNameTable decodes the replies to a TIPC_NL_NAME_TABLE_GET
+// dump.
+func parseTIPCNameTable(msgs []netlink.Message) ([]TIPCPublication, error) {
+	var pubs []TIPCPublication
+	for _, m := range msgs {
+		for _, a := range m.Attrs {
+			if a.Type != tipcNLANameTable {
+				continue
+			}
+			tattrs, err := netlink.ParseAttrs(a.Value)
+			if err != nil {
+				return nil, err
+			}
+			for _, ta := range tattrs {
+				if ta.Type != tipcNLANameTablePubl {
+					continue
+				}
+				p, err := parseTIPCPublication(ta.Value)
+				if err != nil {
+					return nil, err
+				}
+				pubs = append(pubs, p)
+			}
+		}
+	}
+	return pubs, nil
+}
+
+func parseTIPCPublication(b []byte) (TIPCPublication, error) {
+	var p TIPCPublication
+	attrs, err := netlink.ParseAttrs(b)
+	if err != nil {
+		return p, err
+	}
+	var scope uint32
+	for _, a := range attrs {
+		var v *uint32
+		switch a.Type {
+		case tipcNLAPublType:
+			v = &p.Service
+		case tipcNLAPublLower:
+			v = &p.Lower
+		case tipcNLAPublUpper:
+			v = &p.Upper
+		case tipcNLAPublScope:
+			v = &scope
+		case tipcNLAPublNode:
+			v = &p.Node
+		case tipcNLAPublRef:
+			v = &p.Ref
+		default:
+			continue
+		}
+		if *v, err = a.Uint32(); err != nil {
+			return p, err
+		}
+	}
+	p.Scope = int8(scope)
+	return p, nil
+}
+
+// LookupTIPC returns the publications of the names in the name
+// sequence from lower to upper of service, as recorded in the TIPC
+// name table of the cluster.
+func LookupTIPC(service, lower, upper uint32) ([]TIPCPublication, error) {
+	pubs, err := tipcNameTable()
+	if err != nil {
+		return nil, &OpError{Op: "lookup", Net: "tipc", Addr: &TIPCAddr{AddrType: TIPC_ADDR_NAMESEQ, Service: service, Instance: lower, Domain: upper}, Err: err}
+	}
+	var matches []TIPCPublication
+	for _, p := range pubs {
+		if p.Service == service && p.Lower <= upper && lower <= p.Upper {
+			matches = append(matches, p)
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"bytes"
+	"net/internal/netlink"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// Canned kernel responses below are in little-endian byte order.
+
+var genlGetFamilyRequest = []byte{
+	0x20, 0x00, 0x00, 0x00, 0x10, 0x00, 0x05, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // nlmsghdr: len 32, type GENL_ID_CTRL, NLM_F_REQUEST|NLM_F_ACK, seq 1
+	0x03, 0x01, 0x00, 0x00, // genlmsghdr: CTRL_CMD_GETFAMILY, version 1
+	0x0b, 0x00, 0x02, 0x00, 0x54, 0x49, 0x50, 0x43, 0x76, 0x32, 0x00, 0x00, // CTRL_ATTR_FAMILY_NAME
+}
+
+var genlNewFamilyReply = []byte{
+	0x30, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x34, 0x12, 0x00, 0x00, // nlmsghdr: len 48, type GENL_ID_CTRL, seq 1, pid 0x1234
+	0x01, 0x02, 0x00, 0x00, // genlmsghdr: CTRL_CMD_NEWFAMILY, version 2
+	0x0b, 0x00, 0x02, 0x00, 0x54, 0x49, 0x50, 0x43, 0x76, 0x32, 0x00, 0x00, // CTRL_ATTR_FAMILY_NAME
+	0x06, 0x00, 0x01, 0x00, 0x1a, 0x00, 0x00, 0x00, // CTRL_ATTR_FAMILY_ID
+	0x08, 0x00, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, // CTRL_ATTR_VERSION
+}
+
+var tipcNameTableDump = []byte{
+	0x54, 0x00, 0x00, 0x00, 0x1a, 0x00, 0x02, 0x00, 0x01, 0x00, 0x00, 0x00, 0x34, 0x12, 0x00, 0x00, // nlmsghdr: len 84, type 0x1a, NLM_F_MULTI, seq 1, pid 0x1234
+	0x10, 0x01, 0x00, 0x00, // genlmsghdr: TIPC_NL_NAME_TABLE_GET, version 1
+	0x40, 0x00, 0x08, 0x80, // TIPC_NLA_NAME_TABLE, nested
+	0x3c, 0x00, 0x01, 0x80, // TIPC_NLA_NAME_TABLE_PUBL, nested
+	0x08, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, // TIPC_NLA_PUBL_TYPE
+	0x08, 0x00, 0x02, 0x00, 0x01, 0x00, 0x00, 0x00, // TIPC_NLA_PUBL_LOWER
+	0x08, 0x00, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, // TIPC_NLA_PUBL_UPPER
+	0x08, 0x00, 0x04, 0x00, 0x02, 0x00, 0x00, 0x00, // TIPC_NLA_PUBL_SCOPE
+	0x08, 0x00, 0x05, 0x00, 0x01, 0x10, 0x00, 0x01, // TIPC_NLA_PUBL_NODE
+	0x08, 0x00, 0x06, 0x00, 0x4f, 0x2a, 0x1e, 0x8d, // TIPC_NLA_PUBL_REF
+	0x08, 0x00, 0x07, 0x00, 0x50, 0x2a, 0x1e, 0x8d, // TIPC_NLA_PUBL_KEY
+
+	0x54, 0x00, 0x00, 0x00, 0x1a, 0x00, 0x02, 0x00, 0x01, 0x00, 0x00, 0x00, 0x34, 0x12, 0x00, 0x00, // nlmsghdr: len 84, type 0x1a, NLM_F_MULTI, seq 1, pid 0x1234
+	0x10, 0x01, 0x00, 0x00, // genlmsghdr: TIPC_NL_NAME_TABLE_GET, version 1
+	0x40, 0x00, 0x08, 0x80, // TIPC_NLA_NAME_TABLE, nested
+	0x3c, 0x00, 0x01, 0x80, // TIPC_NLA_NAME_TABLE_PUBL, nested
+	0x08, 0x00, 0x01, 0x00, 0xda, 0x49, 0x00, 0x00, // TIPC_NLA_PUBL_TYPE
+	0x08, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, // TIPC_NLA_PUBL_LOWER
+	0x08, 0x00, 0x03, 0x00, 0x63, 0x00, 0x00, 0x00, // TIPC_NLA_PUBL_UPPER
+	0x08, 0x00, 0x04, 0x00, 0x02, 0x00, 0x00, 0x00, // TIPC_NLA_PUBL_SCOPE
+	0x08, 0x00, 0x05, 0x00, 0x01, 0x10, 0x00, 0x01, // TIPC_NLA_PUBL_NODE
+	0x08, 0x00, 0x06, 0x00, 0x01, 0x9f, 0x3d, 0x6a, // TIPC_NLA_PUBL_REF
+	0x08, 0x00, 0x07, 0x00, 0x02, 0x9f, 0x3d, 0x6a, // TIPC_NLA_PUBL_KEY
+
+	0x54, 0x00, 0x00, 0x00, 0x1a, 0x00, 0x02, 0x00, 0x01, 0x00, 0x00, 0x00, 0x34, 0x12, 0x00, 0x00, // nlmsghdr: len 84, type 0x1a, NLM_F_MULTI, seq 1, pid 0x1234
+	0x10, 0x01, 0x00, 0x00, // genlmsghdr: TIPC_NL_NAME_TABLE_GET, version 1
+	0x40, 0x00, 0x08, 0x80, // TIPC_NLA_NAME_TABLE, nested
+	0x3c, 0x00, 0x01, 0x80, // TIPC_NLA_NAME_TABLE_PUBL, nested
+	0x08, 0x00, 0x01, 0x00, 0xda, 0x49, 0x00, 0x00, // TIPC_NLA_PUBL_TYPE
+	0x08, 0x00, 0x02, 0x00, 0x64, 0x00, 0x00, 0x00, // TIPC_NLA_PUBL_LOWER
+	0x08, 0x00, 0x03, 0x00, 0xc7, 0x00, 0x00, 0x00, // TIPC_NLA_PUBL_UPPER
+	0x08, 0x00, 0x04, 0x00, 0x03, 0x00, 0x00, 0x00, // TIPC_NLA_PUBL_SCOPE
+	0x08, 0x00, 0x05, 0x00, 0x02, 0x10, 0x00, 0x01, // TIPC_NLA_PUBL_NODE
+	0x08, 0x00, 0x06, 0x00, 0xee, 0xff, 0xc0, 0x51, // TIPC_NLA_PUBL_REF
+	0x08, 0x00, 0x07, 0x00, 0xef, 0xff, 0xc0, 0x51, // TIPC_NLA_PUBL_KEY
+}
+
+func parseCannedNetlink(t *testing.T, b []byte) []netlink.Message {
+	if *(*uint16)(unsafe.Pointer(&[]byte{1, 0}[0])) != 1 {
+		t.Skip("skipping test; canned netlink messages are little-endian")
+	}
+	msgs, _, err := netlink.ParseReplies(b, 1)
+	if err != nil {
+		t.Fatalf("netlink.ParseReplies failed: %v", err)
+	}
+	return msgs
+}
+
+func TestNewGenlRequest(t *testing.T) {
+	parseCannedNetlink(t, genlGetFamilyRequest)
+	b := netlink.NewRequest(netlink.GenlIDCtrl, syscall.NLM_F_ACK, 1, netlink.CtrlCmdGetFamily, 1, netlink.StringAttr(netlink.CtrlAttrFamilyName, tipcGenlName))
+	if !bytes.Equal(b, genlGetFamilyRequest) {
+		t.Fatalf("got %#v; expected %#v", b, genlGetFamilyRequest)
+	}
+}
+
+func TestParseGenlFamily(t *testing.T) {
+	msgs := parseCannedNetlink(t, genlNewFamilyReply)
+	id, err := netlink.FamilyID(msgs)
+	if err != nil {
+		t.Fatalf("netlink.FamilyID failed: %v", err)
+	}
+	if id != 0x1a {
+		t.Fatalf("got family %#x; expected 0x1a", id)
+	}
+
+	msgs[0].Attrs = msgs[0].Attrs[:1] // name only
+	if _, err := netlink.FamilyID(msgs); err == nil {
+		t.Fatal("netlink.FamilyID without family identifier succeeded")
+	}
+}
+
+func TestParseTIPCNameTable(t *testing.T) {
+	msgs := parseCannedNetlink(t, tipcNameTableDump)
+	pubs, err := parseTIPCNameTable(msgs)
+	if err != nil {
+		t.Fatalf("parseTIPCNameTable failed: %v", err)
+	}
+	expected := []TIPCPublication{
+		{Service: 1, Lower: 1, Upper: 1, Scope: TIPC_CLUSTER_SCOPE, Node: 0x01001001, Ref: 0x8d1e2a4f},
+		{Service: 18906, Lower: 0, Upper: 99, Scope: TIPC_CLUSTER_SCOPE, Node: 0x01001001, Ref: 0x6a3d9f01},
+		{Service: 18906, Lower: 100, Upper: 199, Scope: TIPC_NODE_SCOPE, Node: 0x01001002, Ref: 0x51c0ffee},
+	}
+	if len(pubs) != len(expected) {
+		t.Fatalf("got %d publications; expected %d", len(pubs), len(expected))
+	}
+	for i := range pubs {
+		if pubs[i] != expected[i] {
+			t.Errorf("publication %d = %+v; expected %+v", i, pubs[i], expected[i])
+		}
+	}
+
+	for _, n := range []int{8, 36} {
+		m := msgs[0]
+		m.Attrs = []netlink.Attr{{Type: tipcNLANameTable, Value: m.Attrs[0].Value[:n]}}
+		if _, err := parseTIPCNameTable([]netlink.Message{m}); err == nil {
+			t.Errorf("parseTIPCNameTable of name table truncated to %d bytes succeeded", n)
+		}
+	}
+}
+
+func TestLookupTIPC(t *testing.T) {
+	skipTIPCTest(t)
+
+	ln, err := ListenTIPC("tipc", &TIPCAddr{AddrType: TIPC_ADDR_NAMESEQ, Service: 18906, Instance: 10, Domain: 20})
+	if err != nil {
+		t.Fatalf("ListenTIPC failed: %v", err)
+	}
+	defer ln.Close()
+	pubs, err := LookupTIPC(18906, 0, 15)
+	if err != nil {
+		t.Skipf("skipping test; TIPC name table is not available: %v", err)
+	}
+	ref := ln.Addr().(*TIPCAddr).Ref
+	for _, p := range pubs {
+		if p.Ref == ref && p.Lower == 10 && p.Upper == 20 {
+			return
+		}
+	}
+	t.Fatalf("got %+v; expected publication of {18906,10,20} by port %d", pubs, ref)
+}
+
+func TestGenlFamily(t *testing.T) {
+	// The generic netlink controller resolves its own name.
+	id, err := genlFamily("nlctrl")
+	if err != nil {
+		t.Skipf("skipping test; generic netlink is not available: %v", err)
+	}
+	if id != netlink.GenlIDCtrl {
+		t.Fatalf("got family %#x; expected %#x", id, netlink.GenlIDCtrl)
+	}
+	if _, err := genlFamily("no such family"); err == nil {
+		t.Fatal("genlFamily of unknown family succeeded")
+	}
+}
@@ -5,62 +5,208 @@
 package net
 
 import (
-    "strconv"
+	"strconv"
+	"sync"
 )
 
 const (
-    TIPC_ADDR_NAMESEQ = 1
-    TIPC_ADDR_MCAST   = 1
-    TIPC_ADDR_NAME    = 2
-    TIPC_ADDR_ID      = 3
+	TIPC_ADDR_NAMESEQ = 1
+	TIPC_ADDR_MCAST   = 1
+	TIPC_ADDR_NAME    = 2
+	TIPC_ADDR_ID      = 3
 )
 
 const (
-    TIPC_ZONE_SCOPE     = 1
-    TIPC_CLUSTER_SCOPE  = 2
-    TIPC_NODE_SCOPE     = 3
+	TIPC_ZONE_SCOPE    = 1
+	TIPC_CLUSTER_SCOPE = 2
+	TIPC_NODE_SCOPE    = 3
+)
+
+// Well-known service types and topology service definitions.
+const (
+	TIPC_CFG_SRV    = 0
+	TIPC_NODE_STATE = 0
+	TIPC_TOP_SRV    = 1
+	TIPC_LINK_STATE = 2
+
+	TIPC_WAIT_FOREVER = 0xffffffff
+
+	TIPC_SUB_PORTS   = 0x01
+	TIPC_SUB_SERVICE = 0x02
+	TIPC_SUB_CANCEL  = 0x04
+
+	TIPC_PUBLISHED      = 1
+	TIPC_WITHDRAWN      = 2
+	TIPC_SUBSCR_TIMEOUT = 3
 )
 
 const addressDelimiter = ';'
 const rangeDelimiter = '-'
+const portDelimiter = ':'
 
-func JoinServiceInstance(service, instance uint32) (string) {
-    var s = strconv.FormatUint(uint64(service), 10)
-    var i = strconv.FormatUint(uint64(instance), 10)
-    return s + string(addressDelimiter) + i
+func JoinServiceInstance(service, instance uint32) string {
+	var s = strconv.FormatUint(uint64(service), 10)
+	var i = strconv.FormatUint(uint64(instance), 10)
+	return s + string(addressDelimiter) + i
 }
 
-func JoinServiceInstanceRange(service, low, high uint32) (string) {
-    var s = strconv.FormatUint(uint64(service), 10)
-    var l = strconv.FormatUint(uint64(low), 10)
-    var h = strconv.FormatUint(uint64(high), 10)
-    return s + string(addressDelimiter) + l + string(rangeDelimiter) + h
+func JoinServiceInstanceRange(service, low, high uint32) string {
+	var s = strconv.FormatUint(uint64(service), 10)
+	var l = strconv.FormatUint(uint64(low), 10)
+	var h = strconv.FormatUint(uint64(high), 10)
+	return s + string(addressDelimiter) + l + string(rangeDelimiter) + h
 }
 
-// TIPCAddr represents the address of a TIPC end point.
+// TIPCAddr represents the address of a TIPC end point.  Names use
+// Service, Instance and, as lookup domain, Domain; name sequences use
+// Service, Instance as lower and Domain as upper bound; port
+// identities (TIPC_ADDR_ID) use Ref and Node.
 type TIPCAddr struct {
-	AddrType   uint8  // only supporting TIPC_ADDR_NAME right now in Resolve
-	Scope      int8   // only used in bind
-	Service    uint32
-	Instance   uint32
-	Domain     uint32
+	AddrType uint8 // TIPC_ADDR_NAME, TIPC_ADDR_NAMESEQ or TIPC_ADDR_ID
+	Scope    int8  // publication scope, only used in bind
+	Service  uint32
+	Instance uint32
+	Domain   uint32
+	Ref      uint32 // port reference of a port identity
+	Node     uint32 // node address of a port identity
 }
 
 // Network returns the address's network name, "tipc".
 func (a *TIPCAddr) Network() string { return "tipc" }
 
+// String returns the canonical form of a as accepted by
+// ResolveTIPCAddr.
 func (a *TIPCAddr) String() string {
 	if a == nil {
 		return "<tipcnil>"
 	}
-	if a.AddrType == TIPC_ADDR_NAME {
-		return JoinServiceInstance(a.Service, a.Instance)
-	} else if a.AddrType == TIPC_ADDR_NAMESEQ {
-		return JoinServiceInstanceRange(a.Service, a.Instance, a.Domain)
-    }
-	return "<tipcundef>"
+	var s string
+	switch a.AddrType {
+	case TIPC_ADDR_NAME:
+		s = "{" + itod(uint(a.Service)) + "," + itod(uint(a.Instance)) + "}"
+		if a.Domain != 0 {
+			s += "@" + tipcNodeString(a.Domain)
+		}
+	case TIPC_ADDR_NAMESEQ:
+		s = "{" + itod(uint(a.Service)) + "," + itod(uint(a.Instance)) + "," + itod(uint(a.Domain)) + "}"
+	case TIPC_ADDR_ID:
+		return "<" + tipcNodeString(a.Node) + string(portDelimiter) + itod(uint(a.Ref)) + ">"
+	default:
+		return "<tipcundef>"
+	}
+	switch a.Scope {
+	case TIPC_ZONE_SCOPE:
+		s += "/zone"
+	case TIPC_CLUSTER_SCOPE:
+		s += "/cluster"
+	case TIPC_NODE_SCOPE:
+		s += "/node"
+	}
+	return s
+}
+
+// TIPCNodeAddr is the network address of a TIPC node, written
+// "zone.cluster.node".
+type TIPCNodeAddr uint32
+
+// ParseTIPCNodeAddr parses s as a TIPC node address of the form
+// "zone.cluster.node".
+func ParseTIPCNodeAddr(s string) (TIPCNodeAddr, error) {
+	n, ok := parseTIPCNode(s)
+	if !ok {
+		return 0, &AddrError{Err: "invalid TIPC node address", Addr: s}
+	}
+	return TIPCNodeAddr(n), nil
+}
+
+// Zone returns the zone of n.
+func (n TIPCNodeAddr) Zone() int { return int(n >> 24) }
+
+// Cluster returns the cluster of n within its zone.
+func (n TIPCNodeAddr) Cluster() int { return int(n >> 12 & 0xfff) }
+
+// Node returns the node number of n within its cluster.
+func (n TIPCNodeAddr) Node() int { return int(n & 0xfff) }
+
+func (n TIPCNodeAddr) String() string { return tipcNodeString(uint32(n)) }
+
+// tipcNodeString returns the "zone.cluster.node" form of the TIPC
+// network address n.
+func tipcNodeString(n uint32) string {
+	return itod(uint(n>>24)) + "." + itod(uint(n>>12&0xfff)) + "." + itod(uint(n&0xfff))
+}
+
+// parseTIPCNode parses a TIPC network address of the form
+// "zone.cluster.node".
+func parseTIPCNode(s string) (uint32, bool) {
+	var f [3]int
+	i := 0
+	for k, max := range []int{0xff, 0xfff, 0xfff} {
+		if k > 0 {
+			if i >= len(s) || s[i] != '.' {
+				return 0, false
+			}
+			i++
+		}
+		d, j, ok := dtoi(s, i)
+		if !ok || d > max {
+			return 0, false
+		}
+		f[k], i = d, j
+	}
+	if i != len(s) {
+		return 0, false
+	}
+	return uint32(f[0])<<24 | uint32(f[1])<<12 | uint32(f[2]), true
+}
+
+// parseTIPCPortID parses a TIPC port identity of the form
+// "<zone.cluster.node:ref>".
+func parseTIPCPortID(s string) (*TIPCAddr, bool) {
+	if len(s) < 2 || s[0] != '<' || s[len(s)-1] != '>' {
+		return nil, false
+	}
+	s = s[1 : len(s)-1]
+	i := byteIndex(s, portDelimiter)
+	if i < 0 {
+		return nil, false
+	}
+	node, ok := parseTIPCNode(s[:i])
+	if !ok {
+		return nil, false
+	}
+	ref, err := strconv.ParseUint(s[i+1:], 10, 32)
+	if err != nil {
+		return nil, false
+	}
+	return &TIPCAddr{AddrType: TIPC_ADDR_ID, Ref: uint32(ref), Node: node}, true
+}
+
+// TIPCPublication is an entry of the TIPC name table: the
+// publication of a name sequence by a socket.
+type TIPCPublication struct {
+	Service uint32 // service type
+	Lower   uint32 // lower bound of the instances
+	Upper   uint32 // upper bound of the instances
+	Scope   int8   // TIPC_ZONE_SCOPE, TIPC_CLUSTER_SCOPE or TIPC_NODE_SCOPE
+	Node    uint32 // node of the publishing socket
+	Ref     uint32 // port reference of the publishing socket
 }
 
+// isMulticast reports whether a is a name sequence, which is used as
+// a multicast destination when sending.
+func (a *TIPCAddr) isMulticast() bool {
+	return a != nil && a.AddrType == TIPC_ADDR_MCAST
+}
+
+// nameSeq returns the name sequence covered by a name or name
+// sequence address.
+func (a *TIPCAddr) nameSeq() (service, lower, upper uint32) {
+	if a.AddrType == TIPC_ADDR_NAMESEQ {
+		return a.Service, a.Instance, a.Domain
+	}
+	return a.Service, a.Instance, a.Instance
+}
 
 func (a *TIPCAddr) toAddr() Addr {
 	if a == nil {
@@ -69,55 +215,218 @@ func (a *TIPCAddr) toAddr() Addr {
 	return a
 }
 
-// ResolveTIPCAddr parses addr as a TIPC address of the form "service:instance"
-// and resolves a pair of service name and port name on the network net, 
-// which must be "tipc".
-
+// ResolveTIPCAddr parses addr as a TIPC address on the network net,
+// which must be "tipc", "tipc-seqpacket", "tipc-rdm" or "tipc-dgram".
+//
+// A name is written "{service,instance}" and a name sequence
+// "{service,lower,upper}"; the older forms "service;instance" and
+// "service;lower-upper" are accepted as well.  A name may be followed
+// by "@zone.cluster.node" to restrict its lookup to that domain, and
+// a name or name sequence by "/zone", "/cluster" or "/node" to set
+// the scope of its publication when it is bound.  The port identity
+// of a particular socket is written "<zone.cluster.node:ref>".
 func ResolveTIPCAddr(net, addr string) (*TIPCAddr, error) {
-	if net != "tipc" {
+	switch net {
+	case "tipc", "tipc-seqpacket", "tipc-rdm", "tipc-dgram":
+	default:
 		return nil, UnknownNetworkError(net)
 	}
+	a, err := parseTIPCAddr(addr)
+	if err != "" {
+		return nil, &AddrError{Err: err, Addr: addr}
+	}
+	return a, nil
+}
 
-    var addrSep = last(addr, addressDelimiter)
+// parseTIPCAddr parses s according to the grammar described at
+// ResolveTIPCAddr.  On failure it returns a description of the
+// malformed part of s.
+func parseTIPCAddr(s string) (*TIPCAddr, string) {
+	if s == "" {
+		return nil, "missing TIPC address"
+	}
+	if s[0] == '<' {
+		a, ok := parseTIPCPortID(s)
+		if !ok {
+			return nil, "invalid TIPC port identity"
+		}
+		return a, ""
+	}
+
+	a := new(TIPCAddr)
+	if i := last(s, '/'); i >= 0 {
+		switch s[i+1:] {
+		case "zone":
+			a.Scope = TIPC_ZONE_SCOPE
+		case "cluster":
+			a.Scope = TIPC_CLUSTER_SCOPE
+		case "node":
+			a.Scope = TIPC_NODE_SCOPE
+		default:
+			return nil, "unknown TIPC scope"
+		}
+		s = s[:i]
+	}
+	domain := ""
+	if i := last(s, '@'); i >= 0 {
+		s, domain = s[:i], s[i+1:]
+		if domain == "" {
+			return nil, "invalid TIPC lookup domain"
+		}
+	}
 
-    if addrSep < 0 {
-        return nil, UnknownNetworkError(net)
-    }
+	// Split the name into its service and instance fields.
+	var f []string
+	if len(s) > 0 && s[0] == '{' {
+		if s[len(s)-1] != '}' {
+			return nil, "malformed TIPC name"
+		}
+		for s = s[1 : len(s)-1]; ; {
+			i := byteIndex(s, ',')
+			if i < 0 {
+				f = append(f, s)
+				break
+			}
+			f, s = append(f, s[:i]), s[i+1:]
+		}
+	} else {
+		i := byteIndex(s, addressDelimiter)
+		if i < 0 {
+			return nil, "malformed TIPC name"
+		}
+		f = append(f, s[:i])
+		s = s[i+1:]
+		if i := byteIndex(s, rangeDelimiter); i >= 0 {
+			f = append(f, s[:i], s[i+1:])
+		} else {
+			f = append(f, s)
+		}
+	}
+	if len(f) != 2 && len(f) != 3 {
+		return nil, "malformed TIPC name"
+	}
+	service, err := strconv.ParseUint(f[0], 10, 32)
+	if err != nil {
+		return nil, "invalid TIPC service type"
+	}
+	var inst [2]uint64
+	for i, v := range f[1:] {
+		if inst[i], err = strconv.ParseUint(v, 10, 32); err != nil {
+			return nil, "invalid TIPC instance"
+		}
+	}
+	a.Service, a.Instance = uint32(service), uint32(inst[0])
 
-    service, err := strconv.ParseUint(addr[:addrSep], 10, 32)
+	if len(f) == 3 {
+		if inst[0] > inst[1] {
+			return nil, "invalid TIPC name sequence bounds"
+		}
+		if domain != "" {
+			return nil, "unexpected lookup domain in TIPC name sequence"
+		}
+		a.AddrType = TIPC_ADDR_NAMESEQ
+		a.Domain = uint32(inst[1])
+		return a, ""
+	}
+	a.AddrType = TIPC_ADDR_NAME
+	if domain != "" {
+		d, ok := parseTIPCNode(domain)
+		if !ok {
+			return nil, "invalid TIPC lookup domain"
+		}
+		a.Domain = d
+	}
+	return a, ""
+}
 
-    if err != nil {
-        return nil, UnknownNetworkError(net)
-    }
+// TIPCConn is an implementation of the Conn interface for TIPC network
+// connections.  On "tipc-seqpacket" connections message boundaries
+// are preserved: each Write sends one message and each Read returns
+// at most one message.
+type TIPCConn struct {
+	conn
+}
 
-    var rangeSep = last(addr, rangeDelimiter)
+func newTIPCConn(fd *netFD) *TIPCConn { return &TIPCConn{conn{fd}} }
 
-    if rangeSep < 0 {
+// TIPCListener is a TIPC network listener.  Clients should typically
+// use variables of type Listener instead of assuming TIPC.
+type TIPCListener struct {
+	fd    *netFD
+	names tipcNames
+}
 
-        instance, err := strconv.ParseUint(addr[addrSep+1:], 10, 32)
+// TIPCPacketConn is an implementation of the Conn and PacketConn
+// interfaces for connectionless TIPC sockets, either reliable
+// datagram ("tipc-rdm") or unreliable datagram ("tipc-dgram").
+type TIPCPacketConn struct {
+	conn
+	names tipcNames
+}
 
-        if err != nil {
-            return nil, UnknownNetworkError(net)
-        }
+func newTIPCPacketConn(fd *netFD) *TIPCPacketConn { return &TIPCPacketConn{conn: conn{fd}} }
 
-	    var x = TIPCAddr{AddrType: TIPC_ADDR_NAME, Scope: TIPC_ZONE_SCOPE, Service: uint32(service), Instance: uint32(instance), Domain: 0}
+// TIPCGroupConn is a member of a TIPC communication group.  Group
+// members are reliable datagram sockets bound to a member name whose
+// service type identifies the group; messages between members are
+// flow controlled by the kernel and can be sent to one member, to
+// any member with a given instance, to the members within an
+// instance range or to all members of the group.
+type TIPCGroupConn struct {
+	conn
 
-        return &x, nil
+	mu     sync.Mutex
+	member *TIPCAddr // nil unless joined
+}
 
-    } else {
+// tipcNames is the set of names and name sequences published by a
+// TIPC socket.
+type tipcNames struct {
+	mu    sync.Mutex
+	addrs []*TIPCAddr
+}
 
-        startRange, err := strconv.ParseUint(addr[addrSep + 1:rangeSep], 10, 32)
-        if err != nil {
-            return nil, UnknownNetworkError(net)
-        }
+// TIPCListenConfig contains options for listening on a TIPC address.
+// The options are applied to the socket before it is bound to its
+// name, so that they are in effect from the first message or
+// connection request on.
+type TIPCListenConfig struct {
+	// Importance is the importance of the messages sent from the
+	// socket: syscall.TIPC_LOW_IMPORTANCE (the default),
+	// syscall.TIPC_MEDIUM_IMPORTANCE, syscall.TIPC_HIGH_IMPORTANCE
+	// or syscall.TIPC_CRITICAL_IMPORTANCE.
+	Importance int
 
-        endRange, err := strconv.ParseUint(addr[rangeSep + 1:], 10, 32)
-        if err != nil {
-            return nil, UnknownNetworkError(net)
-        }
+	// Scope, if nonzero, replaces the scope of the address the
+	// socket is bound to: TIPC_ZONE_SCOPE, TIPC_CLUSTER_SCOPE or
+	// TIPC_NODE_SCOPE.
+	Scope int8
+}
 
-	    var x = TIPCAddr{AddrType: TIPC_ADDR_NAMESEQ, Scope: TIPC_ZONE_SCOPE, Service: uint32(service), Instance: uint32(startRange), Domain:uint32(endRange)}
-        return &x, nil
-    }
+// Listen acts like the Listen function for the networks "tipc" and
+// "tipc-seqpacket" but applies the options of lc.
+func (lc *TIPCListenConfig) Listen(net, laddr string) (Listener, error) {
+	la, err := ResolveTIPCAddr(net, laddr)
+	if err != nil {
+		return nil, &OpError{Op: "listen", Net: net, Addr: nil, Err: err}
+	}
+	l, err := lc.ListenTIPC(net, la)
+	if err != nil {
+		return nil, err // l is non-nil interface containing nil pointer
+	}
+	return l, nil
+}
 
+// ListenPacket acts like the ListenPacket function for the networks
+// "tipc-rdm" and "tipc-dgram" but applies the options of lc.
+func (lc *TIPCListenConfig) ListenPacket(net, laddr string) (PacketConn, error) {
+	la, err := ResolveTIPCAddr(net, laddr)
+	if err != nil {
+		return nil, &OpError{Op: "listen", Net: net, Addr: nil, Err: err}
+	}
+	c, err := lc.ListenTIPCPacket(net, la)
+	if err != nil {
+		return nil, err // c is non-nil interface containing nil pointer
+	}
+	return c, nil
 }
@@ -0,0 +1,797 @@
+// Copyright 2009 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+//  incorporate http://tipc.sourceforge.net/doc/Programmers_Guide.txt
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+	"time"
+)
+
+// tipcSockopts holds the TIPC socket options that take effect only
+// when set before the socket is bound or connected.
+type tipcSockopts struct {
+	importance  int           // TIPC_IMPORTANCE, if nonzero
+	connTimeout time.Duration // TIPC_CONN_TIMEOUT, if positive
+}
+
+func (o *tipcSockopts) set(fd *netFD) error {
+	if o.importance != 0 {
+		if err := setTIPCImportance(fd, o.importance); err != nil {
+			return err
+		}
+	}
+	if o.connTimeout > 0 {
+		return setTIPCConnTimeout(fd, o.connTimeout)
+	}
+	return nil
+}
+
+func tipcSocket(net string, laddr, raddr sockaddr, mode string, deadline time.Time, opts *tipcSockopts) (*netFD, error) {
+	var sotype int
+	switch net {
+	case "tipc":
+		sotype = syscall.SOCK_STREAM
+	case "tipc-seqpacket":
+		sotype = syscall.SOCK_SEQPACKET
+	case "tipc-rdm":
+		sotype = syscall.SOCK_RDM
+	case "tipc-dgram":
+		sotype = syscall.SOCK_DGRAM
+	default:
+		return nil, UnknownNetworkError(net)
+	}
+
+	if laddr != nil && laddr.isWildcard() {
+		laddr = nil
+	}
+	if raddr != nil && raddr.isWildcard() {
+		raddr = nil
+	}
+	switch mode {
+	case "dial":
+		if raddr == nil {
+			return nil, errMissingAddress
+		}
+		if raddr.(*TIPCAddr).isMulticast() {
+			return nil, InvalidAddrError("cannot connect to TIPC multicast address")
+		}
+	case "listen":
+	default:
+		return nil, errors.New("unknown mode: " + mode)
+	}
+
+	return tipcSocketFunc(net, sotype, laddr, raddr, deadline, opts)
+}
+
+// tipcSocketFunc creates the socket of a TIPC endpoint, bound to laddr
+// and connected to raddr if they are not nil.  It is replaced by the
+// tests that run without the tipc kernel module.
+var tipcSocketFunc = sysTIPCSocket
+
+func sysTIPCSocket(net string, sotype int, laddr, raddr sockaddr, deadline time.Time, opts *tipcSockopts) (*netFD, error) {
+	var sockopts func(*netFD) error
+	if opts != nil {
+		sockopts = opts.set
+	}
+	return socket(net, syscall.AF_TIPC, sotype, 0, false, laddr, raddr, deadline, sockopts)
+}
+
+func tipcSotypeToNet(sotype int) string {
+	switch sotype {
+	case syscall.SOCK_STREAM:
+		return "tipc"
+	case syscall.SOCK_SEQPACKET:
+		return "tipc-seqpacket"
+	case syscall.SOCK_RDM:
+		return "tipc-rdm"
+	case syscall.SOCK_DGRAM:
+		return "tipc-dgram"
+	}
+	return ""
+}
+
+// tipcAddrFunc returns the function converting the socket addresses
+// of a socket of the given family and type, or nil if it is not a
+// TIPC socket.
+func tipcAddrFunc(family, sotype int) func(syscall.Sockaddr) Addr {
+	if family != syscall.AF_TIPC {
+		return nil
+	}
+	if tipcSotypeToNet(sotype) == "" {
+		return nil
+	}
+	return sockaddrToTIPC
+}
+
+// tipcSockaddrFamily returns AF_TIPC if sa is a TIPC socket address
+// and AF_UNSPEC otherwise.
+func tipcSockaddrFamily(sa syscall.Sockaddr) int {
+	if _, ok := sa.(*syscall.SockaddrTIPC); ok {
+		return syscall.AF_TIPC
+	}
+	return syscall.AF_UNSPEC
+}
+
+// convert system sockaddr to net.TIPCAddr
+func sockaddrToTIPC(sa syscall.Sockaddr) Addr {
+	switch sa := sa.(type) {
+	case *syscall.SockaddrTIPC:
+		switch sa.AddrType {
+		case TIPC_ADDR_ID:
+			return &TIPCAddr{AddrType: sa.AddrType, Scope: sa.Scope, Ref: sa.ID.Ref, Node: sa.ID.Node}
+		case TIPC_ADDR_NAME:
+			return &TIPCAddr{AddrType: sa.AddrType, Scope: sa.Scope, Service: sa.Name.Type, Instance: sa.Name.Instance, Domain: sa.Domain}
+		}
+		return &TIPCAddr{AddrType: sa.AddrType, Scope: sa.Scope, Service: sa.NameSeq.Type, Instance: sa.NameSeq.Lower, Domain: sa.NameSeq.Upper}
+	}
+	return nil
+}
+
+func (a *TIPCAddr) family() int {
+	return syscall.AF_TIPC
+}
+
+func (a *TIPCAddr) isWildcard() bool {
+	return a == nil
+}
+
+// convert net.TIPCAddr to system sockaddr
+func (a *TIPCAddr) sockaddr(family int) (syscall.Sockaddr, error) {
+	if a == nil {
+		return nil, nil
+	}
+	f := &syscall.SockaddrTIPC{AddrType: a.AddrType, Scope: a.Scope}
+	if f.Scope == 0 {
+		// The scope is only used when binding, where the
+		// kernel rejects a zero scope.
+		f.Scope = TIPC_ZONE_SCOPE
+	}
+	switch a.AddrType {
+	case TIPC_ADDR_ID:
+		f.ID = syscall.TIPCPortID{Ref: a.Ref, Node: a.Node}
+	case TIPC_ADDR_NAME:
+		f.Name = syscall.TIPCName{Type: a.Service, Instance: a.Instance}
+		f.Domain = a.Domain
+	case TIPC_ADDR_NAMESEQ: // also TIPC_ADDR_MCAST
+		f.NameSeq = syscall.TIPCNameSeq{Type: a.Service, Lower: a.Instance, Upper: a.Domain}
+	}
+	return f, nil
+}
+
+// add records the publication of a, which may be nil.
+func (ns *tipcNames) add(a *TIPCAddr) {
+	if a == nil {
+		return
+	}
+	ns.mu.Lock()
+	b := *a
+	ns.addrs = append(ns.addrs, &b)
+	ns.mu.Unlock()
+}
+
+// publish binds fd to a and records the publication.
+func (ns *tipcNames) publish(fd *netFD, a *TIPCAddr) error {
+	if err := checkTIPCName(a); err != nil {
+		return &OpError{Op: "publish", Net: fd.net, Addr: a.toAddr(), Err: err}
+	}
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if err := bindTIPC(fd, a, false); err != nil {
+		return &OpError{Op: "publish", Net: fd.net, Addr: a, Err: err}
+	}
+	b := *a
+	ns.addrs = append(ns.addrs, &b)
+	return nil
+}
+
+// withdraw unbinds fd from the recorded publication covering the
+// same names as a.
+func (ns *tipcNames) withdraw(fd *netFD, a *TIPCAddr) error {
+	if err := checkTIPCName(a); err != nil {
+		return &OpError{Op: "withdraw", Net: fd.net, Addr: a.toAddr(), Err: err}
+	}
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	service, lower, upper := a.nameSeq()
+	for i, b := range ns.addrs {
+		if s, l, u := b.nameSeq(); s != service || l != lower || u != upper {
+			continue
+		}
+		// The kernel identifies a publication by its names and
+		// its scope, so withdraw it with the scope it was
+		// published with.
+		if err := bindTIPC(fd, b, true); err != nil {
+			return &OpError{Op: "withdraw", Net: fd.net, Addr: a, Err: err}
+		}
+		ns.addrs = append(ns.addrs[:i], ns.addrs[i+1:]...)
+		return nil
+	}
+	return &OpError{Op: "withdraw", Net: fd.net, Addr: a, Err: errTIPCNotPublished}
+}
+
+// list returns a copy of the recorded publications.
+func (ns *tipcNames) list() []*TIPCAddr {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	addrs := make([]*TIPCAddr, len(ns.addrs))
+	for i, a := range ns.addrs {
+		b := *a
+		addrs[i] = &b
+	}
+	return addrs
+}
+
+var errTIPCNotPublished = errors.New("TIPC name not published")
+
+// checkTIPCName reports whether a can be published.
+func checkTIPCName(a *TIPCAddr) error {
+	if a == nil {
+		return errMissingAddress
+	}
+	if a.AddrType != TIPC_ADDR_NAME && a.AddrType != TIPC_ADDR_NAMESEQ {
+		return InvalidAddrError("cannot publish TIPC port identity")
+	}
+	return nil
+}
+
+// bindTIPC publishes the name or name sequence a on fd or, if
+// withdraw is set, withdraws it again.
+func bindTIPC(fd *netFD, a *TIPCAddr, withdraw bool) error {
+	sa, err := a.sockaddr(fd.family)
+	if err != nil {
+		return err
+	}
+	tsa := sa.(*syscall.SockaddrTIPC)
+	service, lower, upper := a.nameSeq()
+	tsa.AddrType = TIPC_ADDR_NAMESEQ
+	tsa.NameSeq = syscall.TIPCNameSeq{Type: service, Lower: lower, Upper: upper}
+	if withdraw {
+		// Binding with a negated scope withdraws the
+		// publication.
+		tsa.Scope = -tsa.Scope
+	}
+	if err := fd.incref(); err != nil {
+		return err
+	}
+	defer fd.decref()
+	return os.NewSyscallError("bind", syscall.Bind(fd.sysfd, tsa))
+}
+
+// ReadFrom implements the io.ReaderFrom ReadFrom method.
+func (c *TIPCConn) ReadFrom(r io.Reader) (int64, error) {
+	if c.ok() && c.fd.sotype == syscall.SOCK_STREAM {
+		if n, err, handled := sendFile(c.fd, r); handled {
+			return n, err
+		}
+	}
+	return genericReadFrom(c, r)
+}
+
+// ReadMsgTIPC reads a message from c, copying the payload into b and
+// the associated out-of-band data into oob.  It returns the number of
+// bytes copied into b, the number of bytes copied into oob, the flags
+// that were set on the message and the address of the sending socket.
+// On "tipc-seqpacket" connections flags has syscall.MSG_TRUNC set when
+// the message was larger than b and its tail was discarded.
+func (c *TIPCConn) ReadMsgTIPC(b, oob []byte) (n, oobn, flags int, addr *TIPCAddr, err error) {
+	if !c.ok() {
+		return 0, 0, 0, nil, syscall.EINVAL
+	}
+	var sa syscall.Sockaddr
+	n, oobn, flags, sa, err = c.fd.readMsg(b, oob)
+	if a, ok := sockaddrToTIPC(sa).(*TIPCAddr); ok {
+		addr = a
+	}
+	return
+}
+
+// WriteMsgTIPC writes a message to the peer of c, copying the payload
+// from b and the associated out-of-band data from oob.  It returns the
+// number of payload and out-of-band bytes written.
+func (c *TIPCConn) WriteMsgTIPC(b, oob []byte) (n, oobn int, err error) {
+	if !c.ok() {
+		return 0, 0, syscall.EINVAL
+	}
+	return c.fd.writeMsg(b, oob, nil)
+}
+
+// CloseRead shuts down the reading side of the TIPC connection.
+// Most callers should just use Close.
+func (c *TIPCConn) CloseRead() error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	return c.fd.closeRead()
+}
+
+// CloseWrite shuts down the writing side of the TIPC connection.
+// Most callers should just use Close.
+func (c *TIPCConn) CloseWrite() error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	return c.fd.closeWrite()
+}
+
+// SetLinger sets the behavior of Close on a connection which still
+// has data waiting to be sent or to be acknowledged.
+//
+// If sec < 0 (the default), the operating system finishes sending the
+// data in the background.
+//
+// If sec == 0, the operating system discards any unsent or
+// unacknowledged data.
+//
+// If sec > 0, the data is sent in the background as with sec < 0. On
+// some operating systems after sec seconds have elapsed any remaining
+// unsent data may be discarded.
+func (c *TIPCConn) SetLinger(sec int) error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	return setLinger(c.fd, sec)
+}
+
+// SetImportance sets the importance of the messages sent on the
+// connection, which decides which messages are discarded first under
+// congestion.  It must be one of syscall.TIPC_LOW_IMPORTANCE (the
+// default), syscall.TIPC_MEDIUM_IMPORTANCE,
+// syscall.TIPC_HIGH_IMPORTANCE or syscall.TIPC_CRITICAL_IMPORTANCE.
+func (c *TIPCConn) SetImportance(importance int) error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	return setTIPCImportance(c.fd, importance)
+}
+
+// SetConnTimeout sets the TIPC_CONN_TIMEOUT option of the
+// connection's socket, which bounds how long the operating system
+// waits for a connection to be set up.  The timeout is rounded up to
+// a whole number of milliseconds.  A Dialer sets it from its Timeout.
+func (c *TIPCConn) SetConnTimeout(d time.Duration) error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	return setTIPCConnTimeout(c.fd, d)
+}
+
+// SetSrcDroppable sets whether messages sent on the connection may be
+// discarded when congestion occurs instead of blocking the sender.
+func (c *TIPCConn) SetSrcDroppable(droppable bool) error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	return setTIPCSrcDroppable(c.fd, droppable)
+}
+
+// SetDestDroppable sets whether messages sent on the connection are
+// discarded, rather than returned to the sender, when they cannot be
+// delivered.
+func (c *TIPCConn) SetDestDroppable(droppable bool) error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	return setTIPCDestDroppable(c.fd, droppable)
+}
+
+// NodeRecvQueueDepth returns the number of messages waiting in the
+// receive queues of all sockets on the local node.
+func (c *TIPCConn) NodeRecvQueueDepth() (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	return tipcRecvQueueDepth(c.fd, syscall.TIPC_NODE_RECVQ_DEPTH)
+}
+
+// SockRecvQueueDepth returns the number of messages waiting in the
+// receive queue of the connection.
+func (c *TIPCConn) SockRecvQueueDepth() (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	return tipcRecvQueueDepth(c.fd, syscall.TIPC_SOCK_RECVQ_DEPTH)
+}
+
+// DialTIPC connects to the remote address raddr on the network net,
+// which must be "tipc" or "tipc-seqpacket".  If laddr is not nil, it
+// is used as the local address for the connection.
+func DialTIPC(net string, laddr, raddr *TIPCAddr) (*TIPCConn, error) {
+	switch net {
+	case "tipc", "tipc-seqpacket":
+	default:
+		return nil, &OpError{Op: "dial", Net: net, Addr: raddr, Err: UnknownNetworkError(net)}
+	}
+	if raddr == nil {
+		return nil, &OpError{Op: "dial", Net: net, Addr: nil, Err: errMissingAddress}
+	}
+	return dialTIPC(net, laddr, raddr, noDeadline)
+}
+
+func dialTIPC(net string, laddr, raddr *TIPCAddr, deadline time.Time) (*TIPCConn, error) {
+	// The kernel waits for the peer to accept the connection for
+	// TIPC_CONN_TIMEOUT, eight seconds by default, even when the
+	// socket is nonblocking, so let it give up at the deadline too.
+	var opts *tipcSockopts
+	if !deadline.IsZero() {
+		timeout := deadline.Sub(time.Now())
+		if timeout <= 0 {
+			return nil, &OpError{Op: "dial", Net: net, Addr: raddr, Err: errTimeout}
+		}
+		opts = &tipcSockopts{connTimeout: timeout}
+	}
+	fd, err := tipcSocket(net, laddr, raddr, "dial", deadline, opts)
+	if err != nil {
+		return nil, &OpError{Op: "dial", Net: net, Addr: raddr, Err: err}
+	}
+	return newTIPCConn(fd), nil
+}
+
+// AcceptTIPC accepts the next incoming call and returns the new
+// connection.
+func (l *TIPCListener) AcceptTIPC() (*TIPCConn, error) {
+	if l == nil || l.fd == nil {
+		return nil, syscall.EINVAL
+	}
+	fd, err := l.fd.accept()
+	if err != nil {
+		return nil, err
+	}
+	return newTIPCConn(fd), nil
+}
+
+// Accept implements the Accept method in the Listener interface; it
+// waits for the next call and returns a generic Conn.
+func (l *TIPCListener) Accept() (c Conn, err error) {
+	return l.AcceptTIPC()
+}
+
+// Close stops listening on the TIPC address.
+// Already Accepted connections are not closed.
+func (l *TIPCListener) Close() error {
+	if l == nil || l.fd == nil {
+		return syscall.EINVAL
+	}
+	return l.fd.Close()
+}
+
+// Addr returns the listener's network address, a *TIPCAddr.
+func (l *TIPCListener) Addr() Addr { return l.fd.laddr }
+
+// SetDeadline sets the deadline associated with the listener.
+// A zero time value disables the deadline.
+func (l *TIPCListener) SetDeadline(t time.Time) error {
+	if l == nil || l.fd == nil {
+		return syscall.EINVAL
+	}
+	return l.fd.setDeadline(t)
+}
+
+// NodeRecvQueueDepth returns the number of messages waiting in the
+// receive queues of all sockets on the local node.
+func (l *TIPCListener) NodeRecvQueueDepth() (int, error) {
+	if l == nil || l.fd == nil {
+		return 0, syscall.EINVAL
+	}
+	return tipcRecvQueueDepth(l.fd, syscall.TIPC_NODE_RECVQ_DEPTH)
+}
+
+// SockRecvQueueDepth returns the number of connection requests
+// waiting to be accepted on the listener.
+func (l *TIPCListener) SockRecvQueueDepth() (int, error) {
+	if l == nil || l.fd == nil {
+		return 0, syscall.EINVAL
+	}
+	return tipcRecvQueueDepth(l.fd, syscall.TIPC_SOCK_RECVQ_DEPTH)
+}
+
+// File returns a copy of the underlying os.File, set to blocking
+// mode.  It is the caller's responsibility to close f when finished.
+// Closing l does not affect f, and closing f does not affect l.
+//
+// The returned os.File's file descriptor is different from the
+// connection's.  Attempting to change properties of the original
+// using this duplicate may or may not have the desired effect.
+func (l *TIPCListener) File() (f *os.File, err error) { return l.fd.dup() }
+
+// Publish binds l to the additional name or name sequence addr, so
+// that connections to addr are accepted on l as well.  The scope of
+// addr limits the visibility of the publication.
+func (l *TIPCListener) Publish(addr *TIPCAddr) error {
+	if l == nil || l.fd == nil {
+		return syscall.EINVAL
+	}
+	return l.names.publish(l.fd, addr)
+}
+
+// Withdraw unbinds l from the name or name sequence addr, which must
+// have been published before.  Connections accepted earlier through
+// addr and connections waiting to be accepted are not affected.
+func (l *TIPCListener) Withdraw(addr *TIPCAddr) error {
+	if l == nil || l.fd == nil {
+		return syscall.EINVAL
+	}
+	return l.names.withdraw(l.fd, addr)
+}
+
+// Addrs returns the names and name sequences that l is currently
+// bound to.
+func (l *TIPCListener) Addrs() []*TIPCAddr {
+	if l == nil || l.fd == nil {
+		return nil
+	}
+	return l.names.list()
+}
+
+// ListenTIPC announces on the TIPC address laddr and returns a TIPC
+// listener.  Net must be "tipc" or "tipc-seqpacket"; connections
+// accepted on the listener have the same socket type.  The caller can
+// use the Addr method of TIPCListener to retrieve the bound address.
+func ListenTIPC(net string, laddr *TIPCAddr) (*TIPCListener, error) {
+	return listenTIPC(net, laddr, nil)
+}
+
+func listenTIPC(net string, laddr *TIPCAddr, opts *tipcSockopts) (*TIPCListener, error) {
+	switch net {
+	case "tipc", "tipc-seqpacket":
+	default:
+		return nil, &OpError{Op: "listen", Net: net, Addr: laddr, Err: UnknownNetworkError(net)}
+	}
+	if laddr == nil {
+		return nil, &OpError{Op: "listen", Net: net, Addr: nil, Err: errMissingAddress}
+	}
+
+	fd, err := tipcSocket(net, laddr, nil, "listen", noDeadline, opts)
+	if err != nil {
+		return nil, &OpError{Op: "listen", Net: net, Addr: laddr, Err: err}
+	}
+
+	l := &TIPCListener{fd: fd}
+	l.names.add(laddr)
+	return l, nil
+}
+
+// ReadFromTIPC reads a message from c, copying the payload into b.
+// It returns the number of bytes copied into b and the address of
+// the sending socket.
+//
+// ReadFromTIPC can be made to time out and return an error with
+// Timeout() == true after a fixed time limit; see SetDeadline and
+// SetReadDeadline.
+func (c *TIPCPacketConn) ReadFromTIPC(b []byte) (n int, addr *TIPCAddr, err error) {
+	if !c.ok() {
+		return 0, nil, syscall.EINVAL
+	}
+	n, sa, err := c.fd.readFrom(b)
+	if a, ok := sockaddrToTIPC(sa).(*TIPCAddr); ok {
+		addr = a
+	}
+	return
+}
+
+// ReadFrom implements the PacketConn ReadFrom method.
+func (c *TIPCPacketConn) ReadFrom(b []byte) (int, Addr, error) {
+	if !c.ok() {
+		return 0, nil, syscall.EINVAL
+	}
+	n, addr, err := c.ReadFromTIPC(b)
+	return n, addr.toAddr(), err
+}
+
+// ReadMsgTIPC reads a message from c, copying the payload into b and
+// the associated out-of-band data into oob.  It returns the number of
+// bytes copied into b, the number of bytes copied into oob, the flags
+// that were set on the message and the address of the sending socket.
+// The out-of-band data of undeliverable messages returned to their
+// sender and the destination name of received messages can be
+// decoded with syscall.ParseSocketControlMessage and
+// syscall.ParseTIPCMsgInfo.
+func (c *TIPCPacketConn) ReadMsgTIPC(b, oob []byte) (n, oobn, flags int, addr *TIPCAddr, err error) {
+	if !c.ok() {
+		return 0, 0, 0, nil, syscall.EINVAL
+	}
+	var sa syscall.Sockaddr
+	n, oobn, flags, sa, err = c.fd.readMsg(b, oob)
+	if a, ok := sockaddrToTIPC(sa).(*TIPCAddr); ok {
+		addr = a
+	}
+	return
+}
+
+// WriteToTIPC writes a message to addr via c, copying the payload
+// from b.  If addr is a name sequence, the message is multicast to
+// every socket bound to a name overlapping the sequence.
+//
+// WriteToTIPC can be made to time out and return an error with
+// Timeout() == true after a fixed time limit; see SetDeadline and
+// SetWriteDeadline.  On packet-oriented connections, write timeouts
+// are rare.
+func (c *TIPCPacketConn) WriteToTIPC(b []byte, addr *TIPCAddr) (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	if c.fd.isConnected {
+		return 0, &OpError{Op: "write", Net: c.fd.net, Addr: addr, Err: ErrWriteToConnected}
+	}
+	if addr == nil {
+		return 0, &OpError{Op: "write", Net: c.fd.net, Addr: nil, Err: errMissingAddress}
+	}
+	sa, err := addr.sockaddr(c.fd.family)
+	if err != nil {
+		return 0, &OpError{Op: "write", Net: c.fd.net, Addr: addr, Err: err}
+	}
+	return c.fd.writeTo(b, sa)
+}
+
+// WriteTo implements the PacketConn WriteTo method.
+func (c *TIPCPacketConn) WriteTo(b []byte, addr Addr) (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	a, ok := addr.(*TIPCAddr)
+	if !ok {
+		return 0, &OpError{Op: "write", Net: c.fd.net, Addr: addr, Err: syscall.EINVAL}
+	}
+	return c.WriteToTIPC(b, a)
+}
+
+// WriteMsgTIPC writes a message to addr via c, copying the payload
+// from b and the associated out-of-band data from oob.  It returns the
+// number of payload and out-of-band bytes written.  Addr must be nil
+// if c is connected.
+func (c *TIPCPacketConn) WriteMsgTIPC(b, oob []byte, addr *TIPCAddr) (n, oobn int, err error) {
+	if !c.ok() {
+		return 0, 0, syscall.EINVAL
+	}
+	if addr == nil {
+		return c.fd.writeMsg(b, oob, nil)
+	}
+	if c.fd.isConnected {
+		return 0, 0, &OpError{Op: "write", Net: c.fd.net, Addr: addr, Err: ErrWriteToConnected}
+	}
+	sa, err := addr.sockaddr(c.fd.family)
+	if err != nil {
+		return 0, 0, &OpError{Op: "write", Net: c.fd.net, Addr: addr, Err: err}
+	}
+	return c.fd.writeMsg(b, oob, sa)
+}
+
+// SetImportance sets the importance of the messages sent on c; see
+// TIPCConn.SetImportance.
+func (c *TIPCPacketConn) SetImportance(importance int) error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	return setTIPCImportance(c.fd, importance)
+}
+
+// SetSrcDroppable sets whether messages sent on c may be discarded
+// when congestion occurs instead of blocking the sender.
+func (c *TIPCPacketConn) SetSrcDroppable(droppable bool) error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	return setTIPCSrcDroppable(c.fd, droppable)
+}
+
+// SetDestDroppable sets whether messages sent on c are discarded,
+// rather than returned to the sender, when they cannot be delivered.
+func (c *TIPCPacketConn) SetDestDroppable(droppable bool) error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	return setTIPCDestDroppable(c.fd, droppable)
+}
+
+// NodeRecvQueueDepth returns the number of messages waiting in the
+// receive queues of all sockets on the local node.
+func (c *TIPCPacketConn) NodeRecvQueueDepth() (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	return tipcRecvQueueDepth(c.fd, syscall.TIPC_NODE_RECVQ_DEPTH)
+}
+
+// SockRecvQueueDepth returns the number of messages waiting in the
+// receive queue of c.
+func (c *TIPCPacketConn) SockRecvQueueDepth() (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	return tipcRecvQueueDepth(c.fd, syscall.TIPC_SOCK_RECVQ_DEPTH)
+}
+
+// Publish binds c to the additional name or name sequence addr, so
+// that messages sent to addr are delivered to c as well.  The scope
+// of addr limits the visibility of the publication.
+func (c *TIPCPacketConn) Publish(addr *TIPCAddr) error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	return c.names.publish(c.fd, addr)
+}
+
+// Withdraw unbinds c from the name or name sequence addr, which must
+// have been published before.
+func (c *TIPCPacketConn) Withdraw(addr *TIPCAddr) error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	return c.names.withdraw(c.fd, addr)
+}
+
+// Addrs returns the names and name sequences that c is currently
+// bound to.
+func (c *TIPCPacketConn) Addrs() []*TIPCAddr {
+	if !c.ok() {
+		return nil
+	}
+	return c.names.list()
+}
+
+func dialTIPCPacket(net string, laddr, raddr *TIPCAddr, deadline time.Time) (*TIPCPacketConn, error) {
+	fd, err := tipcSocket(net, laddr, raddr, "dial", deadline, nil)
+	if err != nil {
+		return nil, &OpError{Op: "dial", Net: net, Addr: raddr, Err: err}
+	}
+	c := newTIPCPacketConn(fd)
+	c.names.add(laddr)
+	return c, nil
+}
+
+// ListenTIPCPacket listens for incoming TIPC messages addressed to
+// the local address laddr.  Net must be "tipc-rdm" or "tipc-dgram".
+// If laddr is a name sequence, the socket receives the messages sent
+// to any name in the sequence, including messages multicast to an
+// overlapping sequence.
+// If laddr is nil, the socket is not bound to any name and can be
+// reached only through the port identity reported by LocalAddr.
+// The returned connection's ReadFrom and WriteTo methods can be used
+// to receive and send messages with per-message addressing.
+func ListenTIPCPacket(net string, laddr *TIPCAddr) (*TIPCPacketConn, error) {
+	return listenTIPCPacket(net, laddr, nil)
+}
+
+func listenTIPCPacket(net string, laddr *TIPCAddr, opts *tipcSockopts) (*TIPCPacketConn, error) {
+	switch net {
+	case "tipc-rdm", "tipc-dgram":
+	default:
+		return nil, &OpError{Op: "listen", Net: net, Addr: laddr, Err: UnknownNetworkError(net)}
+	}
+	fd, err := tipcSocket(net, laddr, nil, "listen", noDeadline, opts)
+	if err != nil {
+		return nil, &OpError{Op: "listen", Net: net, Addr: laddr, Err: err}
+	}
+	c := newTIPCPacketConn(fd)
+	c.names.add(laddr)
+	return c, nil
+}
+
+func (lc *TIPCListenConfig) apply(laddr *TIPCAddr) (*TIPCAddr, *tipcSockopts) {
+	if laddr != nil && lc.Scope != 0 {
+		a := *laddr
+		a.Scope = lc.Scope
+		laddr = &a
+	}
+	return laddr, &tipcSockopts{importance: lc.Importance}
+}
+
+// ListenTIPC acts like the ListenTIPC function but applies the
+// options of lc.
+func (lc *TIPCListenConfig) ListenTIPC(net string, laddr *TIPCAddr) (*TIPCListener, error) {
+	laddr, opts := lc.apply(laddr)
+	return listenTIPC(net, laddr, opts)
+}
+
+// ListenTIPCPacket acts like the ListenTIPCPacket function but
+// applies the options of lc.
+func (lc *TIPCListenConfig) ListenTIPCPacket(net string, laddr *TIPCAddr) (*TIPCPacketConn, error) {
+	laddr, opts := lc.apply(laddr)
+	return listenTIPCPacket(net, laddr, opts)
+}
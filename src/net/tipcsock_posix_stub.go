@@ -0,0 +1,18 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build darwin dragonfly freebsd nacl netbsd openbsd solaris windows
+
+package net
+
+import "syscall"
+
+// TIPC is only implemented on Linux; elsewhere no socket is ever
+// recognized as a TIPC socket.
+
+func tipcAddrFunc(family, sotype int) func(syscall.Sockaddr) Addr { return nil }
+
+func tipcSockaddrFamily(sa syscall.Sockaddr) int { return syscall.AF_UNSPEC }
+
+func tipcSotypeToNet(sotype int) string { return "" }
@@ -0,0 +1,240 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package net
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"time"
+)
+
+func errTIPCUnsupported(op string) error {
+	return &OpError{Op: op, Net: "tipc", Addr: nil, Err: syscall.EAFNOSUPPORT}
+}
+
+const tipcEventLen = 0
+
+func marshalTIPCSubscr(addr *TIPCAddr, filter uint32, timeout time.Duration) []byte {
+	return nil
+}
+
+func parseTIPCEvent(b []byte) (*TIPCEvent, error) {
+	return nil, errShortTIPCEvent
+}
+
+func (c *TIPCConn) ReadFrom(r io.Reader) (int64, error) {
+	return 0, errTIPCUnsupported("read")
+}
+
+func (c *TIPCConn) ReadMsgTIPC(b, oob []byte) (n, oobn, flags int, addr *TIPCAddr, err error) {
+	return 0, 0, 0, nil, errTIPCUnsupported("read")
+}
+
+func (c *TIPCConn) WriteMsgTIPC(b, oob []byte) (n, oobn int, err error) {
+	return 0, 0, errTIPCUnsupported("write")
+}
+
+func (c *TIPCConn) CloseRead() error {
+	return errTIPCUnsupported("close")
+}
+
+func (c *TIPCConn) CloseWrite() error {
+	return errTIPCUnsupported("close")
+}
+
+func (c *TIPCConn) SetLinger(sec int) error {
+	return errTIPCUnsupported("setsockopt")
+}
+
+func (c *TIPCConn) SetImportance(importance int) error {
+	return errTIPCUnsupported("setsockopt")
+}
+
+func (c *TIPCConn) SetConnTimeout(d time.Duration) error {
+	return errTIPCUnsupported("setsockopt")
+}
+
+func (c *TIPCConn) SetSrcDroppable(droppable bool) error {
+	return errTIPCUnsupported("setsockopt")
+}
+
+func (c *TIPCConn) SetDestDroppable(droppable bool) error {
+	return errTIPCUnsupported("setsockopt")
+}
+
+func (c *TIPCConn) NodeRecvQueueDepth() (int, error) {
+	return 0, errTIPCUnsupported("getsockopt")
+}
+
+func (c *TIPCConn) SockRecvQueueDepth() (int, error) {
+	return 0, errTIPCUnsupported("getsockopt")
+}
+
+func DialTIPC(net string, laddr, raddr *TIPCAddr) (*TIPCConn, error) {
+	return nil, &OpError{Op: "dial", Net: net, Addr: raddr.toAddr(), Err: syscall.EAFNOSUPPORT}
+}
+
+func dialTIPC(net string, laddr, raddr *TIPCAddr, deadline time.Time) (*TIPCConn, error) {
+	return nil, &OpError{Op: "dial", Net: net, Addr: raddr.toAddr(), Err: syscall.EAFNOSUPPORT}
+}
+
+func (l *TIPCListener) AcceptTIPC() (*TIPCConn, error) {
+	return nil, errTIPCUnsupported("accept")
+}
+
+func (l *TIPCListener) Accept() (c Conn, err error) {
+	return nil, errTIPCUnsupported("accept")
+}
+
+func (l *TIPCListener) Close() error {
+	return errTIPCUnsupported("close")
+}
+
+func (l *TIPCListener) Addr() Addr {
+	return nil
+}
+
+func (l *TIPCListener) SetDeadline(t time.Time) error {
+	return errTIPCUnsupported("setdeadline")
+}
+
+func (l *TIPCListener) NodeRecvQueueDepth() (int, error) {
+	return 0, errTIPCUnsupported("getsockopt")
+}
+
+func (l *TIPCListener) SockRecvQueueDepth() (int, error) {
+	return 0, errTIPCUnsupported("getsockopt")
+}
+
+func (l *TIPCListener) File() (f *os.File, err error) {
+	return nil, errTIPCUnsupported("dup")
+}
+
+func (l *TIPCListener) Publish(addr *TIPCAddr) error {
+	return errTIPCUnsupported("publish")
+}
+
+func (l *TIPCListener) Withdraw(addr *TIPCAddr) error {
+	return errTIPCUnsupported("withdraw")
+}
+
+func (l *TIPCListener) Addrs() []*TIPCAddr {
+	return nil
+}
+
+func ListenTIPC(net string, laddr *TIPCAddr) (*TIPCListener, error) {
+	return nil, &OpError{Op: "listen", Net: net, Addr: laddr.toAddr(), Err: syscall.EAFNOSUPPORT}
+}
+
+func (c *TIPCPacketConn) ReadFromTIPC(b []byte) (n int, addr *TIPCAddr, err error) {
+	return 0, nil, errTIPCUnsupported("read")
+}
+
+func (c *TIPCPacketConn) ReadFrom(b []byte) (int, Addr, error) {
+	return 0, nil, errTIPCUnsupported("read")
+}
+
+func (c *TIPCPacketConn) ReadMsgTIPC(b, oob []byte) (n, oobn, flags int, addr *TIPCAddr, err error) {
+	return 0, 0, 0, nil, errTIPCUnsupported("read")
+}
+
+func (c *TIPCPacketConn) WriteToTIPC(b []byte, addr *TIPCAddr) (int, error) {
+	return 0, errTIPCUnsupported("write")
+}
+
+func (c *TIPCPacketConn) WriteTo(b []byte, addr Addr) (int, error) {
+	return 0, errTIPCUnsupported("write")
+}
+
+func (c *TIPCPacketConn) WriteMsgTIPC(b, oob []byte, addr *TIPCAddr) (n, oobn int, err error) {
+	return 0, 0, errTIPCUnsupported("write")
+}
+
+func (c *TIPCPacketConn) SetImportance(importance int) error {
+	return errTIPCUnsupported("setsockopt")
+}
+
+func (c *TIPCPacketConn) SetSrcDroppable(droppable bool) error {
+	return errTIPCUnsupported("setsockopt")
+}
+
+func (c *TIPCPacketConn) SetDestDroppable(droppable bool) error {
+	return errTIPCUnsupported("setsockopt")
+}
+
+func (c *TIPCPacketConn) NodeRecvQueueDepth() (int, error) {
+	return 0, errTIPCUnsupported("getsockopt")
+}
+
+func (c *TIPCPacketConn) SockRecvQueueDepth() (int, error) {
+	return 0, errTIPCUnsupported("getsockopt")
+}
+
+func (c *TIPCPacketConn) Publish(addr *TIPCAddr) error {
+	return errTIPCUnsupported("publish")
+}
+
+func (c *TIPCPacketConn) Withdraw(addr *TIPCAddr) error {
+	return errTIPCUnsupported("withdraw")
+}
+
+func (c *TIPCPacketConn) Addrs() []*TIPCAddr {
+	return nil
+}
+
+func dialTIPCPacket(net string, laddr, raddr *TIPCAddr, deadline time.Time) (*TIPCPacketConn, error) {
+	return nil, &OpError{Op: "dial", Net: net, Addr: raddr.toAddr(), Err: syscall.EAFNOSUPPORT}
+}
+
+func ListenTIPCPacket(net string, laddr *TIPCAddr) (*TIPCPacketConn, error) {
+	return nil, &OpError{Op: "listen", Net: net, Addr: laddr.toAddr(), Err: syscall.EAFNOSUPPORT}
+}
+
+func (lc *TIPCListenConfig) ListenTIPC(net string, laddr *TIPCAddr) (*TIPCListener, error) {
+	return nil, &OpError{Op: "listen", Net: net, Addr: laddr.toAddr(), Err: syscall.EAFNOSUPPORT}
+}
+
+func (lc *TIPCListenConfig) ListenTIPCPacket(net string, laddr *TIPCAddr) (*TIPCPacketConn, error) {
+	return nil, &OpError{Op: "listen", Net: net, Addr: laddr.toAddr(), Err: syscall.EAFNOSUPPORT}
+}
+
+func ListenTIPCGroup(net string) (*TIPCGroupConn, error) {
+	return nil, &OpError{Op: "listen", Net: net, Addr: nil, Err: syscall.EAFNOSUPPORT}
+}
+
+func (c *TIPCGroupConn) Join(addr *TIPCAddr, flags int) error {
+	return errTIPCUnsupported("join")
+}
+
+func (c *TIPCGroupConn) Leave() error {
+	return errTIPCUnsupported("leave")
+}
+
+func (c *TIPCGroupConn) Unicast(b []byte, addr *TIPCAddr) (int, error) {
+	return 0, errTIPCUnsupported("write")
+}
+
+func (c *TIPCGroupConn) Anycast(b []byte, instance uint32) (int, error) {
+	return 0, errTIPCUnsupported("write")
+}
+
+func (c *TIPCGroupConn) Multicast(b []byte, lower, upper uint32) (int, error) {
+	return 0, errTIPCUnsupported("write")
+}
+
+func (c *TIPCGroupConn) Broadcast(b []byte) (int, error) {
+	return 0, errTIPCUnsupported("write")
+}
+
+func (c *TIPCGroupConn) ReadFromGroup(b []byte) (n int, from, member *TIPCAddr, event uint32, err error) {
+	return 0, nil, nil, 0, errTIPCUnsupported("read")
+}
+
+func LookupTIPC(service, lower, upper uint32) ([]TIPCPublication, error) {
+	return nil, &OpError{Op: "lookup", Net: "tipc", Addr: &TIPCAddr{AddrType: TIPC_ADDR_NAMESEQ, Service: service, Instance: lower, Domain: upper}, Err: syscall.EAFNOSUPPORT}
+}
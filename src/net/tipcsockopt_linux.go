@@ -0,0 +1,49 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// setTIPCSockoptInt sets the SOL_TIPC option opt of fd to v.
+func setTIPCSockoptInt(fd *netFD, opt, v int) error {
+	if err := fd.incref(); err != nil {
+		return err
+	}
+	defer fd.decref()
+	return os.NewSyscallError("setsockopt", syscall.SetsockoptInt(fd.sysfd, syscall.SOL_TIPC, opt, v))
+}
+
+func setTIPCImportance(fd *netFD, importance int) error {
+	return setTIPCSockoptInt(fd, syscall.TIPC_IMPORTANCE, importance)
+}
+
+func setTIPCConnTimeout(fd *netFD, d time.Duration) error {
+	ms := int((d + time.Millisecond - 1) / time.Millisecond)
+	return setTIPCSockoptInt(fd, syscall.TIPC_CONN_TIMEOUT, ms)
+}
+
+func setTIPCSrcDroppable(fd *netFD, droppable bool) error {
+	return setTIPCSockoptInt(fd, syscall.TIPC_SRC_DROPPABLE, boolint(droppable))
+}
+
+func setTIPCDestDroppable(fd *netFD, droppable bool) error {
+	return setTIPCSockoptInt(fd, syscall.TIPC_DEST_DROPPABLE, boolint(droppable))
+}
+
+func tipcRecvQueueDepth(fd *netFD, opt int) (int, error) {
+	if err := fd.incref(); err != nil {
+		return 0, err
+	}
+	defer fd.decref()
+	v, err := syscall.GetsockoptInt(fd.sysfd, syscall.SOL_TIPC, opt)
+	if err != nil {
+		return 0, os.NewSyscallError("getsockopt", err)
+	}
+	return v, nil
+}
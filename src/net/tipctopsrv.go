@@ -0,0 +1,202 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var errShortTIPCEvent = errors.New("short TIPC topology event")
+
+// TIPCEvent is a name table event reported by the TIPC topology
+// service.
+type TIPCEvent struct {
+	Type  uint32    // TIPC_PUBLISHED, TIPC_WITHDRAWN or TIPC_SUBSCR_TIMEOUT
+	Lower uint32    // lower bound of the matching instances
+	Upper uint32    // upper bound of the matching instances
+	Ref   uint32    // port reference of the publishing socket
+	Node  uint32    // node of the publishing socket
+	Addr  *TIPCAddr // name sequence of the subscription
+}
+
+// TIPCTopologySubscriber is a client of the TIPC topology service.
+// It subscribes to publications and withdrawals of name sequences
+// anywhere in the cluster and delivers the resulting events on the
+// channel returned by Events.
+type TIPCTopologySubscriber struct {
+	c      *TIPCConn
+	events chan *TIPCEvent
+	done   chan struct{}
+	once   sync.Once
+
+	mu  sync.Mutex
+	err error
+}
+
+// DialTIPCTopology connects to the TIPC topology service.
+func DialTIPCTopology() (*TIPCTopologySubscriber, error) {
+	c, err := DialTIPC("tipc-seqpacket", nil, &TIPCAddr{AddrType: TIPC_ADDR_NAME, Service: TIPC_TOP_SRV, Instance: TIPC_TOP_SRV})
+	if err != nil {
+		return nil, err
+	}
+	s := &TIPCTopologySubscriber{
+		c:      c,
+		events: make(chan *TIPCEvent),
+		done:   make(chan struct{}),
+	}
+	go s.readLoop()
+	return s, nil
+}
+
+func (s *TIPCTopologySubscriber) readLoop() {
+	defer close(s.events)
+	b := make([]byte, tipcEventLen)
+	for {
+		n, err := s.c.Read(b)
+		if err == nil {
+			var ev *TIPCEvent
+			if ev, err = parseTIPCEvent(b[:n]); err == nil {
+				select {
+				case s.events <- ev:
+					continue
+				case <-s.done:
+					return
+				}
+			}
+		}
+		select {
+		case <-s.done:
+		default:
+			s.mu.Lock()
+			s.err = err
+			s.mu.Unlock()
+		}
+		return
+	}
+}
+
+// Subscribe asks the topology service to report publications and
+// withdrawals of names overlapping the name or name sequence addr.
+// Filter is TIPC_SUB_PORTS to receive an event for every matching
+// publication or TIPC_SUB_SERVICE to receive events only when the
+// first publication appears or the last one goes away.  The
+// subscription expires with a TIPC_SUBSCR_TIMEOUT event after
+// timeout, which is rounded up to a whole number of milliseconds and
+// capped at about 49 days; a zero timeout means the subscription never
+// expires.
+func (s *TIPCTopologySubscriber) Subscribe(addr *TIPCAddr, filter uint32, timeout time.Duration) error {
+	if addr == nil {
+		return &OpError{Op: "subscribe", Net: "tipc", Addr: nil, Err: errMissingAddress}
+	}
+	_, err := s.c.Write(marshalTIPCSubscr(addr, filter, timeout))
+	return err
+}
+
+// Unsubscribe cancels a subscription previously made with Subscribe
+// using the same addr, filter and timeout.
+func (s *TIPCTopologySubscriber) Unsubscribe(addr *TIPCAddr, filter uint32, timeout time.Duration) error {
+	return s.Subscribe(addr, filter|TIPC_SUB_CANCEL, timeout)
+}
+
+// Events returns the channel on which topology events are delivered.
+// The channel is closed when the subscriber is closed or the
+// connection to the topology service fails; see Err.
+func (s *TIPCTopologySubscriber) Events() <-chan *TIPCEvent { return s.events }
+
+// Err returns the error that terminated the delivery of events, if
+// any.
+func (s *TIPCTopologySubscriber) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close closes the connection to the topology service, which cancels
+// all its subscriptions.
+func (s *TIPCTopologySubscriber) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return s.c.Close()
+}
+
+// TIPCNodeEvent reports that a node joined or left the TIPC cluster.
+type TIPCNodeEvent struct {
+	Node TIPCNodeAddr
+	Up   bool // whether the node became reachable
+}
+
+// TIPCNodeWatcher reports changes in the membership of the TIPC
+// cluster as seen from the local node.
+type TIPCNodeWatcher struct {
+	s      *TIPCTopologySubscriber
+	events chan TIPCNodeEvent
+}
+
+// WatchTIPCNodes subscribes to the node state names that the
+// topology service publishes for every node reachable from the
+// local node.  A TIPCNodeEvent with Up set is delivered for every
+// node currently reachable and for every node that becomes
+// reachable later; one with Up clear when a node is lost.
+func WatchTIPCNodes() (*TIPCNodeWatcher, error) {
+	s, err := DialTIPCTopology()
+	if err != nil {
+		return nil, err
+	}
+	all := &TIPCAddr{AddrType: TIPC_ADDR_NAMESEQ, Service: TIPC_NODE_STATE, Instance: 0, Domain: ^uint32(0)}
+	if err := s.Subscribe(all, TIPC_SUB_PORTS, 0); err != nil {
+		s.Close()
+		return nil, err
+	}
+	w := &TIPCNodeWatcher{s: s, events: make(chan TIPCNodeEvent)}
+	go w.loop()
+	return w, nil
+}
+
+func (w *TIPCNodeWatcher) loop() {
+	defer close(w.events)
+	for ev := range w.s.Events() {
+		var up bool
+		switch ev.Type {
+		case TIPC_PUBLISHED:
+			up = true
+		case TIPC_WITHDRAWN:
+		default:
+			continue
+		}
+		// The instance of a node state name is the node address.
+		select {
+		case w.events <- TIPCNodeEvent{Node: TIPCNodeAddr(ev.Lower), Up: up}:
+		case <-w.s.done:
+			return
+		}
+	}
+}
+
+// Events returns the channel on which node events are delivered.  The
+// channel is closed when the watcher is closed or the connection to
+// the topology service fails; see Err.
+func (w *TIPCNodeWatcher) Events() <-chan TIPCNodeEvent { return w.events }
+
+// Err returns the error that terminated the delivery of events, if
+// any.
+func (w *TIPCNodeWatcher) Err() error { return w.s.Err() }
+
+// Close stops the delivery of events.
+func (w *TIPCNodeWatcher) Close() error { return w.s.Close() }
+
+// TIPCLocalNode returns the network address of the local TIPC node.
+func TIPCLocalNode() (TIPCNodeAddr, error) {
+	c, err := ListenTIPCPacket("tipc-rdm", nil)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	a, ok := c.LocalAddr().(*TIPCAddr)
+	if !ok {
+		return 0, &OpError{Op: "getsockname", Net: "tipc-rdm", Addr: nil, Err: errNoSuitableAddress}
+	}
+	return TIPCNodeAddr(a.Node), nil
+}
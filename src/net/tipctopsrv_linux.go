@@ -0,0 +1,59 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const tipcEventLen = syscall.SizeofTIPCEvent
+
+// marshalTIPCSubscr encodes a struct tipc_subscr for the name
+// sequence of addr.  It is encoded in host byte order; the topology
+// service tells the byte order of a subscriber from the filter and
+// answers in the same order.
+func marshalTIPCSubscr(addr *TIPCAddr, filter uint32, timeout time.Duration) []byte {
+	b := make([]byte, syscall.SizeofTIPCSubscr)
+	s := (*syscall.TIPCSubscr)(unsafe.Pointer(&b[0]))
+	s.Seq.Type, s.Seq.Lower, s.Seq.Upper = addr.nameSeq()
+	switch {
+	case timeout <= 0:
+		s.Timeout = TIPC_WAIT_FOREVER
+	case timeout >= (TIPC_WAIT_FOREVER-1)*time.Millisecond:
+		// Any longer timeout would read as TIPC_WAIT_FOREVER or
+		// wrap around.
+		s.Timeout = TIPC_WAIT_FOREVER - 1
+	default:
+		// Round up so that a short timeout does not read as
+		// zero and expire at once.
+		s.Timeout = uint32((timeout + time.Millisecond - 1) / time.Millisecond)
+	}
+	s.Filter = filter
+	return b
+}
+
+// parseTIPCEvent decodes a struct tipc_event received from the
+// topology service.
+func parseTIPCEvent(b []byte) (*TIPCEvent, error) {
+	if len(b) < syscall.SizeofTIPCEvent {
+		return nil, errShortTIPCEvent
+	}
+	e := (*syscall.TIPCEvent)(unsafe.Pointer(&b[0]))
+	return &TIPCEvent{
+		Type:  e.Event,
+		Lower: e.Found_lower,
+		Upper: e.Found_upper,
+		Ref:   e.Port.Ref,
+		Node:  e.Port.Node,
+		Addr: &TIPCAddr{
+			AddrType: TIPC_ADDR_NAMESEQ,
+			Service:  e.S.Seq.Type,
+			Instance: e.S.Seq.Lower,
+			Domain:   e.S.Seq.Upper,
+		},
+	}, nil
+}
@@ -0,0 +1,25 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package syscall
+
+import "unsafe"
+
+// RawSockaddrTIPCOf returns the struct sockaddr_tipc that sa is
+// passed to the kernel as.
+func RawSockaddrTIPCOf(sa *SockaddrTIPC) (*RawSockaddrTIPC, error) {
+	p, _, err := sa.sockaddr()
+	if err != nil {
+		return nil, err
+	}
+	return (*RawSockaddrTIPC)(p), nil
+}
+
+// SockaddrTIPCFromRaw decodes a struct sockaddr_tipc returned by the
+// kernel.
+func SockaddrTIPCFromRaw(raw *RawSockaddrTIPC) (Sockaddr, error) {
+	var rsa RawSockaddrAny
+	*(*RawSockaddrTIPC)(unsafe.Pointer(&rsa)) = *raw
+	return anyToSockaddr(&rsa)
+}
@@ -115,6 +115,7 @@ includes_Linux='
 #include <linux/sched.h>
 #include <linux/wait.h>
 #include <linux/icmpv6.h>
+#include <linux/tipc.h>
 #include <net/route.h>
 #include <termios.h>
 
@@ -280,6 +281,9 @@ ccflags="$@"
 		$2 ~ /^LINUX_REBOOT_MAGIC[12]$/ ||
 		$2 !~ "NLA_TYPE_MASK" &&
 		$2 ~ /^(NETLINK|NLM|NLMSG|NLA|IFA|IFAN|RT|RTCF|RTN|RTPROT|RTNH|ARPHRD|ETH_P)_/ ||
+		$2 ~ /^TIPC_([A-Z]+_IMPORTANCE|IMPORTANCE|(SRC|DEST)_DROPPABLE|CONN_(TIMEOUT|SHUTDOWN)|(NODE|SOCK)_RECVQ_DEPTH)$/ ||
+		$2 ~ /^TIPC_(OK|ERR_[A-Z_]+|ERRINFO|RETDATA|DESTNAME|ADDR_[A-Z]+)$/ ||
+		$2 ~ /^TIPC_GROUP_[A-Z_]+$/ ||
 		$2 ~ /^SIOC/ ||
 		$2 ~ /^TIOC/ ||
 		$2 !~ "RTF_BITS" &&
@@ -34,3 +34,50 @@ func ParseUnixCredentials(m *SocketControlMessage) (*Ucred, error) {
 	ucred := *(*Ucred)(unsafe.Pointer(&m.Data[0]))
 	return &ucred, nil
 }
+
+// TIPCMsgInfo holds the TIPC ancillary data of a received message.
+type TIPCMsgInfo struct {
+	// ErrorCode is TIPC_OK for ordinary messages. For messages
+	// returned to their sender it is the reason the message could
+	// not be delivered, such as TIPC_ERR_NO_NAME.
+	ErrorCode int
+
+	// ReturnedData is the payload of a returned message. It
+	// shares storage with the parsed control messages.
+	ReturnedData []byte
+
+	// DestName is the name or name sequence the sender addressed
+	// the message to, or nil if the message was sent to a port
+	// identity.
+	DestName *TIPCNameSeq
+}
+
+// ParseTIPCMsgInfo decodes the TIPC_ERRINFO, TIPC_RETDATA and
+// TIPC_DESTNAME socket control messages in msgs. Control messages of
+// other levels and types are ignored.
+func ParseTIPCMsgInfo(msgs []SocketControlMessage) (*TIPCMsgInfo, error) {
+	info := &TIPCMsgInfo{ErrorCode: TIPC_OK}
+	for _, m := range msgs {
+		if m.Header.Level != SOL_TIPC {
+			continue
+		}
+		switch m.Header.Type {
+		case TIPC_ERRINFO:
+			// The error code is followed by the length of
+			// the returned data.
+			if len(m.Data) < 8 {
+				return nil, EINVAL
+			}
+			info.ErrorCode = int(*(*uint32)(unsafe.Pointer(&m.Data[0])))
+		case TIPC_RETDATA:
+			info.ReturnedData = m.Data
+		case TIPC_DESTNAME:
+			if len(m.Data) < SizeofTIPCNameSeq {
+				return nil, EINVAL
+			}
+			seq := *(*TIPCNameSeq)(unsafe.Pointer(&m.Data[0]))
+			info.DestName = &seq
+		}
+	}
+	return info, nil
+}
@@ -285,14 +285,36 @@ func (sa *SockaddrUnix) sockaddr() (unsafe.Pointer, _Socklen, error) {
 	return unsafe.Pointer(&sa.raw), sl, nil
 }
 
+// SockaddrTIPC is a TIPC socket address.  AddrType selects which of
+// ID, Name and NameSeq holds the address, like the union in struct
+// sockaddr_tipc; the fields are in host byte order.
+type SockaddrTIPC struct {
+	AddrType uint8 // TIPC_ADDR_ID, TIPC_ADDR_NAME or TIPC_ADDR_NAMESEQ
+	Scope    int8
+	ID       TIPCPortID    // socket identity, for TIPC_ADDR_ID
+	Name     TIPCName      // service name, for TIPC_ADDR_NAME
+	Domain   uint32        // lookup domain of Name
+	NameSeq  TIPCNameSeq   // service range, for TIPC_ADDR_NAMESEQ
+	Member   *SockaddrTIPC // sender's group member name, received on TIPC group sockets only
+	raw      RawSockaddrTIPC
+}
+
 func (sa *SockaddrTIPC) sockaddr() (unsafe.Pointer, _Socklen, error) {
 	sa.raw.Family = AF_TIPC
 	sa.raw.AddrType = sa.AddrType
 	sa.raw.Scope = sa.Scope
-	for i := 0; i < len(sa.Addr); i++ {
-		sa.raw.Addr[i] = sa.Addr[i]
+	sa.raw.Addr = [12]byte{}
+	switch sa.AddrType {
+	case TIPC_ADDR_ID:
+		copy(sa.raw.Addr[:], (*[SizeofTIPCPortID]byte)(unsafe.Pointer(&sa.ID))[:])
+	case TIPC_ADDR_NAME:
+		copy(sa.raw.Addr[:], (*[SizeofTIPCName]byte)(unsafe.Pointer(&sa.Name))[:])
+		copy(sa.raw.Addr[SizeofTIPCName:], (*[4]byte)(unsafe.Pointer(&sa.Domain))[:])
+	case TIPC_ADDR_NAMESEQ:
+		copy(sa.raw.Addr[:], (*[SizeofTIPCNameSeq]byte)(unsafe.Pointer(&sa.NameSeq))[:])
+	default:
+		return nil, 0, EINVAL
 	}
-
 	return unsafe.Pointer(&sa.raw), SizeofSockaddrTIPC, nil
 }
 
@@ -407,20 +429,36 @@ func anyToSockaddr(rsa *RawSockaddrAny) (Sockaddr, error) {
 			sa.Addr[i] = pp.Addr[i]
 		}
 		return sa, nil
-    case AF_TIPC:
-        // TIPC SANDEEP
+	case AF_TIPC:
 		pp := (*RawSockaddrTIPC)(unsafe.Pointer(rsa))
-		sa := new(SockaddrTIPC)
-        sa.AddrType = pp.AddrType
-        sa.Scope = pp.Scope
-		for i := 0; i < len(sa.Addr); i++ {
-			sa.Addr[i] = pp.Addr[i]
+		sa := rawToSockaddrTIPC(pp)
+		// On communication group sockets the kernel appends the
+		// service address of the sending member.
+		pm := (*RawSockaddrTIPC)(unsafe.Pointer(uintptr(unsafe.Pointer(rsa)) + SizeofSockaddrTIPC))
+		if pm.Family == AF_TIPC {
+			sa.Member = rawToSockaddrTIPC(pm)
 		}
-        return sa, nil
+		return sa, nil
 	}
 	return nil, EAFNOSUPPORT
 }
 
+func rawToSockaddrTIPC(pp *RawSockaddrTIPC) *SockaddrTIPC {
+	sa := new(SockaddrTIPC)
+	sa.AddrType = pp.AddrType
+	sa.Scope = pp.Scope
+	switch pp.AddrType {
+	case TIPC_ADDR_ID:
+		copy((*[SizeofTIPCPortID]byte)(unsafe.Pointer(&sa.ID))[:], pp.Addr[:])
+	case TIPC_ADDR_NAME:
+		copy((*[SizeofTIPCName]byte)(unsafe.Pointer(&sa.Name))[:], pp.Addr[:])
+		copy((*[4]byte)(unsafe.Pointer(&sa.Domain))[:], pp.Addr[SizeofTIPCName:])
+	case TIPC_ADDR_NAMESEQ:
+		copy((*[SizeofTIPCNameSeq]byte)(unsafe.Pointer(&sa.NameSeq))[:], pp.Addr[:])
+	}
+	return sa
+}
+
 func Accept(fd int) (nfd int, sa Sockaddr, err error) {
 	var rsa RawSockaddrAny
 	var len _Socklen = SizeofSockaddrAny
@@ -515,6 +553,16 @@ func SetsockoptIPMreqn(fd, level, opt int, mreq *IPMreqn) (err error) {
 	return setsockopt(fd, level, opt, unsafe.Pointer(mreq), unsafe.Sizeof(*mreq))
 }
 
+// SetsockoptTIPCGroupReq sets a TIPC communication group option such
+// as TIPC_GROUP_JOIN.  Options that take no value, such as
+// TIPC_GROUP_LEAVE, are set with a nil req.
+func SetsockoptTIPCGroupReq(fd, level, opt int, req *TIPCGroupReq) (err error) {
+	if req == nil {
+		return setsockopt(fd, level, opt, nil, 0)
+	}
+	return setsockopt(fd, level, opt, unsafe.Pointer(req), SizeofTIPCGroupReq)
+}
+
 func Recvmsg(fd int, p, oob []byte, flags int) (n, oobn int, recvflags int, from Sockaddr, err error) {
 	var msg Msghdr
 	var rsa RawSockaddrAny
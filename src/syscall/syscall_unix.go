@@ -182,13 +182,6 @@ type SockaddrUnix struct {
 	raw  RawSockaddrUnix
 }
 
-type SockaddrTIPC struct {
-    AddrType uint8
-    Scope int8
-    Addr [12]byte
-	raw    RawSockaddrTIPC
-}
-
 func Bind(fd int, sa Sockaddr) (err error) {
 	ptr, n, err := sa.sockaddr()
 	if err != nil {
@@ -0,0 +1,101 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package syscall_test
+
+import (
+	"bytes"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// tipcCmsg returns a SOL_TIPC control message of type typ carrying
+// data.
+func tipcCmsg(typ int32, data []byte) []byte {
+	b := make([]byte, syscall.CmsgSpace(len(data)))
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&b[0]))
+	h.Level = syscall.SOL_TIPC
+	h.Type = typ
+	h.SetLen(syscall.CmsgLen(len(data)))
+	copy(b[syscall.CmsgLen(0):], data)
+	return b
+}
+
+// tipcWords returns vs encoded in host byte order.
+func tipcWords(vs ...uint32) []byte {
+	b := make([]byte, 4*len(vs))
+	for i, v := range vs {
+		*(*uint32)(unsafe.Pointer(&b[4*i])) = v
+	}
+	return b
+}
+
+func TestParseTIPCMsgInfo(t *testing.T) {
+	ret := []byte("RETURNED TIPC MESSAGE")
+	var oob []byte
+	oob = append(oob, tipcCmsg(syscall.TIPC_ERRINFO, tipcWords(syscall.TIPC_ERR_NO_NAME, uint32(len(ret))))...)
+	oob = append(oob, tipcCmsg(syscall.TIPC_RETDATA, ret)...)
+	oob = append(oob, tipcCmsg(syscall.TIPC_DESTNAME, tipcWords(18888, 10, 20))...)
+
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		t.Fatalf("ParseSocketControlMessage failed: %v", err)
+	}
+	info, err := syscall.ParseTIPCMsgInfo(msgs)
+	if err != nil {
+		t.Fatalf("ParseTIPCMsgInfo failed: %v", err)
+	}
+	if info.ErrorCode != syscall.TIPC_ERR_NO_NAME {
+		t.Errorf("ErrorCode = %d; expected %d", info.ErrorCode, syscall.TIPC_ERR_NO_NAME)
+	}
+	if !bytes.Equal(info.ReturnedData, ret) {
+		t.Errorf("ReturnedData = %q; expected %q", info.ReturnedData, ret)
+	}
+	if info.DestName == nil || *info.DestName != (syscall.TIPCNameSeq{Type: 18888, Lower: 10, Upper: 20}) {
+		t.Errorf("DestName = %+v", info.DestName)
+	}
+
+	msgs, err = syscall.ParseSocketControlMessage(tipcCmsg(syscall.TIPC_DESTNAME, tipcWords(18888, 10)))
+	if err != nil {
+		t.Fatalf("ParseSocketControlMessage failed: %v", err)
+	}
+	if _, err := syscall.ParseTIPCMsgInfo(msgs); err == nil {
+		t.Error("ParseTIPCMsgInfo accepted a short TIPC_DESTNAME")
+	}
+}
+
+func TestSockaddrTIPC(t *testing.T) {
+	for _, tt := range []struct {
+		sa    syscall.SockaddrTIPC
+		words []byte
+	}{
+		{syscall.SockaddrTIPC{AddrType: syscall.TIPC_ADDR_ID, ID: syscall.TIPCPortID{Ref: 0x12345678, Node: 0x01001001}}, tipcWords(0x12345678, 0x01001001, 0)},
+		{syscall.SockaddrTIPC{AddrType: syscall.TIPC_ADDR_NAME, Scope: 2, Name: syscall.TIPCName{Type: 18888, Instance: 17}, Domain: 0x01001000}, tipcWords(18888, 17, 0x01001000)},
+		{syscall.SockaddrTIPC{AddrType: syscall.TIPC_ADDR_NAMESEQ, Scope: 3, NameSeq: syscall.TIPCNameSeq{Type: 18888, Lower: 10, Upper: 20}}, tipcWords(18888, 10, 20)},
+	} {
+		sa := tt.sa
+		raw, err := syscall.RawSockaddrTIPCOf(&sa)
+		if err != nil {
+			t.Fatalf("%+v: %v", tt.sa, err)
+		}
+		if raw.Family != syscall.AF_TIPC || raw.AddrType != tt.sa.AddrType || raw.Scope != tt.sa.Scope || !bytes.Equal(raw.Addr[:], tt.words) {
+			t.Errorf("%+v: got %+v", tt.sa, raw)
+			continue
+		}
+		got, err := syscall.SockaddrTIPCFromRaw(raw)
+		if err != nil {
+			t.Fatalf("%+v: %v", tt.sa, err)
+		}
+		if sa, ok := got.(*syscall.SockaddrTIPC); !ok || sa.AddrType != tt.sa.AddrType || sa.Scope != tt.sa.Scope || sa.ID != tt.sa.ID || sa.Name != tt.sa.Name || sa.Domain != tt.sa.Domain || sa.NameSeq != tt.sa.NameSeq || sa.Member != nil {
+			t.Errorf("%+v: got %+v", tt.sa, got)
+		}
+	}
+
+	if _, err := syscall.RawSockaddrTIPCOf(&syscall.SockaddrTIPC{AddrType: 0}); err != syscall.EINVAL {
+		t.Errorf("got %v for an unknown address type; expected EINVAL", err)
+	}
+}
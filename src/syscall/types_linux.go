@@ -68,7 +68,7 @@ union sockaddr_all {
 	struct sockaddr_un s4;
 	struct sockaddr_ll s5;
 	struct sockaddr_nl s6;
-	struct sockaddr_tipc s7; 
+	struct sockaddr_tipc s7;
 };
 
 struct sockaddr_any {
@@ -170,7 +170,7 @@ type RawSockaddrInet6 C.struct_sockaddr_in6
 
 type RawSockaddrUnix C.struct_my_sockaddr_un
 
-type RawSockaddrTIPC C.struct_sockaddr_tipc 
+type RawSockaddrTIPC C.struct_sockaddr_tipc
 
 type RawSockaddrLinklayer C.struct_sockaddr_ll
 
@@ -190,6 +190,18 @@ type IPMreq C.struct_ip_mreq
 
 type IPMreqn C.struct_ip_mreqn
 
+type TIPCGroupReq C.struct_tipc_group_req
+
+type TIPCPortID C.struct_tipc_portid
+
+type TIPCName C.struct_tipc_name
+
+type TIPCNameSeq C.struct_tipc_name_seq
+
+type TIPCSubscr C.struct_tipc_subscr
+
+type TIPCEvent C.struct_tipc_event
+
 type IPv6Mreq C.struct_ipv6_mreq
 
 type Msghdr C.struct_msghdr
@@ -213,12 +225,18 @@ const (
 	SizeofSockaddrInet6     = C.sizeof_struct_sockaddr_in6
 	SizeofSockaddrAny       = C.sizeof_struct_sockaddr_any
 	SizeofSockaddrUnix      = C.sizeof_struct_sockaddr_un
-	SizeofSockaddrTIPC      = C.sizeof_struct_sockaddr_tipc 
+	SizeofSockaddrTIPC      = C.sizeof_struct_sockaddr_tipc
 	SizeofSockaddrLinklayer = C.sizeof_struct_sockaddr_ll
 	SizeofSockaddrNetlink   = C.sizeof_struct_sockaddr_nl
 	SizeofLinger            = C.sizeof_struct_linger
 	SizeofIPMreq            = C.sizeof_struct_ip_mreq
 	SizeofIPMreqn           = C.sizeof_struct_ip_mreqn
+	SizeofTIPCGroupReq      = C.sizeof_struct_tipc_group_req
+	SizeofTIPCPortID        = C.sizeof_struct_tipc_portid
+	SizeofTIPCName          = C.sizeof_struct_tipc_name
+	SizeofTIPCNameSeq       = C.sizeof_struct_tipc_name_seq
+	SizeofTIPCSubscr        = C.sizeof_struct_tipc_subscr
+	SizeofTIPCEvent         = C.sizeof_struct_tipc_event
 	SizeofIPv6Mreq          = C.sizeof_struct_ipv6_mreq
 	SizeofMsghdr            = C.sizeof_struct_msghdr
 	SizeofCmsghdr           = C.sizeof_struct_cmsghdr
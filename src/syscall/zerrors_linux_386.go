@@ -1016,6 +1016,7 @@ const (
 	SOL_RAW                          = 0xff
 	SOL_SOCKET                       = 0x1
 	SOL_TCP                          = 0x6
+	SOL_TIPC                         = 0x10f
 	SOL_X25                          = 0x106
 	SOMAXCONN                        = 0x80
 	SO_ACCEPTCONN                    = 0x1e
@@ -1169,6 +1170,33 @@ const (
 	TIOCSSOFTCAR                     = 0x541a
 	TIOCSTI                          = 0x5412
 	TIOCSWINSZ                       = 0x5414
+	TIPC_ADDR_ID                     = 0x3
+	TIPC_ADDR_MCAST                  = 0x1
+	TIPC_ADDR_NAME                   = 0x2
+	TIPC_ADDR_NAMESEQ                = 0x1
+	TIPC_CONN_SHUTDOWN               = 0x5
+	TIPC_CONN_TIMEOUT                = 0x82
+	TIPC_CRITICAL_IMPORTANCE         = 0x3
+	TIPC_DESTNAME                    = 0x3
+	TIPC_DEST_DROPPABLE              = 0x81
+	TIPC_ERRINFO                     = 0x1
+	TIPC_ERR_NO_NAME                 = 0x1
+	TIPC_ERR_NO_NODE                 = 0x3
+	TIPC_ERR_NO_PORT                 = 0x2
+	TIPC_ERR_OVERLOAD                = 0x4
+	TIPC_GROUP_JOIN                  = 0x87
+	TIPC_GROUP_LEAVE                 = 0x88
+	TIPC_GROUP_LOOPBACK              = 0x1
+	TIPC_GROUP_MEMBER_EVTS           = 0x2
+	TIPC_HIGH_IMPORTANCE             = 0x2
+	TIPC_IMPORTANCE                  = 0x7f
+	TIPC_LOW_IMPORTANCE              = 0x0
+	TIPC_MEDIUM_IMPORTANCE           = 0x1
+	TIPC_NODE_RECVQ_DEPTH            = 0x83
+	TIPC_OK                          = 0x0
+	TIPC_RETDATA                     = 0x2
+	TIPC_SOCK_RECVQ_DEPTH            = 0x84
+	TIPC_SRC_DROPPABLE               = 0x80
 	TUNATTACHFILTER                  = 0x400854d5
 	TUNDETACHFILTER                  = 0x400854d6
 	TUNGETFEATURES                   = 0x800454cf
@@ -174,6 +174,13 @@ type RawSockaddrUnix struct {
 	Path   [108]int8
 }
 
+type RawSockaddrTIPC struct {
+	Family   uint16
+	AddrType uint8
+	Scope    int8
+	Addr     [12]byte
+}
+
 type RawSockaddrLinklayer struct {
 	Family   uint16
 	Protocol uint16
@@ -224,6 +231,44 @@ type IPMreqn struct {
 	Ifindex   int32
 }
 
+type TIPCGroupReq struct {
+	Type     uint32
+	Instance uint32
+	Scope    uint32
+	Flags    uint32
+}
+
+type TIPCPortID struct {
+	Ref  uint32
+	Node uint32
+}
+
+type TIPCName struct {
+	Type     uint32
+	Instance uint32
+}
+
+type TIPCNameSeq struct {
+	Type  uint32
+	Lower uint32
+	Upper uint32
+}
+
+type TIPCSubscr struct {
+	Seq        TIPCNameSeq
+	Timeout    uint32
+	Filter     uint32
+	Usr_handle [8]uint8
+}
+
+type TIPCEvent struct {
+	Event       uint32
+	Found_lower uint32
+	Found_upper uint32
+	Port        TIPCPortID
+	S           TIPCSubscr
+}
+
 type IPv6Mreq struct {
 	Multiaddr [16]byte /* in6_addr */
 	Interface uint32
@@ -311,11 +356,18 @@ const (
 	SizeofSockaddrInet6     = 0x1c
 	SizeofSockaddrAny       = 0x70
 	SizeofSockaddrUnix      = 0x6e
+	SizeofSockaddrTIPC      = 0x10
 	SizeofSockaddrLinklayer = 0x14
 	SizeofSockaddrNetlink   = 0xc
 	SizeofLinger            = 0x8
 	SizeofIPMreq            = 0x8
 	SizeofIPMreqn           = 0xc
+	SizeofTIPCGroupReq      = 0x10
+	SizeofTIPCPortID        = 0x8
+	SizeofTIPCName          = 0x8
+	SizeofTIPCNameSeq       = 0xc
+	SizeofTIPCSubscr        = 0x1c
+	SizeofTIPCEvent         = 0x30
 	SizeofIPv6Mreq          = 0x14
 	SizeofMsghdr            = 0x1c
 	SizeofCmsghdr           = 0xc